@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flanksource/clicky/api"
+	"github.com/flanksource/commons/cmd/hx/parse"
+	"github.com/flanksource/commons/har"
+	commonshttp "github.com/flanksource/commons/http"
+	"github.com/spf13/cobra"
+)
+
+const defaultBenchRequests = 100
+
+var benchCmd = &cobra.Command{
+	Use:   "bench [METHOD] URL [ITEMS...]",
+	Short: "Load test an endpoint and report latency percentiles",
+	Long: `bench sends requests concurrently using the same client configuration as a
+regular hx call (auth, mTLS, OAuth, retries) and prints throughput, latency
+percentiles and the status code distribution.
+
+The run stops after -n requests or once -d has elapsed, whichever comes first.
+With --har, up to --har-failures failed requests are sampled into the HAR file.
+
+Examples:
+  hx bench -c 20 -n 1000 https://httpbin.org/get
+  hx bench -c 5 -d 30s --token $TOKEN https://api.example.com/health
+  hx bench -n 200 --har failures.har POST https://httpbin.org/post name=test`,
+	Args:          cobra.MinimumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runBench,
+}
+
+var (
+	flagBenchConcurrency int
+	flagBenchRequests    int
+	flagBenchDuration    time.Duration
+	flagBenchHARFailures int
+)
+
+func init() {
+	f := benchCmd.Flags()
+	f.IntVarP(&flagBenchConcurrency, "concurrency", "c", 10, "Number of concurrent workers")
+	f.IntVarP(&flagBenchRequests, "requests", "n", 0, fmt.Sprintf("Total number of requests (default %d when no --duration is given)", defaultBenchRequests))
+	f.DurationVarP(&flagBenchDuration, "duration", "d", 0, "Run for this long instead of a fixed request count")
+	f.IntVar(&flagBenchHARFailures, "har-failures", 10, "Max failed requests sampled into --har")
+	f.StringVar(&flagData, "data", "", "Request body (string or @file)")
+
+	rootCmd.AddCommand(benchCmd)
+}
+
+// benchOptions controls a single benchmark run.
+type benchOptions struct {
+	Method      string
+	URL         string
+	Body        []byte
+	ContentType string
+	Items       *parse.ParsedItems
+	Concurrency int
+	Requests    int
+	Duration    time.Duration
+}
+
+// benchSample is the outcome of one request.
+type benchSample struct {
+	Latency time.Duration
+	Status  int
+	Err     error
+}
+
+// benchReport aggregates the samples of a benchmark run.
+type benchReport struct {
+	Elapsed   time.Duration
+	Latencies []time.Duration // sorted ascending
+	Statuses  map[int]int     // status code -> count, 0 for transport errors
+	Errors    int
+	Failed    int
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	parsed, err := parse.PositionalArgs(args)
+	if err != nil {
+		return err
+	}
+
+	items, err := parse.Items(parsed.Items)
+	if err != nil {
+		return err
+	}
+
+	body, contentType, err := resolveBody(items)
+	if err != nil {
+		return err
+	}
+
+	var bodyBytes []byte
+	if body != nil {
+		if bodyBytes, err = io.ReadAll(body); err != nil {
+			return fmt.Errorf("reading body: %w", err)
+		}
+	}
+
+	client := newClient(nil)
+	var sampler *failureSampler
+	if flagHAROutput != "" && flagBenchHARFailures > 0 {
+		sampler = &failureSampler{limit: flagBenchHARFailures}
+		client = client.HAR(sampler.add)
+	}
+
+	report := benchmark(context.Background(), client, benchOptions{
+		Method:      parsed.EffectiveMethod(body != nil, flagMethod),
+		URL:         parsed.URL,
+		Body:        bodyBytes,
+		ContentType: contentType,
+		Items:       items,
+		Concurrency: flagBenchConcurrency,
+		Requests:    flagBenchRequests,
+		Duration:    flagBenchDuration,
+	})
+
+	useColor := outputOpts().UseColor()
+	for _, table := range []api.TextTable{report.SummaryTable(), report.StatusTable()} {
+		if useColor {
+			fmt.Fprintln(os.Stdout, table.ANSI())
+		} else {
+			fmt.Fprintln(os.Stdout, table.String())
+		}
+	}
+
+	if sampler != nil {
+		if err := writeHAR(sampler.Entries(), flagHAROutput); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: HAR write failed: %v\n", err)
+		}
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d requests failed", report.Failed, report.Total())
+	}
+	return nil
+}
+
+// benchmark runs opts.Concurrency workers against opts.URL until opts.Requests
+// requests have been issued or opts.Duration has elapsed. Requests still in
+// flight at the deadline are cancelled and left out of the report.
+func benchmark(ctx context.Context, client *commonshttp.Client, opts benchOptions) benchReport {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Requests <= 0 && opts.Duration <= 0 {
+		opts.Requests = defaultBenchRequests
+	}
+
+	start := time.Now()
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, start.Add(opts.Duration))
+		defer cancel()
+	}
+
+	var issued atomic.Int64
+	samples := make(chan benchSample, opts.Concurrency)
+	var wg sync.WaitGroup
+	for range opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if ctx.Err() != nil {
+					return
+				}
+				if opts.Requests > 0 && issued.Add(1) > int64(opts.Requests) {
+					return
+				}
+				s := benchOnce(ctx, client, opts)
+				if s.Err != nil && ctx.Err() != nil {
+					// cancelled by the deadline, not a failure of the server
+					return
+				}
+				samples <- s
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+
+	report := benchReport{Statuses: make(map[int]int)}
+	for s := range samples {
+		report.add(s)
+	}
+	report.Elapsed = time.Since(start)
+	sort.Slice(report.Latencies, func(i, j int) bool { return report.Latencies[i] < report.Latencies[j] })
+	return report
+}
+
+func benchOnce(ctx context.Context, client *commonshttp.Client, opts benchOptions) benchSample {
+	req := newRequest(ctx, client, opts.Items, opts.ContentType)
+	if opts.Body != nil {
+		if err := req.Body(bytes.NewReader(opts.Body)); err != nil {
+			return benchSample{Err: err}
+		}
+	}
+
+	started := time.Now()
+	resp, err := req.Do(opts.Method, opts.URL)
+	if err != nil {
+		return benchSample{Latency: time.Since(started), Err: err}
+	}
+	// Drain the body so the latency includes the transfer and the
+	// connection can be reused by the next request.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return benchSample{Latency: time.Since(started), Status: resp.StatusCode}
+}
+
+func (r *benchReport) add(s benchSample) {
+	r.Latencies = append(r.Latencies, s.Latency)
+	r.Statuses[s.Status]++
+	if s.Err != nil {
+		r.Errors++
+	}
+	if s.Err != nil || s.Status >= 400 {
+		r.Failed++
+	}
+}
+
+// Total returns the number of completed requests.
+func (r benchReport) Total() int {
+	return len(r.Latencies)
+}
+
+// Throughput returns completed requests per second.
+func (r benchReport) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Total()) / r.Elapsed.Seconds()
+}
+
+// Percentile returns the nearest-rank latency percentile (0-100).
+func (r benchReport) Percentile(p float64) time.Duration {
+	n := len(r.Latencies)
+	if n == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(n)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > n {
+		rank = n
+	}
+	return r.Latencies[rank-1]
+}
+
+// Mean returns the average latency.
+func (r benchReport) Mean() time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, l := range r.Latencies {
+		sum += l
+	}
+	return sum / time.Duration(len(r.Latencies))
+}
+
+type benchSummaryRow struct {
+	report benchReport
+}
+
+// Columns implements api.TableProvider.
+func (benchSummaryRow) Columns() []api.ColumnDef {
+	return []api.ColumnDef{
+		api.Column("requests").Label("Requests").Build(),
+		api.Column("failed").Label("Failed").Build(),
+		api.Column("elapsed").Label("Elapsed").Build(),
+		api.Column("rps").Label("Req/s").Build(),
+		api.Column("mean").Label("Mean").Build(),
+		api.Column("p50").Label("p50").Build(),
+		api.Column("p90").Label("p90").Build(),
+		api.Column("p99").Label("p99").Build(),
+		api.Column("max").Label("Max").Build(),
+	}
+}
+
+// Row implements api.TableProvider.
+func (s benchSummaryRow) Row() map[string]any {
+	r := s.report
+	failedStyle := "text-muted"
+	if r.Failed > 0 {
+		failedStyle = "text-red-500 font-bold"
+	}
+	return map[string]any{
+		"requests": r.Total(),
+		"failed":   api.Text{Content: strconv.Itoa(r.Failed), Style: failedStyle},
+		"elapsed":  api.Human(r.Elapsed.Round(time.Millisecond), "text-muted"),
+		"rps":      fmt.Sprintf("%.1f", r.Throughput()),
+		"mean":     api.Human(r.Mean(), "text-muted"),
+		"p50":      api.Human(r.Percentile(50)),
+		"p90":      api.Human(r.Percentile(90)),
+		"p99":      api.Human(r.Percentile(99)),
+		"max":      api.Human(r.Percentile(100), "text-muted"),
+	}
+}
+
+// SummaryTable renders throughput and latency percentiles.
+func (r benchReport) SummaryTable() api.TextTable {
+	return api.NewTableFrom([]benchSummaryRow{{report: r}})
+}
+
+type benchStatusRow struct {
+	Status int
+	Count  int
+	Total  int
+}
+
+// Columns implements api.TableProvider.
+func (benchStatusRow) Columns() []api.ColumnDef {
+	return []api.ColumnDef{
+		api.Column("status").Label("Status").Build(),
+		api.Column("count").Label("Count").Build(),
+		api.Column("percent").Label("%").Build(),
+	}
+}
+
+// Row implements api.TableProvider.
+func (s benchStatusRow) Row() map[string]any {
+	status := api.Text{Content: "error", Style: "text-red-500 font-bold"}
+	if s.Status != 0 {
+		status = api.Text{Content: strconv.Itoa(s.Status), Style: statusStyle(s.Status)}
+	}
+	return map[string]any{
+		"status":  status,
+		"count":   s.Count,
+		"percent": fmt.Sprintf("%.1f", 100*float64(s.Count)/float64(s.Total)),
+	}
+}
+
+// StatusTable renders the status code distribution, transport errors last.
+func (r benchReport) StatusTable() api.TextTable {
+	rows := make([]benchStatusRow, 0, len(r.Statuses))
+	for status, count := range r.Statuses {
+		rows = append(rows, benchStatusRow{Status: status, Count: count, Total: r.Total()})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Status == 0 || rows[j].Status == 0 {
+			return rows[j].Status == 0 && rows[i].Status != 0
+		}
+		return rows[i].Status < rows[j].Status
+	})
+	return api.NewTableFrom(rows)
+}
+
+func statusStyle(code int) string {
+	switch {
+	case code >= 500:
+		return "text-red-500 font-bold"
+	case code >= 400:
+		return "text-yellow-500 font-bold"
+	case code >= 300:
+		return "text-blue-500 font-bold"
+	default:
+		return "text-green-500 font-bold"
+	}
+}
+
+// failureSampler keeps the first limit HAR entries for failed requests
+// (transport errors and responses >= 400).
+type failureSampler struct {
+	mu      sync.Mutex
+	limit   int
+	entries []har.Entry
+}
+
+func (s *failureSampler) add(e *har.Entry) {
+	if e.Response.Status != 0 && e.Response.Status < 400 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) < s.limit {
+		s.entries = append(s.entries, *e)
+	}
+}
+
+// Entries returns a copy of the sampled entries.
+func (s *failureSampler) Entries() []har.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]har.Entry(nil), s.entries...)
+}
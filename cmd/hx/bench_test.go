@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/commons/cmd/hx/parse"
+	commonshttp "github.com/flanksource/commons/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmarkFixedRequestCount(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	items, err := parse.Items(nil)
+	require.NoError(t, err)
+
+	report := benchmark(context.Background(), commonshttp.NewClient(), benchOptions{
+		Method:      "GET",
+		URL:         srv.URL + "/get",
+		Items:       items,
+		Concurrency: 4,
+		Requests:    25,
+	})
+
+	assert.Equal(t, 25, report.Total())
+	assert.Equal(t, 0, report.Failed)
+	assert.Equal(t, map[int]int{200: 25}, report.Statuses)
+	assert.True(t, report.Percentile(50) <= report.Percentile(99))
+	assert.Greater(t, report.Throughput(), 0.0)
+}
+
+func TestBenchmarkDuration(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	items, err := parse.Items(nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	report := benchmark(context.Background(), commonshttp.NewClient(), benchOptions{
+		Method:      "GET",
+		URL:         srv.URL + "/status/503",
+		Items:       items,
+		Concurrency: 2,
+		Duration:    200 * time.Millisecond,
+	})
+
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Greater(t, report.Total(), 0)
+	assert.Equal(t, report.Total(), report.Failed)
+	assert.Equal(t, report.Total(), report.Statuses[503])
+}
+
+func TestBenchmarkDurationCancelsInFlightRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	items, err := parse.Items(nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	report := benchmark(context.Background(), commonshttp.NewClient(), benchOptions{
+		Method:      "GET",
+		URL:         srv.URL,
+		Items:       items,
+		Concurrency: 2,
+		Duration:    200 * time.Millisecond,
+	})
+
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, 0, report.Total(), "cancelled requests should not be counted")
+	assert.Equal(t, 0, report.Failed)
+}
+
+func TestBenchReportPercentiles(t *testing.T) {
+	report := benchReport{Statuses: map[int]int{}}
+	for i := 1; i <= 100; i++ {
+		report.add(benchSample{Latency: time.Duration(i) * time.Millisecond, Status: 200})
+	}
+
+	assert.Equal(t, 50*time.Millisecond, report.Percentile(50))
+	assert.Equal(t, 90*time.Millisecond, report.Percentile(90))
+	assert.Equal(t, 99*time.Millisecond, report.Percentile(99))
+	assert.Equal(t, 100*time.Millisecond, report.Percentile(100))
+	assert.Equal(t, time.Duration(0), benchReport{}.Percentile(50))
+}
+
+func TestBenchSamplesFailuresIntoHAR(t *testing.T) {
+	srv := testServer()
+	defer srv.Close()
+
+	path := harFixturePath(t)
+	flagHAROutput = path
+	flagBenchRequests = 5
+	flagBenchConcurrency = 2
+	flagBenchHARFailures = 3
+	defer func() {
+		flagHAROutput = ""
+		flagBenchRequests = 0
+		flagBenchConcurrency = 10
+		flagBenchHARFailures = 10
+	}()
+
+	err := runBench(benchCmd, []string{srv.URL + "/status/500"})
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "5 of 5 requests failed"), err.Error())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var file map[string]any
+	require.NoError(t, json.Unmarshal(data, &file))
+	entries := file["log"].(map[string]any)["entries"].([]any)
+	assert.Len(t, entries, 3)
+}
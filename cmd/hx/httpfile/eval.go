@@ -0,0 +1,207 @@
+package httpfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/commons/lookup"
+	"github.com/flanksource/commons/utils"
+)
+
+// variableRef matches the REST Client `{{name}}` and the Go template
+// `{{.name}}` forms.
+var variableRef = regexp.MustCompile(`{{\s*\.?([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// Interpolate expands `{{name}}` and `{{.name}}` references in s using vars
+// with utils.Interpolate. Only variable references are expanded, so other
+// `{{` in a body are kept as is. It returns an error when a variable is not
+// defined, e.g. when the capture that sets it failed.
+func Interpolate(s string, vars map[string]string) (string, error) {
+	var err error
+	out := variableRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := variableRef.FindStringSubmatch(ref)[1]
+		if _, ok := vars[name]; !ok {
+			if err == nil {
+				err = fmt.Errorf("variable %q is not defined", name)
+			}
+			return ref
+		}
+		// utils.InterpolateE checks this itself, but is newer than the
+		// commons release hx builds against
+		return utils.Interpolate("{{."+name+"}}", vars)
+	})
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// ResponseContext returns the value captures and assertions are evaluated
+// against: status, headers (canonical names, joined values), body (raw text),
+// json (the decoded body, when it is JSON) and duration (milliseconds).
+func ResponseContext(status int, headers http.Header, body []byte, elapsed time.Duration) map[string]any {
+	h := make(map[string]any, len(headers))
+	for k, v := range headers {
+		h[http.CanonicalHeaderKey(k)] = strings.Join(v, ", ")
+	}
+	ctx := map[string]any{
+		"status":   status,
+		"headers":  h,
+		"body":     string(body),
+		"duration": elapsed.Milliseconds(),
+	}
+	var decoded any
+	if json.Unmarshal(body, &decoded) == nil {
+		ctx["json"] = decoded
+	}
+	return ctx
+}
+
+// Capture resolves a lookup path (e.g. json.items[0].id) against a response
+// context and returns its string form.
+func Capture(resp map[string]any, path string) (string, error) {
+	v, err := resolve(resp, path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return stringify(v), nil
+}
+
+var indexSuffix = regexp.MustCompile(`\[(\d+)\]`)
+
+// resolve walks a dotted lookup path one segment at a time so that indexes
+// into decoded JSON arrays (held as interface values) work, e.g. items[0].id.
+func resolve(resp map[string]any, path string) (reflect.Value, error) {
+	var current any = resp
+	for _, segment := range strings.Split(path, lookup.SplitToken) {
+		name, _, _ := strings.Cut(segment, lookup.IndexOpenChar)
+		if name != "" {
+			v, err := lookup.Lookup(current, name)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if !v.IsValid() {
+				return reflect.Value{}, lookup.ErrKeyNotFound
+			}
+			current = v.Interface()
+		}
+		for _, m := range indexSuffix.FindAllStringSubmatch(segment, -1) {
+			i, _ := strconv.Atoi(m[1])
+			items, ok := current.([]any)
+			if !ok {
+				return reflect.Value{}, lookup.ErrInvalidIndexUsage
+			}
+			if i >= len(items) {
+				return reflect.Value{}, lookup.ErrKeyNotFound
+			}
+			current = items[i]
+		}
+	}
+	return reflect.ValueOf(current), nil
+}
+
+// Assertion is a parsed `<path> <op> [value]` check.
+type Assertion struct {
+	Path  string
+	Op    string
+	Value string
+}
+
+var assertionOps = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"contains": true, "exists": true,
+}
+
+// ParseAssertion parses expressions such as `status == 200`,
+// `json.user.name != bob`, `headers.Content-Type contains json` or
+// `json.token exists`.
+func ParseAssertion(s string) (Assertion, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 || !assertionOps[fields[1]] {
+		return Assertion{}, fmt.Errorf("invalid assertion %q, expected: <path> <op> [value]", s)
+	}
+	a := Assertion{Path: fields[0], Op: fields[1]}
+	if a.Op == "exists" {
+		if len(fields) != 2 {
+			return Assertion{}, fmt.Errorf("invalid assertion %q: exists takes no value", s)
+		}
+		return a, nil
+	}
+	if len(fields) < 3 {
+		return Assertion{}, fmt.Errorf("invalid assertion %q: missing value", s)
+	}
+	a.Value = strings.Trim(strings.Join(fields[2:], " "), `"`)
+	return a, nil
+}
+
+func (a Assertion) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", a.Path, a.Op, a.Value))
+}
+
+// Check evaluates the assertion against a response context.
+func (a Assertion) Check(resp map[string]any) error {
+	v, err := resolve(resp, a.Path)
+	if a.Op == "exists" {
+		if err != nil || !v.IsValid() {
+			return fmt.Errorf("%s: not found", a)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", a, err)
+	}
+
+	actual := stringify(v)
+	var ok bool
+	switch a.Op {
+	case "==":
+		ok = actual == a.Value
+	case "!=":
+		ok = actual != a.Value
+	case "contains":
+		ok = strings.Contains(actual, a.Value)
+	default:
+		x, errX := strconv.ParseFloat(actual, 64)
+		y, errY := strconv.ParseFloat(a.Value, 64)
+		if errX != nil || errY != nil {
+			return fmt.Errorf("%s: cannot compare %q numerically", a, actual)
+		}
+		switch a.Op {
+		case "<":
+			ok = x < y
+		case "<=":
+			ok = x <= y
+		case ">":
+			ok = x > y
+		case ">=":
+			ok = x >= y
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%s: got %q", a, actual)
+	}
+	return nil
+}
+
+func stringify(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	switch val := v.Interface().(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case map[string]any, []any:
+		b, _ := json.Marshal(val)
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
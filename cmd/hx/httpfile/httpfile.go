@@ -0,0 +1,276 @@
+// Package httpfile parses request collections for `hx run`: the IntelliJ /
+// VS Code REST Client `.http` format and an equivalent YAML layout.
+//
+// A `.http` file is a list of requests separated by `###` lines:
+//
+//	@host = https://httpbin.org
+//
+//	### Login
+//	POST {{host}}/post
+//	Content-Type: application/json
+//
+//	{"user": "alice"}
+//
+//	# @capture user = json.json.user
+//	# @assert status == 200
+//
+//	### Profile
+//	GET {{host}}/get?user={{user}}
+//
+// `@name = value` lines declare variables, `# @name`, `# @capture` and
+// `# @assert` comment directives attach metadata to the request they appear in,
+// and a body of the form `< ./path` is read from a file relative to the
+// collection.
+package httpfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// File is a parsed request collection.
+type File struct {
+	// Vars are the file-level variables available to every request.
+	Vars map[string]string `json:"vars,omitempty"`
+
+	Requests []Request `json:"requests"`
+}
+
+// Request is a single request in a collection. URL, header values and the
+// body are interpolated against the collection variables (including values
+// captured from earlier responses) before the request is sent.
+type Request struct {
+	Name    string            `json:"name,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+
+	// BodyFile is read as the body when set, relative to the collection file.
+	BodyFile string `json:"bodyFile,omitempty"`
+
+	// Capture maps a variable name to a lookup path into the response,
+	// e.g. token: json.access_token
+	Capture map[string]string `json:"capture,omitempty"`
+
+	// Assert lists assertions evaluated against the response,
+	// e.g. "status == 200" or "headers.Content-Type contains json".
+	Assert []string `json:"assert,omitempty"`
+}
+
+// Title returns the request name, falling back to METHOD URL.
+func (r Request) Title() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Method + " " + r.URL
+}
+
+// Load reads a collection from path, choosing the format by extension:
+// .yaml, .yml and .json are parsed as YAML, anything else as a .http file.
+// Relative BodyFile paths are resolved against the directory of path.
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var file *File
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		file, err = ParseYAML(f)
+	default:
+		file, err = ParseHTTP(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i, req := range file.Requests {
+		if req.BodyFile != "" && !filepath.IsAbs(req.BodyFile) {
+			file.Requests[i].BodyFile = filepath.Join(dir, req.BodyFile)
+		}
+	}
+	return file, nil
+}
+
+// ParseYAML parses the YAML (or JSON) form of a collection.
+func ParseYAML(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	for i := range file.Requests {
+		if file.Requests[i].URL == "" {
+			return nil, fmt.Errorf("request %d: url is required", i+1)
+		}
+		if file.Requests[i].Method == "" {
+			file.Requests[i].Method = "GET"
+		}
+		file.Requests[i].Method = strings.ToUpper(file.Requests[i].Method)
+	}
+	return &file, nil
+}
+
+var (
+	varDeclaration = regexp.MustCompile(`^@([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
+	directive      = regexp.MustCompile(`^(?:#|//)\s*@(name|capture|assert)\b\s*(.*)$`)
+	requestLine    = regexp.MustCompile(`^(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS|TRACE|CONNECT)\s+(\S+)(?:\s+HTTP/[\d.]+)?$`)
+)
+
+// ParseHTTP parses the IntelliJ / VS Code `.http` request format.
+func ParseHTTP(r io.Reader) (*File, error) {
+	file := &File{Vars: map[string]string{}}
+
+	var block []string
+	var title string
+	lineNo, blockStart := 0, 1
+
+	flush := func() error {
+		req, ok, err := parseBlock(title, block, file.Vars)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", blockStart, err)
+		}
+		if ok {
+			file.Requests = append(file.Requests, req)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "###") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			title = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			block = nil
+			blockStart = lineNo
+			continue
+		}
+		block = append(block, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// parseBlock parses the lines between two `###` separators. Variable
+// declarations are added to vars; ok is false for blocks without a request
+// line (e.g. a preamble holding only variables).
+func parseBlock(title string, lines []string, vars map[string]string) (req Request, ok bool, err error) {
+	req = Request{Name: title, Headers: map[string]string{}, Capture: map[string]string{}}
+
+	const (
+		preamble = iota
+		headers
+		body
+	)
+	state := preamble
+	var bodyLines []string
+
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+
+		if m := directive.FindStringSubmatch(line); m != nil {
+			if err := applyDirective(&req, m[1], strings.TrimSpace(m[2])); err != nil {
+				return req, false, err
+			}
+			continue
+		}
+
+		switch state {
+		case preamble:
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+				continue
+			}
+			if m := varDeclaration.FindStringSubmatch(line); m != nil {
+				vars[m[1]] = strings.TrimSpace(m[2])
+				continue
+			}
+			if m := requestLine.FindStringSubmatch(line); m != nil {
+				req.Method, req.URL = m[1], m[2]
+			} else if !strings.ContainsAny(line, " \t") {
+				req.Method, req.URL = "GET", line
+			} else {
+				return req, false, fmt.Errorf("invalid request line: %s", line)
+			}
+			state = headers
+		case headers:
+			if line == "" {
+				state = body
+				continue
+			}
+			if strings.HasPrefix(line, "?") || strings.HasPrefix(line, "&") {
+				// multi-line query string continuation
+				req.URL += line
+				continue
+			}
+			if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+				continue
+			}
+			k, v, found := strings.Cut(line, ":")
+			if !found {
+				return req, false, fmt.Errorf("invalid header: %s", line)
+			}
+			req.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		case body:
+			if strings.HasPrefix(line, ">") {
+				// response handler scripts are not supported; use # @capture / # @assert
+				continue
+			}
+			bodyLines = append(bodyLines, raw)
+		}
+	}
+
+	if req.URL == "" {
+		return req, false, nil
+	}
+
+	text := strings.TrimSpace(strings.Join(bodyLines, "\n"))
+	if path, found := strings.CutPrefix(text, "< "); found && !strings.Contains(path, "\n") {
+		req.BodyFile = strings.TrimSpace(path)
+	} else {
+		req.Body = text
+	}
+	return req, true, nil
+}
+
+func applyDirective(req *Request, name, value string) error {
+	switch name {
+	case "name":
+		req.Name = value
+	case "capture":
+		k, path, found := strings.Cut(value, "=")
+		if !found || strings.TrimSpace(k) == "" || strings.TrimSpace(path) == "" {
+			return fmt.Errorf("invalid capture %q, expected: # @capture <var> = <path>", value)
+		}
+		req.Capture[strings.TrimSpace(k)] = strings.TrimSpace(path)
+	case "assert":
+		if _, err := ParseAssertion(value); err != nil {
+			return err
+		}
+		req.Assert = append(req.Assert, value)
+	}
+	return nil
+}
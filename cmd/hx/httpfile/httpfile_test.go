@@ -0,0 +1,165 @@
+package httpfile
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = `@host = https://httpbin.org
+@user = alice
+
+### Login
+POST {{host}}/post
+Content-Type: application/json
+# @capture token = json.json.user
+
+{
+  "user": "{{user}}"
+}
+
+# @assert status == 200
+# @assert headers.Content-Type contains json
+
+###
+# @name profile
+GET {{host}}/get
+    ?user={{token}}
+    &page=1
+Authorization: Bearer {{token}}
+
+### Upload
+PUT {{host}}/put
+Content-Type: application/json
+
+< ./body.json
+
+### Bare URL
+https://httpbin.org/uuid
+`
+
+func TestParseHTTP(t *testing.T) {
+	file, err := ParseHTTP(strings.NewReader(sample))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"host": "https://httpbin.org", "user": "alice"}, file.Vars)
+	require.Len(t, file.Requests, 4)
+
+	login := file.Requests[0]
+	assert.Equal(t, "Login", login.Name)
+	assert.Equal(t, "POST", login.Method)
+	assert.Equal(t, "{{host}}/post", login.URL)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, login.Headers)
+	assert.Equal(t, "{\n  \"user\": \"{{user}}\"\n}", login.Body)
+	assert.Equal(t, map[string]string{"token": "json.json.user"}, login.Capture)
+	assert.Equal(t, []string{"status == 200", "headers.Content-Type contains json"}, login.Assert)
+
+	profile := file.Requests[1]
+	assert.Equal(t, "profile", profile.Name)
+	assert.Equal(t, "{{host}}/get?user={{token}}&page=1", profile.URL)
+	assert.Equal(t, "Bearer {{token}}", profile.Headers["Authorization"])
+	assert.Empty(t, profile.Body)
+
+	assert.Equal(t, "./body.json", file.Requests[2].BodyFile)
+	assert.Empty(t, file.Requests[2].Body)
+
+	assert.Equal(t, "GET", file.Requests[3].Method)
+	assert.Equal(t, "https://httpbin.org/uuid", file.Requests[3].URL)
+}
+
+func TestParseHTTPErrors(t *testing.T) {
+	_, err := ParseHTTP(strings.NewReader("### bad\nGET https://x\nnot a header\n"))
+	assert.ErrorContains(t, err, "invalid header")
+
+	_, err = ParseHTTP(strings.NewReader("### bad\n# @assert status\nGET https://x\n"))
+	assert.ErrorContains(t, err, "invalid assertion")
+}
+
+func TestLoadYAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "api.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+vars:
+  host: http://localhost
+requests:
+  - name: create
+    method: post
+    url: "{{.host}}/items"
+    bodyFile: item.json
+    capture:
+      id: json.id
+    assert:
+      - status == 201
+  - url: "{{host}}/items/{{id}}"
+`), 0o644))
+
+	file, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, file.Requests, 2)
+	assert.Equal(t, "POST", file.Requests[0].Method)
+	assert.Equal(t, filepath.Join(dir, "item.json"), file.Requests[0].BodyFile)
+	assert.Equal(t, "GET", file.Requests[1].Method)
+	assert.Equal(t, "GET {{host}}/items/{{id}}", file.Requests[1].Title())
+}
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{"host": "http://localhost", "id": "42"}
+	for _, s := range []string{"{{host}}/items/{{ id }}", "{{.host}}/items/{{.id}}"} {
+		got, err := Interpolate(s, vars)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost/items/42", got)
+	}
+	got, err := Interpolate("no vars", vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "no vars", got)
+
+	body := `{"template": "{{#items}} {{> row}}", "open": "{{", "id": {{id}}}`
+	got, err = Interpolate(body, vars)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"template": "{{#items}} {{> row}}", "open": "{{", "id": 42}`, got)
+
+	_, err = Interpolate("{{host}}/items/{{missing}}", vars)
+	assert.ErrorContains(t, err, "missing")
+}
+
+func TestAssertions(t *testing.T) {
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	resp := ResponseContext(201, header, []byte(`{"id": 7, "items": [{"name": "a"}], "token": "abc"}`), 15*time.Millisecond)
+
+	tests := []struct {
+		expr string
+		ok   bool
+	}{
+		{"status == 201", true},
+		{"status != 201", false},
+		{"status < 300", true},
+		{"status >= 400", false},
+		{"json.id == 7", true},
+		{"json.items[0].name == a", true},
+		{"json.token exists", true},
+		{"json.missing exists", false},
+		{"headers.Content-Type contains json", true},
+		{"duration < 1000", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			a, err := ParseAssertion(tc.expr)
+			require.NoError(t, err)
+			if tc.ok {
+				assert.NoError(t, a.Check(resp))
+			} else {
+				assert.Error(t, a.Check(resp))
+			}
+		})
+	}
+
+	id, err := Capture(resp, "json.id")
+	require.NoError(t, err)
+	assert.Equal(t, "7", id)
+}
@@ -90,6 +90,11 @@ func PrintRequest(req *http.Request, body []byte, opts Options) {
 	fmt.Fprintln(w)
 }
 
+// PrintBody writes a body, pretty-printing JSON and coloring it when opts allow.
+func PrintBody(w io.Writer, body []byte, contentType string, opts Options) error {
+	return printBody(w, body, contentType, opts.UseColor())
+}
+
 func printStatusLine(w io.Writer, resp *http.Response, useColor bool) {
 	line := fmt.Sprintf("%s %s", resp.Proto, resp.Status)
 	if !useColor {
@@ -92,10 +92,13 @@ var (
 )
 
 func init() {
-	f := rootCmd.Flags()
+	// -d is kept local to the root command so subcommands (e.g. bench -d 30s)
+	// can reuse the shorthand; every other flag is shared with subcommands.
+	rootCmd.Flags().StringVarP(&flagData, "data", "d", "", "Request body (string or @file)")
+
+	f := rootCmd.PersistentFlags()
 
 	f.StringVarP(&flagMethod, "method", "X", "", "HTTP method override")
-	f.StringVarP(&flagData, "data", "d", "", "Request body (string or @file)")
 	f.StringSliceVarP(&flagForm, "form", "f", nil, "Form data (key=value)")
 	f.StringSliceVarP(&flagHeaders, "header", "H", nil, "Custom header (Key: Value)")
 	f.StringVar(&flagUserAgent, "user-agent", "hx/0.1", "User-Agent header")
@@ -145,10 +148,6 @@ func run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	for k, v := range items.Headers {
-		flagHeaders = append(flagHeaders, fmt.Sprintf("%s: %s", k, v))
-	}
-
 	body, contentType, err := resolveBody(items)
 	if err != nil {
 		return err
@@ -158,26 +157,7 @@ func run(cmd *cobra.Command, args []string) error {
 	method := parsed.EffectiveMethod(hasBody, flagMethod)
 
 	client, collector := buildClient()
-	req := client.R(context.Background())
-
-	for _, h := range flagHeaders {
-		if k, v, ok := strings.Cut(h, ":"); ok {
-			req = req.Header(strings.TrimSpace(k), strings.TrimSpace(v))
-		}
-	}
-
-	if contentType != "" {
-		req = req.Header("Content-Type", contentType)
-	}
-
-	for k, v := range items.QueryParams {
-		req = req.QueryParam(k, v)
-	}
-
-	if flagToken != "" {
-		req = req.Header("Authorization", "Bearer "+flagToken)
-	}
-
+	req := newRequest(context.Background(), client, items, contentType)
 
 	opts := outputOpts()
 
@@ -224,6 +204,35 @@ func run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// newRequest builds a request carrying the -H headers, header/query items,
+// content type and bearer token shared by every hx invocation.
+func newRequest(ctx context.Context, client *commonshttp.Client, items *parse.ParsedItems, contentType string) *commonshttp.Request {
+	req := client.R(ctx)
+
+	for _, h := range flagHeaders {
+		if k, v, ok := strings.Cut(h, ":"); ok {
+			req = req.Header(strings.TrimSpace(k), strings.TrimSpace(v))
+		}
+	}
+
+	for k, v := range items.Headers {
+		req = req.Header(k, v)
+	}
+
+	if contentType != "" {
+		req = req.Header("Content-Type", contentType)
+	}
+
+	for k, v := range items.QueryParams {
+		req = req.QueryParam(k, v)
+	}
+
+	if flagToken != "" {
+		req = req.Header("Authorization", "Bearer "+flagToken)
+	}
+	return req
+}
+
 func writeHAR(entries []har.Entry, dest string) error {
 	file := har.File{
 		Log: har.Log{
@@ -279,6 +288,17 @@ func printRequestVerbose(method, rawURL string, req *commonshttp.Request, body [
 }
 
 func buildClient() (*commonshttp.Client, *har.Collector) {
+	var collector *har.Collector
+	if flagHAROutput != "" {
+		collector = har.NewCollector(har.DefaultConfig())
+	}
+	return newClient(collector), collector
+}
+
+// newClient builds a Client from the auth, TLS, retry and trace flags. When
+// collector is non-nil it is attached before OAuth so token fetches are
+// captured too.
+func newClient(collector *har.Collector) *commonshttp.Client {
 	var tracer func(string)
 	if flagVerbose >= 1 {
 		useColor := term.IsTerminal(int(os.Stderr.Fd()))
@@ -295,9 +315,7 @@ func buildClient() (*commonshttp.Client, *har.Collector) {
 		Timeout(flagTimeout).
 		UserAgent(flagUserAgent)
 
-	var collector *har.Collector
-	if flagHAROutput != "" {
-		collector = har.NewCollector(har.DefaultConfig())
+	if collector != nil {
 		client = client.HARCollector(collector)
 	}
 
@@ -410,7 +428,7 @@ func buildClient() (*commonshttp.Client, *har.Collector) {
 		client = client.TraceToStdout(commonshttp.TraceHeaders)
 	}
 
-	return client, collector
+	return client
 }
 
 func resolveBody(items *parse.ParsedItems) (io.Reader, string, error) {
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/flanksource/clicky/api"
+	"github.com/flanksource/commons/cmd/hx/httpfile"
+	"github.com/flanksource/commons/cmd/hx/output"
+	"github.com/flanksource/commons/cmd/hx/parse"
+	"github.com/flanksource/commons/console"
+	commonshttp "github.com/flanksource/commons/http"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run FILE",
+	Short: "Run the requests in a .http or YAML file",
+	Long: `run executes the requests in an IntelliJ / VS Code REST Client .http file
+(or the equivalent YAML) in order, using the same client configuration as a
+regular hx call.
+
+Variables declared with @name = value, --var or captured from earlier
+responses are interpolated into URLs, headers and bodies using {{name}}.
+
+Directives in .http files:
+  # @name login                          name the request
+  # @capture token = json.access_token   store a response value in a variable
+  # @assert status == 200                check the response (==, !=, <, <=, >, >=, contains, exists)
+
+Examples:
+  hx run requests.http
+  hx run --var host=http://localhost:8080 --junit results.xml api.yaml`,
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runFile,
+}
+
+var (
+	flagRunVars     map[string]string
+	flagRunJUnit    string
+	flagRunFailFast bool
+	flagRunShowBody bool
+)
+
+func init() {
+	f := runCmd.Flags()
+	f.StringToStringVar(&flagRunVars, "var", nil, "Set a variable (name=value), overriding the file")
+	f.StringVar(&flagRunJUnit, "junit", "", "Write JUnit XML results to file (use - for stdout)")
+	f.BoolVar(&flagRunFailFast, "fail-fast", false, "Stop at the first failed request")
+	f.BoolVar(&flagRunShowBody, "body", false, "Print each response body")
+
+	rootCmd.AddCommand(runCmd)
+}
+
+// requestResult is the outcome of one request in a collection.
+type requestResult struct {
+	Request     httpfile.Request
+	Method      string
+	URL         string
+	Status      int
+	Duration    time.Duration
+	Body        []byte
+	ContentType string
+	Failures    []string
+}
+
+func (r requestResult) Failed() bool {
+	return len(r.Failures) > 0
+}
+
+func runFile(cmd *cobra.Command, args []string) error {
+	file, err := httpfile.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	vars := map[string]string{}
+	for k, v := range file.Vars {
+		vars[k] = v
+	}
+	for k, v := range flagRunVars {
+		vars[k] = v
+	}
+
+	client, collector := buildClient()
+	results := runRequests(context.Background(), client, file.Requests, vars, flagRunFailFast)
+
+	useColor := outputOpts().UseColor()
+	for _, r := range results {
+		printResult(r, useColor)
+	}
+
+	if collector != nil {
+		if err := writeHAR(collector.Entries(), flagHAROutput); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: HAR write failed: %v\n", err)
+		}
+	}
+
+	suites := junitResults(filepath.Base(args[0]), results)
+	if flagRunJUnit != "" {
+		xml, err := suites.ToXML()
+		if err != nil {
+			return err
+		}
+		if flagRunJUnit == "-" {
+			fmt.Fprintln(os.Stdout, xml)
+		} else if err := os.WriteFile(flagRunJUnit, []byte(xml), 0o644); err != nil {
+			return fmt.Errorf("writing junit results: %w", err)
+		}
+	}
+
+	if failures := suites.Suites[0].Failures; failures > 0 {
+		return fmt.Errorf("%d of %d requests failed", failures, len(results))
+	}
+	return nil
+}
+
+// runRequests sends reqs in order. Values captured from each response are
+// added to vars so later requests can reference them.
+func runRequests(ctx context.Context, client *commonshttp.Client, reqs []httpfile.Request, vars map[string]string, failFast bool) []requestResult {
+	results := make([]requestResult, 0, len(reqs))
+	for _, req := range reqs {
+		result := runRequest(ctx, client, req, vars)
+		results = append(results, result)
+		if failFast && result.Failed() {
+			break
+		}
+	}
+	return results
+}
+
+func runRequest(ctx context.Context, client *commonshttp.Client, req httpfile.Request, vars map[string]string) requestResult {
+	result := requestResult{Request: req, Method: req.Method, URL: req.URL}
+	fail := func(format string, args ...any) requestResult {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+		return result
+	}
+
+	url, err := httpfile.Interpolate(req.URL, vars)
+	if err != nil {
+		return fail("interpolating url: %v", err)
+	}
+	result.URL = url
+
+	body := req.Body
+	if req.BodyFile != "" {
+		data, err := os.ReadFile(req.BodyFile)
+		if err != nil {
+			return fail("reading body: %v", err)
+		}
+		body = string(data)
+	}
+
+	items := &parse.ParsedItems{Headers: make(map[string]string, len(req.Headers))}
+	for k, v := range req.Headers {
+		if items.Headers[k], err = httpfile.Interpolate(v, vars); err != nil {
+			return fail("interpolating header %s: %v", k, err)
+		}
+	}
+	r := newRequest(ctx, client, items, "")
+	if body != "" {
+		if body, err = httpfile.Interpolate(body, vars); err != nil {
+			return fail("interpolating body: %v", err)
+		}
+		if err := r.Body(body); err != nil {
+			return fail("setting body: %v", err)
+		}
+	}
+
+	started := time.Now()
+	resp, err := r.Do(result.Method, result.URL)
+	if err != nil {
+		result.Duration = time.Since(started)
+		result.Failures = append(result.Failures, err.Error())
+		return result
+	}
+	defer resp.Body.Close()
+	result.Body, err = io.ReadAll(resp.Body)
+	result.Duration = time.Since(started)
+	result.Status = resp.StatusCode
+	result.ContentType = resp.Header.Get("Content-Type")
+	if err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("reading response: %v", err))
+		return result
+	}
+
+	respCtx := httpfile.ResponseContext(resp.StatusCode, resp.Header, result.Body, result.Duration)
+	for name, path := range req.Capture {
+		value, err := httpfile.Capture(respCtx, path)
+		if err != nil {
+			result.Failures = append(result.Failures, fmt.Sprintf("capture %s: %v", name, err))
+			continue
+		}
+		vars[name] = value
+	}
+
+	for _, expr := range req.Assert {
+		assertion, err := httpfile.ParseAssertion(expr)
+		if err == nil {
+			err = assertion.Check(respCtx)
+		}
+		if err != nil {
+			result.Failures = append(result.Failures, err.Error())
+		}
+	}
+	return result
+}
+
+func printResult(r requestResult, useColor bool) {
+	mark, style := "✓", "text-green-500 font-bold"
+	if r.Failed() {
+		mark, style = "✗", "text-red-500 font-bold"
+	}
+
+	t := api.Text{}.
+		AddText(mark+" ", style).
+		AddText(r.Request.Title(), "font-bold").
+		AddText(" "+r.Method, "text-green-500 uppercase").
+		AddText(" " + r.URL)
+	if r.Status != 0 {
+		t = t.AddText(fmt.Sprintf(" %d", r.Status), statusStyle(r.Status))
+	}
+	t = t.AddText(" ").Add(api.Human(r.Duration.Round(time.Millisecond), "text-muted"))
+	for _, f := range r.Failures {
+		t = t.NewLine().AddText("    "+f, "text-red-500")
+	}
+
+	if useColor {
+		fmt.Fprintln(os.Stdout, t.ANSI())
+	} else {
+		fmt.Fprintln(os.Stdout, t.String())
+	}
+	if flagRunShowBody && len(r.Body) > 0 {
+		_ = output.PrintBody(os.Stdout, r.Body, r.ContentType, outputOpts())
+	}
+}
+
+// junitResults converts results into a single JUnit suite named after the
+// collection file.
+func junitResults(name string, results []requestResult) console.JUnitTestSuites {
+	suite := console.JUnitTestSuite{Name: name, Tests: len(results)}
+	var total time.Duration
+	for _, r := range results {
+		total += r.Duration
+		tc := console.JUnitTestCase{
+			Classname: strings.TrimSuffix(name, filepath.Ext(name)),
+			Name:      r.Request.Title(),
+			Time:      fmt.Sprintf("%.3f", r.Duration.Seconds()),
+		}
+		if r.Failed() {
+			suite.Failures++
+			tc.Failure = &console.JUnitFailure{
+				Message:  r.Failures[0],
+				Type:     "AssertionError",
+				Contents: strings.Join(r.Failures, "\n"),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+	return console.JUnitTestSuites{Suites: []console.JUnitTestSuite{suite}}
+}
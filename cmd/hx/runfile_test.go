@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flanksource/commons/console"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunFileChainsCapturedValues(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"token": "tok-123", "user": map[string]any{"id": 7}})
+	})
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok-123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]any{"id": r.PathValue("id")})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.http")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`@host = %s
+
+### Login
+POST {{host}}/login
+Content-Type: application/json
+
+{"user": "alice"}
+
+# @capture token = json.token
+# @capture id = json.user.id
+# @assert status == 200
+
+### Profile
+GET {{host}}/users/{{id}}
+Authorization: Bearer {{token}}
+
+# @assert json.id == 7
+
+### Wrong expectation
+GET {{host}}/users/{{id}}
+
+# @assert status == 200
+`, srv.URL)), 0o644))
+
+	junit := filepath.Join(dir, "results.xml")
+	flagRunJUnit = junit
+	flagQuiet = true
+	defer func() { flagRunJUnit = ""; flagQuiet = false }()
+
+	err := runFile(runCmd, []string{path})
+	require.Error(t, err)
+	assert.Equal(t, "1 of 3 requests failed", err.Error())
+
+	data, err := os.ReadFile(junit)
+	require.NoError(t, err)
+
+	var suites console.JUnitTestSuites
+	require.NoError(t, xml.Unmarshal(data, &suites))
+	require.Len(t, suites.Suites, 1)
+	suite := suites.Suites[0]
+	assert.Equal(t, "flow.http", suite.Name)
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	require.Len(t, suite.TestCases, 3)
+	assert.Nil(t, suite.TestCases[0].Failure)
+	assert.Nil(t, suite.TestCases[1].Failure)
+	require.NotNil(t, suite.TestCases[2].Failure)
+	assert.Contains(t, suite.TestCases[2].Failure.Message, `status == 200: got "401"`)
+}
+
+func TestRunFileUsesGlobalFlags(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"auth": r.Header.Get("Authorization"), "tenant": r.Header.Get("X-Tenant")})
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "flags.http")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`### Whoami
+GET %s/whoami
+
+# @assert json.auth == "Bearer tok-123"
+# @assert json.tenant == "acme"
+
+### Undefined variable
+GET %s/items/{{missing}}
+
+### Skipped
+GET %s/whoami
+`, srv.URL, srv.URL, srv.URL)), 0o644))
+
+	flagToken, flagHeaders, flagRunFailFast, flagQuiet = "tok-123", []string{"X-Tenant: acme"}, true, true
+	defer func() { flagToken, flagHeaders, flagRunFailFast, flagQuiet = "", nil, false, false }()
+
+	err := runFile(runCmd, []string{path})
+	require.Error(t, err)
+	assert.Equal(t, "1 of 2 requests failed", err.Error())
+}
@@ -2,43 +2,38 @@ package console
 
 import (
 	"strings"
+
+	"github.com/flanksource/commons/logger"
 )
 
-// StripSecrets takes a YAML or INI formatted text and removes any potentially secret data
-// as denoted by keys containing "pass" or "secret" or exact matches for "key"
-// the last character of the secret is kept to aid in troubleshooting
+// StripSecrets takes a YAML or INI formatted text and masks any potentially
+// secret data: values of keys containing "pass" or "secret", or named exactly
+// "key" or "token", and well-known token formats anywhere in the text. Masks
+// follow logger.GetRedactionPolicy, but unlike its RedactText the keys are
+// kept to this narrower set so that config such as usernames stays readable.
 func StripSecrets(text string) string {
-	out := ""
-	for _, line := range strings.Split(text, "\n") {
-		var k, v, sep string
-		if strings.Contains(line, ":") {
-			parts := strings.Split(line, ":")
-			k = parts[0]
-			if len(parts) > 1 {
-				v = parts[1]
-			}
-			sep = ":"
-		} else if strings.Contains(line, "=") {
-			parts := strings.Split(line, "=")
-			k = parts[0]
-			if len(parts) > 1 {
-				v = parts[1]
-			}
-			sep = "="
-		} else {
-			v = line
+	policy := logger.GetRedactionPolicy()
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		sep := strings.Index(line, ":")
+		if sep < 0 {
+			sep = strings.Index(line, "=")
 		}
-
-		if strings.Contains(k, "pass") || strings.Contains(k, "secret") || strings.TrimSpace(k) == "key" || strings.TrimSpace(k) == "token" {
-			if len(v) == 0 {
-				out += k + sep + "\n"
-			} else {
-				out += k + sep + "****" + v[len(v)-1:] + "\n"
-			}
-		} else {
-			out += k + sep + v + "\n"
+		if sep < 0 || !isSecretKey(line[:sep]) {
+			continue
 		}
+		value := line[sep+1:]
+		trimmed := strings.TrimSpace(value)
+		if trimmed == "" {
+			continue
+		}
+		lead := value[:strings.Index(value, trimmed)]
+		lines[i] = line[:sep+1] + lead + policy.MaskValue(trimmed)
 	}
-	return out
+	return policy.RedactString(strings.Join(lines, "\n"))
+}
 
+func isSecretKey(key string) bool {
+	key = strings.ToLower(strings.Trim(strings.TrimSpace(key), `"'`))
+	return strings.Contains(key, "pass") || strings.Contains(key, "secret") || key == "key" || key == "token"
 }
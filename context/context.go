@@ -300,15 +300,15 @@ func (c Context) IsTrace() bool {
 
 func (c Context) Debugf(format string, args ...interface{}) {
 	if c.IsDebug() {
-		c.GetSpan().AddEvent(fmt.Sprintf(format, args...), trace.WithAttributes(attribute.String("level", "debug")))
-		c.Logger.WithSkipReportLevel(1).Debugf(format, args...)
+		c.addEvent(logger.Debug, "debug", format, args...)
+		c.logger().Debugf(format, args...)
 	}
 }
 
 func (c Context) Tracef(format string, args ...interface{}) {
 	if c.IsTrace() {
-		c.GetSpan().AddEvent(fmt.Sprintf(format, args...), trace.WithAttributes(attribute.String("level", "trace")))
-		c.Logger.WithSkipReportLevel(1).Tracef(format, args...)
+		c.addEvent(logger.Trace, "trace", format, args...)
+		c.logger().Tracef(format, args...)
 	}
 }
 
@@ -322,10 +322,12 @@ func (c Context) Error(err error, msg ...any) {
 	c.GetSpan().RecordError(err)
 	c.GetSpan().SetStatus(codes.Error, err.Error())
 
-	if o, ok := oops.AsOops(err); ok {
-		c.Logger.WithSkipReportLevel(1).Errorf("%#v", o.ToMap())
+	if l, ok := c.logger().(logger.ErrorLogger); ok {
+		l.LogError(err)
+	} else if o, ok := oops.AsOops(err); ok {
+		c.logger().Errorf("%#v", o.ToMap())
 	} else {
-		c.Logger.WithSkipReportLevel(1).Errorf(err.Error())
+		c.logger().Errorf(err.Error())
 	}
 }
 
@@ -333,31 +335,46 @@ func (c Context) Errorf(format string, args ...interface{}) {
 	err := fmt.Sprintf(format, args...)
 	c.GetSpan().RecordError(errors.New(err))
 	c.GetSpan().SetStatus(codes.Error, err)
-	c.Logger.WithSkipReportLevel(1).Errorf(err)
+	c.logger().Errorf(err)
 }
 
 func (c Context) Infof(format string, args ...interface{}) {
 	if c.IsDebug() {
 		// info level logs should only be pushed for debug traces
-		c.GetSpan().AddEvent(fmt.Sprintf(format, args...), trace.WithAttributes(attribute.String("level", "info")))
+		c.addEvent(logger.Info, "info", format, args...)
 	}
-	c.Logger.WithSkipReportLevel(1).Infof(fmt.Sprintf(format, args...))
+	c.logger().Infof(fmt.Sprintf(format, args...))
 }
 
 func (c Context) Warnf(format string, args ...interface{}) {
 	if c.IsDebug() {
 		// info level logs should only be pushed for debug traces
-		c.GetSpan().AddEvent(fmt.Sprintf(format, args...), trace.WithAttributes(attribute.String("level", "warn")))
+		c.addEvent(logger.Warn, "warn", format, args...)
 	}
-	c.Logger.WithSkipReportLevel(1).Warnf(fmt.Sprintf(format, args...))
+	c.logger().Warnf(fmt.Sprintf(format, args...))
 }
 
 func (c Context) Logf(level int, format string, args ...interface{}) {
 	if c.IsTrace() {
 		// info level logs should only be pushed for debug traces
-		c.GetSpan().AddEvent(fmt.Sprintf(format, args...), trace.WithAttributes(attribute.String("level", fmt.Sprintf("%d", level))))
+		c.addEvent(logger.LogLevel(level), fmt.Sprintf("%d", level), format, args...)
 	}
-	c.Logger.WithSkipReportLevel(1).V(level).Infof(format, args...)
+	c.logger().V(level).Infof(format, args...)
+}
+
+// logger returns the context's logger reporting its caller's caller, with
+// records correlated to the span in c.
+func (c Context) logger() logger.Logger {
+	return logger.WithContext(c.Logger.WithSkipReportLevel(1), c.Context)
+}
+
+// addEvent records a log message as an event on the span, unless the logger
+// already does so for this level (see the log.span.events property).
+func (c Context) addEvent(level logger.LogLevel, label, format string, args ...interface{}) {
+	if logger.SpanEventsEnabled(level.Slog()) {
+		return
+	}
+	c.GetSpan().AddEvent(fmt.Sprintf(format, args...), trace.WithAttributes(attribute.String("level", label)))
 }
 
 func (c Context) GetSpan() trace.Span {
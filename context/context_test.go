@@ -1,9 +1,11 @@
 package context
 
 import (
+	"bytes"
 	gocontext "context"
 	"testing"
 
+	"github.com/flanksource/commons/logger"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -75,3 +77,16 @@ func TestContext(t *testing.T) {
 	span.AddEvent("test event from outer")
 
 }
+
+func TestContextLogsCarrySpanIDs(t *testing.T) {
+	RegisterTestingT(t)
+	var buf bytes.Buffer
+	ctx := NewContext(gocontext.Background(), WithTracer(tracer), WithLogger(logger.NewWithWriter(&buf)))
+
+	ctx, span := ctx.StartSpan("correlated")
+	defer span.End()
+	ctx.Infof("inside span")
+
+	Expect(buf.String()).To(ContainSubstring(span.SpanContext().TraceID().String()))
+	Expect(buf.String()).To(ContainSubstring(span.SpanContext().SpanID().String()))
+}
@@ -1,29 +1,219 @@
 package har
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/flanksource/commons/http/middlewares"
+	"github.com/flanksource/commons/logger"
 )
 
 // Collector accumulates HAR entries from multiple sources (main requests,
 // OAuth token fetches, redirect hops, retries).
+//
+// By default every entry is kept in memory. Long-running processes should
+// bound retention with HARConfig.MaxEntries / MaxBytes, and use StreamTo to
+// write every entry to disk as it is captured.
 type Collector struct {
 	Config  HARConfig
 	mu      sync.Mutex
 	entries []Entry
+	// sizes holds the JSON-encoded size of each entry, indexed like entries
+	sizes   []int64
+	bytes   int64
+	dropped int64
+	pages   []Page
+	// pageStarts holds the start time of each page, indexed like pages
+	pageStarts []time.Time
+	writer     *Writer
+	exporter   *OTelExporter
 }
 
 func NewCollector(cfg HARConfig) *Collector {
 	return &Collector{Config: cfg}
 }
 
-// Add appends an entry to the collector. Safe for concurrent use.
+// Add appends an entry to the collector. Entries without a Pageref are
+// assigned to the current page, if one has been started. Safe for concurrent use.
 func (c *Collector) Add(e *Entry) {
 	c.mu.Lock()
-	c.entries = append(c.entries, *e)
+	entry, data := c.add(e)
+	writer, exporter := c.writer, c.exporter
 	c.mu.Unlock()
+
+	// written outside c.mu, so concurrent requests do not queue behind disk
+	// writes and rotations
+	if writer != nil && data != nil {
+		// a writer closed meanwhile keeps the entry in memory only, see Close
+		if err := writer.writeRaw(data); err != nil && !errors.Is(err, os.ErrClosed) {
+			logger.Warnf("har: failed to write entry to %s: %v", writer.Path(), err)
+		}
+	}
+	entry.ctx = e.ctx
+	exporter.Export(&entry)
+}
+
+// add stores e and returns the stored copy, and its JSON encoding when it
+// must be streamed. Callers must hold c.mu.
+func (c *Collector) add(e *Entry) (Entry, []byte) {
+	entry := *e
+	// the request context, with its values and span, must not outlive the
+	// request: only the exporter uses it, see Add
+	entry.ctx = nil
+	if entry.Pageref == "" && len(c.pages) > 0 {
+		entry.Pageref = c.pages[len(c.pages)-1].ID
+	}
+	c.updatePageTimings(entry)
+
+	var size int64
+	var stream []byte
+	if c.writer != nil || c.Config.MaxBytes > 0 {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			logger.Warnf("har: failed to encode entry for %s: %v", entry.Request.URL, err)
+		}
+		size = int64(len(data))
+		if c.writer != nil && err == nil {
+			stream = data
+		}
+	}
+
+	c.entries = append(c.entries, entry)
+	c.sizes = append(c.sizes, size)
+	c.bytes += size
+	c.evict()
+	return entry, stream
+}
+
+// evict drops the oldest entries until the retention limits are met.
+// Callers must hold c.mu.
+func (c *Collector) evict() {
+	n := 0
+	for remaining := len(c.entries); remaining > 0; remaining-- {
+		overCount := c.Config.MaxEntries > 0 && remaining > c.Config.MaxEntries
+		overBytes := c.Config.MaxBytes > 0 && c.bytes > c.Config.MaxBytes && remaining > 1
+		if !overCount && !overBytes {
+			break
+		}
+		c.bytes -= c.sizes[n]
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	// release the evicted entries' bodies; append reallocates the backing
+	// array once it fills up, so the slices don't grow without bound
+	clear(c.entries[:n])
+	c.entries = c.entries[n:]
+	c.sizes = c.sizes[n:]
+	c.dropped += int64(n)
+}
+
+// Dropped returns the number of entries evicted from memory by the
+// MaxEntries / MaxBytes limits. Evicted entries are still in the stream, if any.
+func (c *Collector) Dropped() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// StreamTo writes every entry added from now on (and all pages) to w. The
+// collector takes ownership of w: call Collector.Close to finish the file.
+func (c *Collector) StreamTo(w *Writer) *Collector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writer = w
+	for _, p := range c.pages {
+		w.AddPage(p)
+	}
+	return c
+}
+
+// ExportTo publishes every entry added from now on to OpenTelemetry via x.
+func (c *Collector) ExportTo(x *OTelExporter) *Collector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exporter = x
+	return c
+}
+
+// Streaming returns the Writer set with StreamTo, or nil.
+func (c *Collector) Streaming() *Writer {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writer
+}
+
+// Close finishes the streamed HAR file, if any. Entries added afterwards are
+// only kept in memory.
+func (c *Collector) Close() error {
+	c.mu.Lock()
+	w := c.writer
+	c.writer = nil
+	c.mu.Unlock()
+	if w == nil {
+		return nil
+	}
+	return w.Close()
+}
+
+// StartPage begins a new page: entries added from now on are grouped under
+// it until the next call to StartPage. Use one page per step of a multi-step
+// workflow so the steps show up separately in HAR viewers. Returns the page ID.
+func (c *Collector) StartPage(title string) string {
+	started := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	page := Page{
+		StartedDateTime: started.UTC().Format(startedDateTimeFormat),
+		ID:              fmt.Sprintf("page_%d", len(c.pages)+1),
+		Title:           title,
+	}
+	c.pages = append(c.pages, page)
+	c.pageStarts = append(c.pageStarts, started)
+	if c.writer != nil {
+		c.writer.AddPage(page)
+	}
+	return page.ID
+}
+
+// Pages returns a copy of all pages started on this collector.
+func (c *Collector) Pages() []Page {
+	if c == nil {
+		return []Page{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Page, len(c.pages))
+	copy(out, c.pages)
+	return out
+}
+
+// updatePageTimings extends the OnLoad time of the entry's page up to now,
+// as entries are added once they complete. Callers must hold c.mu.
+func (c *Collector) updatePageTimings(e Entry) {
+	for i := range c.pages {
+		if c.pages[i].ID != e.Pageref {
+			continue
+		}
+		onLoad := ms(time.Since(c.pageStarts[i]))
+		if onLoad > c.pages[i].PageTimings.OnLoad {
+			c.pages[i].PageTimings.OnLoad = onLoad
+			if c.writer != nil {
+				c.writer.AddPage(c.pages[i])
+			}
+		}
+		return
+	}
 }
 
 // Entries returns a copy of all collected entries.
@@ -258,3 +258,50 @@ func TestRedirectPolicy_NoFollow(t *testing.T) {
 		t.Errorf("expected 302, got %d", resp.StatusCode)
 	}
 }
+
+func TestCollector_StartPageGroupsEntries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	collector := har.NewCollector(har.DefaultConfig())
+	client := commonshttp.NewClient().HARCollector(collector)
+	get := func(path string) {
+		resp, err := client.R(context.Background()).Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	get("/before")
+	login := collector.StartPage("Login")
+	get("/login")
+	fetch := collector.StartPage("Fetch")
+	get("/items")
+	get("/items/1")
+
+	pages := collector.Pages()
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(pages))
+	}
+	if pages[0].ID != login || pages[0].Title != "Login" || pages[1].ID != fetch || pages[1].Title != "Fetch" {
+		t.Errorf("unexpected pages: %+v", pages)
+	}
+	if login == fetch {
+		t.Errorf("page IDs should be unique, got %q twice", login)
+	}
+
+	want := []string{"", login, fetch, fetch}
+	for i, e := range collector.Entries() {
+		if e.Pageref != want[i] {
+			t.Errorf("entry %d (%s): expected pageref %q, got %q", i, e.Request.URL, want[i], e.Pageref)
+		}
+	}
+	for _, p := range pages {
+		if p.PageTimings.OnLoad <= 0 {
+			t.Errorf("page %s: expected onLoad to cover its entries, got %f", p.ID, p.PageTimings.OnLoad)
+		}
+	}
+}
@@ -1,8 +1,14 @@
 // Package har provides HAR 1.2 types and an HTTP client middleware for
-// capturing outbound request/response pairs for troubleshooting.
+// capturing outbound request/response pairs for troubleshooting, along with
+// a reader and query API for analyzing captured or browser-exported files,
+// and an exporter publishing entries to OpenTelemetry.
 package har
 
-import "github.com/flanksource/commons/properties"
+import (
+	"context"
+
+	"github.com/flanksource/commons/properties"
+)
 
 const defaultMaxBodySize = 64 * 1024 // 64 KB
 
@@ -12,6 +18,14 @@ const defaultMaxBodySize = 64 * 1024 // 64 KB
 // -P http.har.maxBodySize=0 to capture full bodies with no cap.
 const MaxBodySizeProperty = "http.har.maxBodySize"
 
+// MaxEntriesProperty is the -P/properties key that caps the number of
+// entries a Collector retains in memory, e.g. -P http.har.maxEntries=1000.
+const MaxEntriesProperty = "http.har.maxEntries"
+
+// MaxBytesProperty is the -P/properties key that caps the approximate
+// (JSON-encoded) size of the entries a Collector retains in memory.
+const MaxBytesProperty = "http.har.maxBytes"
+
 // HARConfig controls what the HAR middleware captures and how it redacts.
 type HARConfig struct {
 	// MaxBodySize is the maximum number of bytes captured per body.
@@ -26,16 +40,28 @@ type HARConfig struct {
 	// RedactedHeaders lists additional header name glob patterns to redact,
 	// on top of logger.CommonRedactedHeaders.
 	RedactedHeaders []string
+
+	// MaxEntries caps the number of entries a Collector keeps in memory; the
+	// oldest are evicted first. 0 means unlimited.
+	MaxEntries int
+
+	// MaxBytes caps the JSON-encoded size of the entries a Collector keeps in
+	// memory; the oldest are evicted first, but the newest entry is always
+	// kept. 0 means unlimited.
+	MaxBytes int64
 }
 
 // DefaultConfig returns a HARConfig with sensible defaults. The per-body
 // capture cap honours the MaxBodySizeProperty (-P http.har.maxBodySize=…)
 // override; an unset or unparseable value keeps the 64 KB default, and a
-// value <= 0 disables truncation (full bodies captured).
+// value <= 0 disables truncation (full bodies captured). Collector retention
+// is unlimited unless MaxEntriesProperty or MaxBytesProperty is set.
 func DefaultConfig() HARConfig {
 	return HARConfig{
 		MaxBodySize:         int64(properties.Int(defaultMaxBodySize, MaxBodySizeProperty)),
 		CaptureContentTypes: []string{"application/json", "application/x-www-form-urlencoded"},
+		MaxEntries:          properties.Int(0, MaxEntriesProperty),
+		MaxBytes:            int64(properties.Int(0, MaxBytesProperty)),
 	}
 }
 
@@ -53,7 +79,9 @@ type Log struct {
 	Entries []Entry `json:"entries"`
 }
 
-// Page is included for HAR 1.2 spec compliance; hx leaves it empty.
+// Page groups related entries, e.g. one step of a multi-step workflow.
+// Pages are created with Collector.StartPage; entries refer to them by ID
+// through Entry.Pageref.
 type Page struct {
 	StartedDateTime string      `json:"startedDateTime"`
 	ID              string      `json:"id"`
@@ -61,9 +89,12 @@ type Page struct {
 	PageTimings     PageTimings `json:"pageTimings"`
 }
 
-// PageTimings holds page-level timing data (unused by hx, present for spec compliance).
+// PageTimings holds page-level timing data in milliseconds. For collected
+// pages OnLoad runs from the start of the page until its last entry was added.
+// Browsers export fractional values, hence float64.
 type PageTimings struct {
-	OnLoad int `json:"onLoad,omitempty"`
+	OnContentLoad float64 `json:"onContentLoad,omitempty"`
+	OnLoad        float64 `json:"onLoad,omitempty"`
 }
 
 // Creator identifies the application that created the HAR log.
@@ -74,12 +105,27 @@ type Creator struct {
 
 // Entry represents a single HTTP request/response pair.
 type Entry struct {
+	Pageref         string   `json:"pageref,omitempty"`
 	StartedDateTime string   `json:"startedDateTime"`
 	Time            float64  `json:"time"`
 	Request         Request  `json:"request"`
 	Response        Response `json:"response"`
 	Cache           Cache    `json:"cache"`
 	Timings         Timings  `json:"timings"`
+
+	// ServerIPAddress is the IP address of the server that was connected to.
+	ServerIPAddress string `json:"serverIPAddress,omitempty"`
+
+	// Connection identifies the TCP connection (its local address).
+	Connection string `json:"connection,omitempty"`
+
+	// ConnectionReused is true when the request was sent on a pooled
+	// connection, in which case DNS, Connect and SSL are -1.
+	ConnectionReused bool `json:"_connectionReused,omitempty"`
+
+	// ctx is the context of the captured request, used by OTelExporter to
+	// attach the entry to the request's span. Set by Timer.Finish.
+	ctx context.Context
 }
 
 // Cache holds cache information for an entry (required by spec; left empty by hx).
@@ -122,6 +168,7 @@ type Content struct {
 	Size      int64  `json:"size"`
 	MimeType  string `json:"mimeType,omitempty"`
 	Text      string `json:"text,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
 	Truncated bool   `json:"truncated,omitempty"`
 }
 
@@ -144,7 +191,13 @@ type QueryString struct {
 }
 
 // Timings records durations (in milliseconds) for the request lifecycle.
+// Blocked, DNS, Connect and SSL are -1 when the phase does not apply to the
+// request; Connect includes SSL.
 type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
 	Send    float64 `json:"send"`
 	Wait    float64 `json:"wait"`
 	Receive float64 `json:"receive"`
@@ -1,7 +1,10 @@
 package har
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/flanksource/commons/properties"
 )
@@ -34,3 +37,49 @@ func TestDefaultConfig_MaxBodySizeProperty(t *testing.T) {
 		})
 	}
 }
+
+func TestCollector_DoesNotRetainRequestContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "request")
+	c := NewCollector(DefaultConfig())
+	c.Add(&Entry{Request: Request{URL: "https://example.com"}, ctx: ctx})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) != 1 || c.entries[0].ctx != nil {
+		t.Errorf("stored entries must not keep the request context")
+	}
+}
+
+func TestCollector_WritesOutsideLock(t *testing.T) {
+	w, err := NewWriter(filepath.Join(t.TempDir(), "slow.har"), WriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCollector(DefaultConfig()).StreamTo(w)
+
+	// a slow write or rotation holds the writer
+	w.mu.Lock()
+	added := make(chan struct{})
+	go func() {
+		c.Add(&Entry{Request: Request{URL: "https://example.com/1"}})
+		close(added)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		c.StartPage("next")
+		_ = c.Entries()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("collector calls waited for the writer")
+	}
+	w.mu.Unlock()
+	<-added
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,7 +2,6 @@ package har
 
 import (
 	"bytes"
-	"encoding/json"
 	"io"
 	"net/http"
 	"net/url"
@@ -29,23 +28,15 @@ func NewMiddleware(cfg HARConfig, handler func(*Entry)) middlewares.Middleware {
 }
 
 func capture(req *http.Request, next http.RoundTripper, cfg HARConfig, handler func(*Entry)) (*http.Response, error) {
-	started := time.Now()
-
-	entry := &Entry{
-		StartedDateTime: started.UTC().Format(time.RFC3339),
-		Request:         buildRequest(req, cfg),
-	}
-
-	waitStart := time.Now()
-	resp, err := next.RoundTrip(req)
-	waitMs := float64(time.Since(waitStart).Microseconds()) / 1000.0
-
-	entry.Timings = Timings{Wait: waitMs}
-	entry.Time = waitMs
+	entry := &Entry{Request: buildRequest(req, cfg)}
 
+	traced, timer := StartTimer(req)
+	resp, err := next.RoundTrip(traced)
 	if resp != nil {
 		entry.Response = buildResponse(resp, cfg)
 	}
+	// captured bodies have been read by now, so this also covers receive time
+	timer.Finish(entry)
 
 	handler(entry)
 	return resp, err
@@ -54,20 +45,21 @@ func capture(req *http.Request, next http.RoundTripper, cfg HARConfig, handler f
 // CaptureRedirect builds a HAR entry from a redirect hop's request and response.
 func CaptureRedirect(req *http.Request, resp *http.Response, cfg HARConfig) *Entry {
 	return &Entry{
-		StartedDateTime: time.Now().UTC().Format(time.RFC3339),
+		StartedDateTime: time.Now().UTC().Format(startedDateTimeFormat),
 		Request:         buildRequest(req, cfg),
 		Response:        buildResponse(resp, cfg),
+		Timings:         Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
 	}
 }
 
 func buildRequest(req *http.Request, cfg HARConfig) Request {
 	har := Request{
 		Method:      req.Method,
-		URL:         req.URL.String(),
+		URL:         logger.GetRedactionPolicy().RedactURL(req.URL.String()),
 		HTTPVersion: httpVersion(req.Proto),
 		Cookies:     []Cookie{},
 		Headers:     toHARHeaders(logger.SanitizeHeaders(req.Header, cfg.RedactedHeaders...)),
-		QueryString: toQueryString(req.URL.Query()),
+		QueryString: toQueryString(logger.GetRedactionPolicy().RedactQuery(req.URL.Query())),
 		HeadersSize: -1,
 		BodySize:    -1,
 	}
@@ -154,47 +146,7 @@ func shouldCapture(contentType string, allowed []string) bool {
 }
 
 func redactBody(text, contentType string) string {
-	ct := strings.ToLower(strings.Split(contentType, ";")[0])
-	ct = strings.TrimSpace(ct)
-
-	switch ct {
-	case "application/json":
-		return redactJSON(text)
-	case "application/x-www-form-urlencoded":
-		return redactForm(text)
-	default:
-		return text
-	}
-}
-
-func redactJSON(text string) string {
-	var m map[string]any
-	if err := json.Unmarshal([]byte(text), &m); err != nil {
-		return text
-	}
-	redacted := logger.StripSecretsFromMap(m)
-	out, err := json.Marshal(redacted)
-	if err != nil {
-		return text
-	}
-	return string(out)
-}
-
-func redactForm(text string) string {
-	vals, err := url.ParseQuery(text)
-	if err != nil {
-		return text
-	}
-	for k, vs := range vals {
-		if logger.IsSensitiveKey(k) {
-			redacted := make([]string, len(vs))
-			for i, v := range vs {
-				redacted[i] = logger.PrintableSecret(v)
-			}
-			vals[k] = redacted
-		}
-	}
-	return vals.Encode()
+	return logger.GetRedactionPolicy().RedactBody(text, contentType)
 }
 
 func toHARHeaders(h http.Header) []Header {
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/flanksource/commons/har"
 	commonshttp "github.com/flanksource/commons/http"
@@ -223,3 +224,63 @@ func TestHAR_TimingsNonNegative(t *testing.T) {
 		t.Errorf("Timings.Wait should be >= 0, got %f", entry.Timings.Wait)
 	}
 }
+
+func TestHAR_TimingsBreakdown(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	var entries []*har.Entry
+	rt := har.NewMiddleware(har.DefaultConfig(), func(e *har.Entry) { entries = append(entries, e) })(srv.Client().Transport)
+
+	for range 2 {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/", nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	first, second := entries[0], entries[1]
+	if first.ConnectionReused {
+		t.Error("first request should open a new connection")
+	}
+	if first.Timings.Connect < 0 || first.Timings.SSL < 0 {
+		t.Errorf("expected connect and ssl timings on a new TLS connection, got %+v", first.Timings)
+	}
+	if first.Timings.Connect < first.Timings.SSL {
+		t.Errorf("connect (%f) should include ssl (%f)", first.Timings.Connect, first.Timings.SSL)
+	}
+	if first.Timings.DNS != -1 {
+		t.Errorf("expected no DNS lookup for an IP address, got %f", first.Timings.DNS)
+	}
+	if first.ServerIPAddress != "127.0.0.1" {
+		t.Errorf("expected serverIPAddress 127.0.0.1, got %q", first.ServerIPAddress)
+	}
+
+	if !second.ConnectionReused {
+		t.Error("second request should reuse the pooled connection")
+	}
+	if second.Timings.Connect != -1 || second.Timings.SSL != -1 {
+		t.Errorf("expected connect/ssl of -1 on a reused connection, got %+v", second.Timings)
+	}
+	if second.Connection != first.Connection {
+		t.Errorf("expected same connection, got %q and %q", first.Connection, second.Connection)
+	}
+
+	for i, e := range entries {
+		if e.Time != e.Timings.Total() {
+			t.Errorf("entry %d: time %f should equal sum of timings %f", i, e.Time, e.Timings.Total())
+		}
+		if _, err := time.Parse(time.RFC3339Nano, e.StartedDateTime); err != nil {
+			t.Errorf("entry %d: invalid startedDateTime %q: %v", i, e.StartedDateTime, err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package har
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTelEventName is the name of the span events and log records produced by
+// OTelExporter.
+const OTelEventName = "http.client.request"
+
+const otelScope = "github.com/flanksource/commons/har"
+
+// OTelConfig controls how an OTelExporter publishes entries.
+type OTelConfig struct {
+	// SpanEvents adds each entry as an event on the span that was active in
+	// the request's context. Entries captured without a recording span are
+	// not added.
+	SpanEvents bool
+
+	// LoggerProvider, when set, emits each entry as a log record, correlated
+	// with the request's span.
+	LoggerProvider log.LoggerProvider
+
+	// Headers adds http.request.header.<name> and http.response.header.<name>
+	// attributes. Values are taken from the entry, so are already redacted.
+	Headers bool
+
+	// Bodies adds the captured request and response bodies as
+	// har.request.body and har.response.body attributes.
+	Bodies bool
+}
+
+// OTelExporter publishes HAR entries to OpenTelemetry as span events and/or
+// log records, using the HTTP client semantic conventions. Use Export as the
+// handler passed to NewMiddleware, or attach it with Collector.ExportTo so
+// entries are published as they are collected:
+//
+//	collector := har.NewCollector(har.DefaultConfig()).ExportTo(har.NewOTelExporter(har.OTelConfig{
+//		SpanEvents:     true,
+//		LoggerProvider: global.GetLoggerProvider(),
+//	}))
+type OTelExporter struct {
+	config OTelConfig
+	logger log.Logger
+}
+
+// NewOTelExporter returns an exporter for config.
+func NewOTelExporter(config OTelConfig) *OTelExporter {
+	x := &OTelExporter{config: config}
+	if config.LoggerProvider != nil {
+		x.logger = config.LoggerProvider.Logger(otelScope, log.WithSchemaURL(semconv.SchemaURL))
+	}
+	return x
+}
+
+// Export publishes e. The span and trace context are taken from the request
+// the entry was captured from; entries built by hand (or loaded from a file)
+// are only emitted as log records.
+func (x *OTelExporter) Export(e *Entry) {
+	if x == nil || e == nil {
+		return
+	}
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	attrs := x.Attributes(*e)
+
+	if x.config.SpanEvents {
+		if span := trace.SpanFromContext(ctx); span.IsRecording() {
+			opts := []trace.EventOption{trace.WithAttributes(attrs...)}
+			if start := e.StartTime(); !start.IsZero() {
+				opts = append(opts, trace.WithTimestamp(start))
+			}
+			span.AddEvent(OTelEventName, opts...)
+		}
+	}
+
+	if x.logger != nil {
+		x.logger.Emit(ctx, x.record(*e, attrs))
+	}
+}
+
+func (x *OTelExporter) record(e Entry, attrs []attribute.KeyValue) log.Record {
+	var r log.Record
+	r.SetEventName(OTelEventName)
+	r.SetTimestamp(e.StartTime())
+	r.SetObservedTimestamp(time.Now())
+	severity := severityOf(e.Response.Status)
+	r.SetSeverity(severity)
+	r.SetSeverityText(severity.String())
+	r.SetBody(log.StringValue(summary(e)))
+	for _, a := range attrs {
+		r.AddAttributes(log.KeyValueFromAttribute(a))
+	}
+	return r
+}
+
+// Attributes returns e as HTTP client semantic convention attributes, plus
+// har.* attributes for the timing breakdown and page, which have no semantic
+// convention equivalent.
+func (x *OTelExporter) Attributes(e Entry) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(e.Request.Method),
+		semconv.URLFull(e.Request.URL),
+		semconv.NetworkProtocolName("http"),
+	}
+	if u, err := url.Parse(e.Request.URL); err == nil && u.Host != "" {
+		attrs = append(attrs, semconv.URLScheme(u.Scheme), semconv.ServerAddress(u.Hostname()))
+		if port := serverPort(u); port > 0 {
+			attrs = append(attrs, semconv.ServerPort(port))
+		}
+	}
+	if version := protocolVersion(e.Request.HTTPVersion); version != "" {
+		attrs = append(attrs, semconv.NetworkProtocolVersion(version))
+	}
+	if e.ServerIPAddress != "" {
+		attrs = append(attrs, semconv.NetworkPeerAddress(e.ServerIPAddress))
+	}
+	if ua := headerValue(e.Request.Headers, "User-Agent"); ua != "" {
+		attrs = append(attrs, semconv.UserAgentOriginal(ua))
+	}
+	if e.Request.BodySize > 0 {
+		attrs = append(attrs, semconv.HTTPRequestBodySize(int(e.Request.BodySize)))
+	}
+
+	if status := e.Response.Status; status > 0 {
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(status))
+		if status >= 400 {
+			attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(status)))
+		}
+		if e.Response.Content.Size >= 0 {
+			attrs = append(attrs, semconv.HTTPResponseBodySize(int(e.Response.Content.Size)))
+		}
+	} else {
+		// no response: the request failed in the transport
+		attrs = append(attrs, semconv.ErrorTypeOther)
+	}
+
+	if x.config.Headers {
+		request := headerMap(e.Request.Headers)
+		for _, name := range slices.Sorted(maps.Keys(request)) {
+			attrs = append(attrs, semconv.HTTPRequestHeader(name, request[name]...))
+		}
+		response := headerMap(e.Response.Headers)
+		for _, name := range slices.Sorted(maps.Keys(response)) {
+			attrs = append(attrs, semconv.HTTPResponseHeader(name, response[name]...))
+		}
+	}
+	if x.config.Bodies {
+		if e.Request.PostData != nil && e.Request.PostData.Text != "" {
+			attrs = append(attrs, attribute.String("har.request.body", e.Request.PostData.Text))
+		}
+		if e.Response.Content.Text != "" {
+			attrs = append(attrs, attribute.String("har.response.body", e.Response.Content.Text))
+		}
+	}
+
+	if e.Pageref != "" {
+		attrs = append(attrs, attribute.String("har.pageref", e.Pageref))
+	}
+	attrs = append(attrs, attribute.Float64("har.time", e.Time))
+	for _, t := range []struct {
+		name  string
+		value float64
+	}{
+		{"blocked", e.Timings.Blocked},
+		{"dns", e.Timings.DNS},
+		{"connect", e.Timings.Connect},
+		{"ssl", e.Timings.SSL},
+		{"send", e.Timings.Send},
+		{"wait", e.Timings.Wait},
+		{"receive", e.Timings.Receive},
+	} {
+		if t.value > 0 {
+			attrs = append(attrs, attribute.Float64("har.timings."+t.name, t.value))
+		}
+	}
+	return attrs
+}
+
+func severityOf(status int) log.Severity {
+	switch {
+	case status == 0 || status >= 500:
+		return log.SeverityError
+	case status >= 400:
+		return log.SeverityWarn
+	default:
+		return log.SeverityInfo
+	}
+}
+
+func summary(e Entry) string {
+	if e.Response.Status == 0 {
+		return fmt.Sprintf("%s %s failed", e.Request.Method, e.Request.URL)
+	}
+	return fmt.Sprintf("%s %s %d (%.0fms)", e.Request.Method, e.Request.URL, e.Response.Status, e.Time)
+}
+
+func serverPort(u *url.URL) int {
+	if p := u.Port(); p != "" {
+		port, _ := strconv.Atoi(p)
+		return port
+	}
+	switch u.Scheme {
+	case "https":
+		return 443
+	case "http":
+		return 80
+	}
+	return 0
+}
+
+// protocolVersion converts a HAR httpVersion (HTTP/1.1, HTTP/2.0, h3) to the
+// network.protocol.version form (1.1, 2, 3).
+func protocolVersion(v string) string {
+	v = strings.ToLower(v)
+	switch {
+	case strings.HasPrefix(v, "http/"):
+		v = strings.TrimPrefix(v, "http/")
+	case strings.HasPrefix(v, "h"):
+		v = strings.TrimPrefix(v, "h")
+	default:
+		return ""
+	}
+	return strings.TrimSuffix(v, ".0")
+}
+
+func headerValue(headers []Header, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// headerMap groups headers by lower-cased name, as the header attributes require.
+func headerMap(headers []Header) map[string][]string {
+	out := map[string][]string{}
+	for _, h := range headers {
+		name := strings.ToLower(h.Name)
+		out[name] = append(out[name], h.Value)
+	}
+	return out
+}
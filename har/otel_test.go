@@ -0,0 +1,189 @@
+package har_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flanksource/commons/har"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/embedded"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingLogs is an in-memory log.LoggerProvider.
+type recordingLogs struct {
+	embedded.LoggerProvider
+
+	mu      sync.Mutex
+	scope   string
+	records []log.Record
+	spans   []trace.SpanContext
+}
+
+type recordingLogger struct {
+	embedded.Logger
+	*recordingLogs
+}
+
+func (r *recordingLogs) Logger(name string, _ ...log.LoggerOption) log.Logger {
+	r.scope = name
+	return recordingLogger{recordingLogs: r}
+}
+
+func (r *recordingLogs) Emit(ctx context.Context, record log.Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record.Clone())
+	r.spans = append(r.spans, trace.SpanContextFromContext(ctx))
+}
+
+func (recordingLogger) Enabled(context.Context, log.EnabledParameters) bool { return true }
+
+func logAttrs(r log.Record) map[string]string {
+	out := map[string]string{}
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		out[kv.Key] = kv.Value.String()
+		return true
+	})
+	return out
+}
+
+func eventAttrs(attrs []attribute.KeyValue) map[string]string {
+	out := map[string]string{}
+	for _, kv := range attrs {
+		out[string(kv.Key)] = kv.Value.Emit()
+	}
+	return out
+}
+
+func TestOTelExporter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	spans := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	logs := &recordingLogs{}
+
+	collector := har.NewCollector(har.DefaultConfig()).ExportTo(har.NewOTelExporter(har.OTelConfig{
+		SpanEvents:     true,
+		LoggerProvider: logs,
+		Headers:        true,
+		Bodies:         true,
+	}))
+	client := &http.Client{Transport: collector.Middleware()(http.DefaultTransport)}
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "checkout")
+	for _, path := range []string{"/items?limit=2", "/missing"} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("User-Agent", "har-test")
+		req.Header.Set("Authorization", "Bearer abcdefghijklmnop")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	span.End()
+
+	ended := spans.GetSpans()
+	if len(ended) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(ended))
+	}
+	events := ended[0].Events
+	if len(events) != 2 {
+		t.Fatalf("expected 2 span events, got %d", len(events))
+	}
+	for _, e := range events {
+		if e.Name != har.OTelEventName {
+			t.Errorf("unexpected event name %q", e.Name)
+		}
+	}
+
+	first := eventAttrs(events[0].Attributes)
+	port := srv.URL[strings.LastIndex(srv.URL, ":")+1:]
+	for key, want := range map[string]string{
+		"http.request.method":               "GET",
+		"url.full":                          srv.URL + "/items?limit=2",
+		"url.scheme":                        "http",
+		"server.address":                    "127.0.0.1",
+		"server.port":                       port,
+		"network.protocol.version":          "1.1",
+		"network.peer.address":              "127.0.0.1",
+		"user_agent.original":               "har-test",
+		"http.response.status_code":         "200",
+		"http.response.body.size":           "11",
+		"http.response.header.content-type": "[\"application/json\"]",
+		"har.response.body":                 `{"ok":true}`,
+	} {
+		if first[key] != want {
+			t.Errorf("%s = %q, want %q", key, first[key], want)
+		}
+	}
+	if _, ok := first["error.type"]; ok {
+		t.Error("successful request should not have error.type")
+	}
+	if strings.Contains(first["http.request.header.authorization"], "abcdefghijklmnop") {
+		t.Errorf("authorization header leaked: %s", first["http.request.header.authorization"])
+	}
+	if second := eventAttrs(events[1].Attributes); second["error.type"] != "404" {
+		t.Errorf("expected error.type=404, got %q", second["error.type"])
+	}
+
+	if logs.scope != "github.com/flanksource/commons/har" {
+		t.Errorf("unexpected scope %q", logs.scope)
+	}
+	if len(logs.records) != 2 {
+		t.Fatalf("expected 2 log records, got %d", len(logs.records))
+	}
+	for i, want := range []log.Severity{log.SeverityInfo, log.SeverityWarn} {
+		r := logs.records[i]
+		if r.Severity() != want || r.EventName() != har.OTelEventName {
+			t.Errorf("record %d: severity %v event %q", i, r.Severity(), r.EventName())
+		}
+		if logs.spans[i].SpanID() != ended[0].SpanContext.SpanID() {
+			t.Errorf("record %d is not correlated with the request span", i)
+		}
+	}
+	if got := logAttrs(logs.records[1])["http.response.status_code"]; got != "404" {
+		t.Errorf("expected status 404 on log record, got %q", got)
+	}
+	if body := logs.records[0].Body().AsString(); !strings.HasPrefix(body, "GET "+srv.URL+"/items?limit=2 200") {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestOTelExporter_WithoutRequestContext(t *testing.T) {
+	file, err := har.Load("testdata/browser.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := &recordingLogs{}
+	x := har.NewOTelExporter(har.OTelConfig{SpanEvents: true, LoggerProvider: logs})
+	for _, e := range file.Log.Entries {
+		x.Export(&e)
+	}
+
+	if len(logs.records) != len(file.Log.Entries) {
+		t.Fatalf("expected %d records, got %d", len(file.Log.Entries), len(logs.records))
+	}
+	attrs := logAttrs(logs.records[0])
+	if attrs["network.protocol.version"] != "2" || attrs["har.timings.ssl"] != "20.3" || attrs["har.pageref"] == "" {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+	if logs.records[0].Timestamp().IsZero() {
+		t.Error("expected the record timestamp to be the entry start time")
+	}
+}
@@ -55,6 +55,41 @@ func headersToDescriptionList(headers []Header) api.DescriptionList {
 	return api.DescriptionList{Items: items}
 }
 
+// timingsToDescriptionList lists the phases that apply to the entry, followed
+// by the connection it was sent on.
+func timingsToDescriptionList(e Entry) api.DescriptionList {
+	if e.Timings.Total() <= 0 {
+		return api.DescriptionList{}
+	}
+	var items []api.KeyValuePair
+	phases := []struct {
+		name string
+		ms   float64
+	}{
+		{"Blocked", e.Timings.Blocked},
+		{"DNS", e.Timings.DNS},
+		{"Connect", e.Timings.Connect},
+		{"SSL", e.Timings.SSL},
+		{"Send", e.Timings.Send},
+		{"Wait", e.Timings.Wait},
+		{"Receive", e.Timings.Receive},
+	}
+	for _, p := range phases {
+		if p.ms < 0 {
+			continue
+		}
+		items = append(items, api.KeyValuePair{Key: p.name, Value: api.Human(time.Duration(p.ms*float64(time.Millisecond)), "text-muted")})
+	}
+	if e.ServerIPAddress != "" {
+		conn := e.ServerIPAddress
+		if e.ConnectionReused {
+			conn += " (reused)"
+		}
+		items = append(items, api.KeyValuePair{Key: "Server", Value: conn})
+	}
+	return api.DescriptionList{Items: items}
+}
+
 // Columns implements api.TableProvider.
 func (e Entry) Columns() []api.ColumnDef {
 	return []api.ColumnDef{
@@ -74,8 +109,7 @@ func (e Entry) Row() map[string]any {
 		"status": statusText(e.Response),
 	}
 
-	dur := time.Duration(e.Time * float64(time.Millisecond))
-	row["duration"] = api.Human(dur, "text-muted")
+	row["duration"] = api.Human(e.Duration(), "text-muted")
 
 	if e.Response.Content.Size > 0 {
 		row["size"] = api.HumanizeBytes(e.Response.Content.Size).Styles("text-muted")
@@ -126,6 +160,14 @@ func (e Entry) RowDetail() api.Textable {
 			Add(formatBody(e.Response.Content.MimeType, e.Response.Content.Text))
 	}
 
+	if timings := timingsToDescriptionList(e); len(timings.Items) > 0 {
+		if hasContent {
+			t = t.NewLine()
+		}
+		hasContent = true
+		t = t.AddText("Timings", "font-bold text-muted").NewLine().Add(timings)
+	}
+
 	if !hasContent {
 		return nil
 	}
@@ -140,8 +182,7 @@ func (e Entry) Pretty() api.Text {
 		AddText(" "+e.Request.URL, "font-bold").
 		Add(statusText(e.Response).Prefix(" "))
 
-	dur := time.Duration(e.Time * float64(time.Millisecond))
-	t = t.AddText(" ").Add(api.Human(dur, "text-muted"))
+	t = t.AddText(" ").Add(api.Human(e.Duration(), "text-muted"))
 
 	if e.Response.Content.Size > 0 {
 		t = t.AddText(" ").Add(api.HumanizeBytes(e.Response.Content.Size).Styles("text-muted"))
@@ -152,7 +193,7 @@ func (e Entry) Pretty() api.Text {
 
 // Table returns a TextTable of all collected entries with row detail expansion.
 func (c *Collector) Table() api.TextTable {
-	return api.NewTableFrom(c.Entries())
+	return Entries(c.Entries()).Table()
 }
 
 // Pretty returns a compact summary of all collected entries.
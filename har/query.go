@@ -0,0 +1,175 @@
+package har
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/flanksource/clicky/api"
+	"github.com/flanksource/commons/collections"
+)
+
+// Entries is a list of HAR entries with filtering and sorting helpers:
+//
+//	slow := file.Entries().
+//		Filter(Host("*.example.com"), SlowerThan(time.Second)).
+//		SortBy(Descending(ByDuration))
+type Entries []Entry
+
+// Predicate reports whether an entry matches a query.
+type Predicate func(Entry) bool
+
+// Filter returns the entries matching all predicates.
+func (e Entries) Filter(predicates ...Predicate) Entries {
+	var out Entries
+outer:
+	for _, entry := range e {
+		for _, p := range predicates {
+			if !p(entry) {
+				continue outer
+			}
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+// SortBy returns a copy of the entries sorted by compare. The sort is stable,
+// so entries that compare equal keep their original order.
+func (e Entries) SortBy(compare func(a, b Entry) int) Entries {
+	out := slices.Clone(e)
+	slices.SortStableFunc(out, compare)
+	return out
+}
+
+// Dedupe returns the entries with duplicates (same start time, method, URL,
+// status and duration) removed, keeping the first occurrence.
+func (e Entries) Dedupe() Entries {
+	seen := make(map[string]bool, len(e))
+	var out Entries
+	for _, entry := range e {
+		key := fmt.Sprintf("%s %s %s %d %f", entry.StartedDateTime, entry.Request.Method, entry.Request.URL, entry.Response.Status, entry.Time)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, entry)
+	}
+	return out
+}
+
+// Table returns a TextTable of the entries with row detail expansion.
+func (e Entries) Table() api.TextTable {
+	return api.NewTableFrom([]Entry(e))
+}
+
+// Host matches entries whose request host matches any of the patterns, using
+// collections.MatchItems syntax (e.g. "*.example.com", "!localhost").
+func Host(patterns ...string) Predicate {
+	return func(e Entry) bool {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return false
+		}
+		return collections.MatchItems(u.Hostname(), slices.Clone(patterns)...)
+	}
+}
+
+// Method matches entries with any of the given request methods.
+func Method(methods ...string) Predicate {
+	return func(e Entry) bool {
+		return slices.ContainsFunc(methods, func(m string) bool {
+			return strings.EqualFold(m, e.Request.Method)
+		})
+	}
+}
+
+// Status matches entries with a response status in [min, max].
+func Status(min, max int) Predicate {
+	return func(e Entry) bool {
+		return e.Response.Status >= min && e.Response.Status <= max
+	}
+}
+
+// Failed matches entries with an error status (>= 400) or no response at all.
+func Failed() Predicate {
+	return func(e Entry) bool {
+		return e.Response.Status == 0 || e.Response.Status >= 400
+	}
+}
+
+// SlowerThan matches entries that took at least d.
+func SlowerThan(d time.Duration) Predicate {
+	return func(e Entry) bool {
+		return e.Duration() >= d
+	}
+}
+
+// FasterThan matches entries that took less than d.
+func FasterThan(d time.Duration) Predicate {
+	return func(e Entry) bool {
+		return e.Duration() < d
+	}
+}
+
+// MimeType matches entries whose response content type (without parameters)
+// matches any of the patterns, e.g. "application/json" or "image/*".
+func MimeType(patterns ...string) Predicate {
+	return func(e Entry) bool {
+		mime := strings.TrimSpace(strings.Split(e.Response.Content.MimeType, ";")[0])
+		return mime != "" && collections.MatchItems(mime, slices.Clone(patterns)...)
+	}
+}
+
+// InPage matches entries belonging to the page with the given ID.
+func InPage(id string) Predicate {
+	return func(e Entry) bool {
+		return e.Pageref == id
+	}
+}
+
+// Duration returns the total time of the entry.
+func (e Entry) Duration() time.Duration {
+	return time.Duration(e.Time * float64(time.Millisecond))
+}
+
+// StartTime parses StartedDateTime, returning the zero time if it is invalid.
+func (e Entry) StartTime() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, e.StartedDateTime)
+	return t
+}
+
+// ByStartTime orders entries by when they started.
+func ByStartTime(a, b Entry) int {
+	return a.StartTime().Compare(b.StartTime())
+}
+
+// ByDuration orders entries by total time.
+func ByDuration(a, b Entry) int {
+	return cmp.Compare(a.Time, b.Time)
+}
+
+// ByStatus orders entries by response status.
+func ByStatus(a, b Entry) int {
+	return cmp.Compare(a.Response.Status, b.Response.Status)
+}
+
+// BySize orders entries by response content size.
+func BySize(a, b Entry) int {
+	return cmp.Compare(a.Response.Content.Size, b.Response.Content.Size)
+}
+
+// ByURL orders entries by request URL.
+func ByURL(a, b Entry) int {
+	return cmp.Compare(a.Request.URL, b.Request.URL)
+}
+
+// Descending reverses an ordering.
+func Descending(compare func(a, b Entry) int) func(a, b Entry) int {
+	return func(a, b Entry) int {
+		return compare(b, a)
+	}
+}
@@ -0,0 +1,130 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+// Parse decodes a HAR document. Both plain HAR 1.2 files (e.g. browser
+// exports) and files with the extended fields written by this package are
+// accepted; unknown fields are ignored. Files streamed by a Writer that was
+// never closed are read up to the last complete entry.
+func Parse(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// some browsers prefix exports with a UTF-8 BOM
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		repaired, ok := repairStream(data)
+		if !ok || json.Unmarshal(repaired, &file) != nil {
+			return nil, fmt.Errorf("invalid HAR: %w", err)
+		}
+	}
+	if file.Log.Version == "" && file.Log.Entries == nil {
+		return nil, fmt.Errorf("invalid HAR: missing log")
+	}
+	if file.Log.Pages == nil {
+		file.Log.Pages = []Page{}
+	}
+	if file.Log.Entries == nil {
+		file.Log.Entries = []Entry{}
+	}
+	return &file, nil
+}
+
+// repairStream closes a file left unfinished by a Writer whose process
+// exited without calling Close. Writers emit whole entries, so the file ends
+// after the header or a complete entry.
+func repairStream(data []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(data, []byte(`{"log":{"version":"1.2","creator":`)) {
+		return nil, false
+	}
+	data = bytes.TrimRight(data, " \t\r\n,")
+	return append(slices.Clip(data), "\n],\"pages\":[]}}"...), true
+}
+
+// Load reads and parses the HAR file at path, e.g. one written by hx --har
+// or http.WriteHARFile.
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	file, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Entries returns the file's entries for querying.
+func (f *File) Entries() Entries {
+	return Entries(f.Log.Entries)
+}
+
+// Collector returns a Collector holding the file's pages and entries, so
+// loaded files can be rendered with Collector.Table and Collector.Pretty.
+// Entries added later are grouped under the file's last page.
+func (f *File) Collector() *Collector {
+	c := NewCollector(DefaultConfig())
+	c.pages = slices.Clone(f.Log.Pages)
+	c.pageStarts = make([]time.Time, len(c.pages))
+	for i, p := range c.pages {
+		c.pageStarts[i], _ = time.Parse(time.RFC3339Nano, p.StartedDateTime)
+	}
+	c.entries = slices.Clone(f.Log.Entries)
+	c.sizes = make([]int64, len(c.entries))
+	return c
+}
+
+// Merge combines several HAR files into one. Pages whose IDs clash with a
+// page from an earlier file are renamed (and their entries re-pointed),
+// duplicate entries are dropped and entries are ordered by start time.
+func Merge(files ...*File) *File {
+	merged := &File{Log: Log{Version: "1.2", Pages: []Page{}}}
+	seen := map[string]bool{}
+	var entries Entries
+
+	for i, f := range files {
+		if f == nil {
+			continue
+		}
+		if merged.Log.Creator.Name == "" {
+			merged.Log.Creator = f.Log.Creator
+		}
+
+		renamed := map[string]string{}
+		for _, p := range f.Log.Pages {
+			if seen[p.ID] {
+				id := fmt.Sprintf("%s_%d", p.ID, i+1)
+				renamed[p.ID] = id
+				p.ID = id
+			}
+			seen[p.ID] = true
+			merged.Log.Pages = append(merged.Log.Pages, p)
+		}
+		for _, e := range f.Log.Entries {
+			if id, ok := renamed[e.Pageref]; ok {
+				e.Pageref = id
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	merged.Log.Entries = entries.Dedupe().SortBy(ByStartTime)
+	if merged.Log.Entries == nil {
+		merged.Log.Entries = []Entry{}
+	}
+	return merged
+}
@@ -0,0 +1,162 @@
+package har_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/commons/har"
+	commonshttp "github.com/flanksource/commons/http"
+)
+
+func urls(entries har.Entries) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.Request.URL
+	}
+	return out
+}
+
+func TestLoad_BrowserExport(t *testing.T) {
+	file, err := har.Load("testdata/browser.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Log.Creator.Name != "WebInspector" {
+		t.Errorf("unexpected creator %q", file.Log.Creator.Name)
+	}
+	if len(file.Log.Pages) != 1 || file.Log.Pages[0].PageTimings.OnLoad != 980.1 {
+		t.Errorf("unexpected pages: %+v", file.Log.Pages)
+	}
+	if len(file.Log.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(file.Log.Entries))
+	}
+
+	first := file.Log.Entries[0]
+	if first.Timings.SSL != 20.3 || first.Timings.Connect != 40.2 || first.ServerIPAddress != "203.0.113.10" {
+		t.Errorf("timings/connection not parsed: %+v %q", first.Timings, first.ServerIPAddress)
+	}
+	if logo := file.Log.Entries[2]; logo.Response.Content.Encoding != "base64" {
+		t.Errorf("expected base64 encoding, got %q", logo.Response.Content.Encoding)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, input := range []string{"", "not json", `{"foo": 1}`} {
+		if _, err := har.Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestEntries_Query(t *testing.T) {
+	file, err := har.Load("testdata/browser.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := file.Entries()
+
+	cases := []struct {
+		name  string
+		query har.Entries
+		want  []string
+	}{
+		{"host glob", entries.Filter(har.Host("*.example.com")), []string{"https://shop.example.com/", "https://api.example.com/v1/products?limit=20"}},
+		{"host exclusion", entries.Filter(har.Host("!cdn.*")), []string{"https://shop.example.com/", "https://api.example.com/v1/products?limit=20"}},
+		{"status range", entries.Filter(har.Status(200, 299)), []string{"https://shop.example.com/", "https://cdn.example.net/logo.png"}},
+		{"failed", entries.Filter(har.Failed()), []string{"https://api.example.com/v1/products?limit=20"}},
+		{"slower than", entries.Filter(har.SlowerThan(100 * time.Millisecond)), []string{"https://shop.example.com/", "https://api.example.com/v1/products?limit=20"}},
+		{"faster than", entries.Filter(har.FasterThan(100 * time.Millisecond)), []string{"https://cdn.example.net/logo.png"}},
+		{"mime type", entries.Filter(har.MimeType("image/*", "application/json")), []string{"https://api.example.com/v1/products?limit=20", "https://cdn.example.net/logo.png"}},
+		{"combined", entries.Filter(har.Host("*.example.com"), har.Status(200, 299)), []string{"https://shop.example.com/"}},
+		{"by start time", entries.SortBy(har.ByStartTime), []string{"https://shop.example.com/", "https://cdn.example.net/logo.png", "https://api.example.com/v1/products?limit=20"}},
+		{"slowest first", entries.SortBy(har.Descending(har.ByDuration)), []string{"https://api.example.com/v1/products?limit=20", "https://shop.example.com/", "https://cdn.example.net/logo.png"}},
+		{"by size", entries.SortBy(har.BySize), []string{"https://api.example.com/v1/products?limit=20", "https://cdn.example.net/logo.png", "https://shop.example.com/"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := urls(tc.query)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if urls(entries)[0] != "https://shop.example.com/" {
+		t.Error("SortBy must not reorder the receiver")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, err := har.Load("testdata/browser.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := har.Load("testdata/browser.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra := b.Log.Entries[0]
+	extra.StartedDateTime = "2026-03-01T09:59:59.000Z"
+	extra.Request.URL = "https://shop.example.com/earlier"
+	b.Log.Entries = append(b.Log.Entries, extra)
+
+	merged := har.Merge(a, b)
+
+	if len(merged.Log.Pages) != 2 || merged.Log.Pages[0].ID == merged.Log.Pages[1].ID {
+		t.Fatalf("expected clashing page IDs to be renamed, got %+v", merged.Log.Pages)
+	}
+	if len(merged.Log.Entries) != 4 {
+		t.Fatalf("expected duplicates to be dropped leaving 4 entries, got %d", len(merged.Log.Entries))
+	}
+	if merged.Log.Entries[0].Request.URL != "https://shop.example.com/earlier" {
+		t.Errorf("expected entries sorted by start time, got %v", urls(merged.Log.Entries))
+	}
+	if merged.Log.Entries[0].Pageref != merged.Log.Pages[1].ID {
+		t.Errorf("expected entry from the second file to point at the renamed page, got %q", merged.Log.Entries[0].Pageref)
+	}
+}
+
+func TestLoad_RoundTripsWriteHARFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	collector := har.NewCollector(har.DefaultConfig())
+	client := commonshttp.NewClient().HARCollector(collector)
+	collector.StartPage("step 1")
+	for _, path := range []string{"/a", "/b"} {
+		resp, err := client.R(context.Background()).Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	path := filepath.Join(t.TempDir(), "out.har")
+	if err := commonshttp.WriteHARFile(collector, path); err != nil {
+		t.Fatal(err)
+	}
+	file, err := har.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(file.Log.Pages) != 1 || len(file.Log.Entries) != 2 {
+		t.Fatalf("expected 1 page and 2 entries, got %d and %d", len(file.Log.Pages), len(file.Log.Entries))
+	}
+
+	table := file.Collector().Table().String()
+	for _, want := range []string{srv.URL + "/a", srv.URL + "/b", "200"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("table missing %q:\n%s", want, table)
+		}
+	}
+}
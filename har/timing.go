@@ -0,0 +1,170 @@
+package har
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// startedDateTimeFormat is ISO 8601 with millisecond precision, so HAR
+// viewers can lay entries out on a waterfall.
+const startedDateTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Timer records the phases of a single round trip using net/http/httptrace.
+// Create one with StartTimer, send the returned request, then call Finish
+// once the response (and any captured body) has been read.
+type Timer struct {
+	mu  sync.Mutex
+	ctx context.Context
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+
+	reused     bool
+	remoteAddr string
+	localAddr  string
+}
+
+// StartTimer returns a copy of req whose context carries an httptrace.ClientTrace
+// feeding the returned Timer. Any ClientTrace already on the context still fires.
+func StartTimer(req *http.Request) (*http.Request, *Timer) {
+	t := &Timer{start: time.Now(), ctx: req.Context()}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			// happy eyeballs may dial several addresses; keep the first
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone:       func(string, string, error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = time.Now()
+			t.reused = info.Reused
+			if info.Conn != nil {
+				t.remoteAddr = addrString(info.Conn.RemoteAddr())
+				t.localAddr = addrString(info.Conn.LocalAddr())
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), t
+}
+
+func (t *Timer) mark(field *time.Time) {
+	t.mu.Lock()
+	*field = time.Now()
+	t.mu.Unlock()
+}
+
+// Finish fills in the entry's StartedDateTime, Time, Timings and connection
+// details. Phases that did not happen (e.g. DNS and connect on a reused
+// connection) are reported as -1, as the HAR spec requires.
+func (t *Timer) Finish(e *Entry) {
+	end := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e.ctx = t.ctx
+	e.StartedDateTime = t.start.UTC().Format(startedDateTimeFormat)
+	e.Timings = t.timings(end)
+	e.Time = e.Timings.Total()
+	e.ConnectionReused = t.reused
+	e.Connection = t.localAddr
+	if host, _, err := net.SplitHostPort(t.remoteAddr); err == nil {
+		e.ServerIPAddress = host
+	}
+}
+
+func (t *Timer) timings(end time.Time) Timings {
+	timings := Timings{
+		Blocked: -1,
+		DNS:     span(t.dnsStart, t.dnsDone),
+		Connect: -1,
+		SSL:     span(t.tlsStart, t.tlsDone),
+	}
+	// connect includes the TLS handshake
+	if connected := latest(t.connectDone, t.tlsDone); !t.connectStart.IsZero() && !connected.IsZero() {
+		timings.Connect = ms(connected.Sub(t.connectStart))
+	}
+
+	if t.gotConn.IsZero() {
+		// the transport failed before a connection was available
+		timings.Wait = ms(end.Sub(t.start))
+		return timings
+	}
+
+	setup := max(timings.DNS, 0) + max(timings.Connect, 0)
+	if blocked := ms(t.gotConn.Sub(t.start)) - setup; blocked > 0 {
+		timings.Blocked = blocked
+	}
+
+	sent := latest(t.wroteRequest, t.gotConn)
+	timings.Send = ms(sent.Sub(t.gotConn))
+	if t.firstByte.IsZero() {
+		timings.Wait = ms(end.Sub(sent))
+		return timings
+	}
+	timings.Wait = ms(t.firstByte.Sub(sent))
+	timings.Receive = ms(end.Sub(t.firstByte))
+	return timings
+}
+
+// Total returns the sum of all applicable phases, which the HAR spec defines
+// as the entry's Time.
+func (t Timings) Total() float64 {
+	total := t.Send + t.Wait + t.Receive
+	for _, phase := range []float64{t.Blocked, t.DNS, t.Connect} {
+		if phase > 0 {
+			total += phase
+		}
+	}
+	// SSL is already included in Connect
+	return total
+}
+
+func span(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return -1
+	}
+	return ms(end.Sub(start))
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
@@ -0,0 +1,187 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/internal/rotation"
+)
+
+// WriterOptions controls rotation of a streaming HAR Writer.
+type WriterOptions struct {
+	// MaxSize rotates the file once it grows beyond this many bytes. 0 disables.
+	MaxSize int64
+
+	// MaxAge rotates the file once it has been open this long. The check runs
+	// when an entry is written, so an idle file is not rotated. 0 disables.
+	MaxAge time.Duration
+
+	// MaxBackups is the number of rotated files to keep, oldest removed first.
+	// 0 keeps all of them.
+	MaxBackups int
+
+	// Creator is recorded in each file. Default: flanksource-commons.
+	Creator Creator
+}
+
+// Writer streams entries into a HAR file as they are captured, instead of
+// holding them in memory until exit. Each entry is written with a single
+// write, so a file left behind by a crashed process ends on an entry boundary
+// and can still be read with Load. Close must be called to finish the file.
+//
+// When rotation is enabled the active file is always at the original path;
+// rotated files are renamed to <name>-<timestamp>.har alongside it, with
+// unique names even when rotations happen within the same millisecond.
+type Writer struct {
+	opts WriterOptions
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	opened  time.Time
+	size    int64
+	entries int
+	closed  bool
+
+	// pagesMu protects pages, so AddPage does not wait for writes
+	pagesMu sync.Mutex
+	pages   []Page
+}
+
+// NewWriter creates (or truncates) the HAR file at path and writes its header.
+func NewWriter(path string, opts WriterOptions) (*Writer, error) {
+	if opts.Creator.Name == "" {
+		opts.Creator = Creator{Name: "flanksource-commons", Version: "0"}
+	}
+	w := &Writer{opts: opts, path: path}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Path returns the path of the active file.
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write appends an entry to the file, rotating it first if a limit was reached.
+func (w *Writer) Write(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return w.writeRaw(data)
+}
+
+func (w *Writer) writeRaw(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.shouldRotate(int64(len(data))) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	sep := ",\n  "
+	if w.entries == 0 {
+		sep = "\n  "
+	}
+	n, err := w.file.Write(append([]byte(sep), data...))
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	w.entries++
+	return nil
+}
+
+// AddPage records a page, replacing an earlier page with the same ID. Pages
+// are written out when the file is closed or rotated.
+func (w *Writer) AddPage(p Page) {
+	w.pagesMu.Lock()
+	defer w.pagesMu.Unlock()
+	for i := range w.pages {
+		if w.pages[i].ID == p.ID {
+			w.pages[i] = p
+			return
+		}
+	}
+	w.pages = append(w.pages, p)
+}
+
+// Close finishes the active file. It is safe to call more than once.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.finish()
+}
+
+func (w *Writer) shouldRotate(next int64) bool {
+	if w.entries == 0 {
+		return false
+	}
+	if w.opts.MaxSize > 0 && w.size+next > w.opts.MaxSize {
+		return true
+	}
+	return w.opts.MaxAge > 0 && time.Since(w.opened) >= w.opts.MaxAge
+}
+
+func (w *Writer) open() error {
+	f, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	creator, _ := json.Marshal(w.opts.Creator)
+	header := fmt.Sprintf(`{"log":{"version":"1.2","creator":%s,"entries":[`, creator)
+	if _, err := f.WriteString(header); err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.file, w.opened, w.size, w.entries = f, time.Now(), int64(len(header)), 0
+	return nil
+}
+
+// finish writes the pages and closing brackets and closes the active file.
+func (w *Writer) finish() error {
+	w.pagesMu.Lock()
+	pages := w.pages
+	if pages == nil {
+		pages = []Page{}
+	}
+	data, err := json.Marshal(pages)
+	w.pagesMu.Unlock()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w.file, "\n],\"pages\":%s}}\n", data)
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (w *Writer) rotate() error {
+	if err := w.finish(); err != nil {
+		return err
+	}
+	if _, err := rotation.Rename(w.path, w.opened); err != nil {
+		return err
+	}
+	if w.opts.MaxBackups > 0 {
+		if err := rotation.Prune(w.path, w.opts.MaxBackups); err != nil {
+			return err
+		}
+	}
+	return w.open()
+}
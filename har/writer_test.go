@@ -0,0 +1,246 @@
+package har_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/commons/har"
+)
+
+func entry(i int) *har.Entry {
+	return &har.Entry{
+		StartedDateTime: time.Date(2026, 3, 1, 10, 0, 0, i*int(time.Millisecond), time.UTC).Format(time.RFC3339Nano),
+		Time:            float64(i),
+		Request:         har.Request{Method: "GET", URL: fmt.Sprintf("https://example.com/%d", i)},
+		Response:        har.Response{Status: 200, Content: har.Content{Text: strings.Repeat("x", 100)}},
+	}
+}
+
+func TestCollector_MaxEntriesEvictsOldest(t *testing.T) {
+	cfg := har.DefaultConfig()
+	cfg.MaxEntries = 3
+	collector := har.NewCollector(cfg)
+
+	for i := range 10 {
+		collector.Add(entry(i))
+	}
+
+	got := urls(collector.Entries())
+	want := []string{"https://example.com/7", "https://example.com/8", "https://example.com/9"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if collector.Dropped() != 7 {
+		t.Errorf("expected 7 dropped, got %d", collector.Dropped())
+	}
+}
+
+func TestCollector_MaxBytesEvictsOldest(t *testing.T) {
+	cfg := har.DefaultConfig()
+	cfg.MaxBytes = 1000
+	collector := har.NewCollector(cfg)
+
+	for i := range 50 {
+		collector.Add(entry(i))
+	}
+
+	entries := collector.Entries()
+	if len(entries) == 0 || len(entries) >= 50 {
+		t.Fatalf("expected byte limit to retain some but not all entries, got %d", len(entries))
+	}
+	if last := entries[len(entries)-1].Request.URL; last != "https://example.com/49" {
+		t.Errorf("newest entry must be retained, got %s", last)
+	}
+	if int(collector.Dropped())+len(entries) != 50 {
+		t.Errorf("dropped (%d) + retained (%d) should be 50", collector.Dropped(), len(entries))
+	}
+
+	// a single entry larger than the limit is still kept
+	cfg.MaxBytes = 10
+	small := har.NewCollector(cfg)
+	small.Add(entry(1))
+	if len(small.Entries()) != 1 {
+		t.Error("expected the newest entry to be kept even when over the byte limit")
+	}
+}
+
+func TestCollector_StreamTo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.har")
+	w, err := har.NewWriter(path, har.WriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := har.DefaultConfig()
+	cfg.MaxEntries = 2
+	collector := har.NewCollector(cfg).StreamTo(w)
+	page := collector.StartPage("checkout")
+	for i := range 5 {
+		collector.Add(entry(i))
+	}
+	if err := collector.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := collector.Close(); err != nil {
+		t.Fatalf("second Close should be a no-op, got %v", err)
+	}
+
+	if len(collector.Entries()) != 2 {
+		t.Errorf("expected 2 entries in memory, got %d", len(collector.Entries()))
+	}
+	file, err := har.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Log.Entries) != 5 {
+		t.Errorf("expected all 5 entries on disk, got %d", len(file.Log.Entries))
+	}
+	if len(file.Log.Pages) != 1 || file.Log.Pages[0].ID != page {
+		t.Errorf("expected page %s on disk, got %+v", page, file.Log.Pages)
+	}
+	for _, e := range file.Log.Entries {
+		if e.Pageref != page {
+			t.Errorf("expected pageref %s, got %q", page, e.Pageref)
+		}
+	}
+}
+
+func TestWriter_UnclosedFileIsReadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crash.har")
+	w, err := har.NewWriter(path, har.WriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file, err := har.Load(path); err != nil || len(file.Log.Entries) != 0 {
+		t.Fatalf("expected empty file to load, got %v, %v", file, err)
+	}
+
+	for i := range 3 {
+		if err := w.Write(entry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// simulate a crash: no Close
+	file, err := har.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Log.Entries) != 3 {
+		t.Errorf("expected 3 entries, got %d", len(file.Log.Entries))
+	}
+	_ = w.Close()
+}
+
+func TestWriter_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rotate.har")
+	// not a backup of rotate.har, must survive MaxBackups
+	sibling := filepath.Join(dir, "rotate-notes.har")
+	if err := os.WriteFile(sibling, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := har.NewWriter(path, har.WriterOptions{MaxSize: 1024, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 30 {
+		if err := w.Write(entry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "rotate-2*.har"))
+	if len(backups) != 2 {
+		t.Fatalf("expected MaxBackups=2 rotated files, got %v", backups)
+	}
+	if _, err := os.Stat(sibling); err != nil {
+		t.Errorf("%s was removed as a backup: %v", sibling, err)
+	}
+
+	var last string
+	for _, p := range append(backups, path) {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := har.Load(p)
+		if err != nil {
+			t.Fatalf("rotated file %s is not valid HAR: %v", p, err)
+		}
+		if len(file.Log.Entries) == 0 {
+			t.Errorf("%s has no entries", p)
+		}
+		if info.Size() > 1024+512 {
+			t.Errorf("%s is %d bytes, expected about 1024", p, info.Size())
+		}
+		last = file.Log.Entries[len(file.Log.Entries)-1].Request.URL
+	}
+	if last != "https://example.com/29" {
+		t.Errorf("expected the active file to end with the newest entry, got %s", last)
+	}
+}
+
+func TestWriter_RotationKeepsEveryEntry(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "all.har")
+	w, err := har.NewWriter(path, har.WriterOptions{MaxSize: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rotations within the same millisecond must not overwrite each other
+	for i := range 50 {
+		if err := w.Write(entry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "all-*.har"))
+	total := 0
+	for _, p := range append(backups, path) {
+		file, err := har.Load(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(file.Log.Entries)
+	}
+	if total != 50 {
+		t.Errorf("expected 50 entries across %d files, got %d", len(backups)+1, total)
+	}
+}
+
+func TestWriter_RotatesByAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "age.har")
+	w, err := har.NewWriter(path, har.WriterOptions{MaxAge: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Write(entry(1))
+	_ = w.Write(entry(2))
+	time.Sleep(30 * time.Millisecond)
+	_ = w.Write(entry(3))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "age-*.har"))
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 rotated file, got %v", backups)
+	}
+	rotated, _ := har.Load(backups[0])
+	current, _ := har.Load(path)
+	if len(rotated.Log.Entries) != 2 || len(current.Log.Entries) != 1 {
+		t.Errorf("expected 2 rotated and 1 current entries, got %d and %d", len(rotated.Log.Entries), len(current.Log.Entries))
+	}
+}
@@ -821,7 +821,10 @@ type CommonsHTTPContext interface {
 //     middleware (HARMetadata).
 //
 // WithContext does NOT register any lifecycle hook — the context owns
-// flushing (e.g. via context.AfterFunc on cancellation).
+// flushing (e.g. via context.AfterFunc on cancellation). For long-running
+// processes, HARFor should return a collector bounded with
+// HARConfig.MaxEntries/MaxBytes and streaming to path via
+// har.Collector.StreamTo, so capture can stay on without growing memory.
 func (c *Client) WithContext(ctx CommonsHTTPContext, feature string) *Client {
 	c = c.WithLogger(ctx.GetLogger())
 	if cfg, ok := ctx.HTTPTraceConfig(feature); ok {
@@ -848,27 +851,22 @@ func (c *Client) WithContext(ctx CommonsHTTPContext, feature string) *Client {
 func metadataHARMiddleware(collector *har.Collector) middlewares.Middleware {
 	return func(next http.RoundTripper) http.RoundTripper {
 		return middlewares.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
-			started := time.Now()
 			entry := &har.Entry{
-				StartedDateTime: started.UTC().Format(time.RFC3339),
 				Request: har.Request{
 					Method:      req.Method,
-					URL:         req.URL.String(),
+					URL:         logger.GetRedactionPolicy().RedactURL(req.URL.String()),
 					HTTPVersion: harHTTPVersion(req.Proto),
 					Cookies:     []har.Cookie{},
 					Headers:     toHARHeaders(logger.SanitizeHeaders(req.Header)),
-					QueryString: toHARQueryString(req.URL.Query()),
+					QueryString: toHARQueryString(logger.GetRedactionPolicy().RedactQuery(req.URL.Query())),
 					HeadersSize: -1,
 					BodySize:    -1,
 				},
 			}
 
-			waitStart := time.Now()
-			resp, err := next.RoundTrip(req)
-			waitMs := float64(time.Since(waitStart).Microseconds()) / 1000.0
-
-			entry.Timings = har.Timings{Wait: waitMs}
-			entry.Time = waitMs
+			traced, timer := har.StartTimer(req)
+			resp, err := next.RoundTrip(traced)
+			timer.Finish(entry)
 			if resp != nil {
 				entry.Response = har.Response{
 					Status:      resp.StatusCode,
@@ -926,12 +924,19 @@ func harHTTPVersion(proto string) string {
 // WriteHARFile serializes collector.Entries() into a HAR 1.2 file at
 // path. Designed for use from a context.AfterFunc hook owned by the
 // caller — commons/http does not register any lifecycle itself.
+//
+// If the collector is already streaming to path (see har.Collector.StreamTo)
+// the stream is closed instead, so the file keeps every entry rather than
+// just those still retained in memory.
 func WriteHARFile(collector *har.Collector, path string) error {
+	if w := collector.Streaming(); w != nil && w.Path() == path {
+		return collector.Close()
+	}
 	file := har.File{
 		Log: har.Log{
 			Version: "1.2",
 			Creator: har.Creator{Name: "flanksource-commons", Version: "0"},
-			Pages:   []har.Page{},
+			Pages:   collector.Pages(),
 			Entries: collector.Entries(),
 		},
 	}
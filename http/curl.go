@@ -19,24 +19,44 @@ func escapeSingleQuote(s string) string {
 // All headers including Authorization are included unredacted so the
 // command can be copy-pasted for debugging.
 func ToCurl(req *http.Request) string {
+	return toCurl(req, nil)
+}
+
+// ToRedactedCurl is like ToCurl, but masks secrets in the URL, headers and
+// body according to logger.GetRedactionPolicy, so the command is safe to log.
+func ToRedactedCurl(req *http.Request) string {
+	return toCurl(req, logger.GetRedactionPolicy())
+}
+
+// toCurl renders req as a curl command, masking secrets with policy unless it is nil.
+func toCurl(req *http.Request, policy *logger.RedactionPolicy) string {
+	headers, uri := req.Header, req.URL.String()
+	if policy != nil {
+		headers, uri = policy.RedactHeaders(req.Header), policy.RedactURL(uri)
+	}
+
 	var b strings.Builder
-	fmt.Fprintf(&b, "curl -X %s '%s'", req.Method, escapeSingleQuote(req.URL.String()))
+	fmt.Fprintf(&b, "curl -X %s '%s'", req.Method, escapeSingleQuote(uri))
 
-	keys := make([]string, 0, len(req.Header))
-	for k := range req.Header {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
 	for _, k := range keys {
-		fmt.Fprintf(&b, " -H '%s: %s'", escapeSingleQuote(k), escapeSingleQuote(strings.Join(req.Header[k], ", ")))
+		fmt.Fprintf(&b, " -H '%s: %s'", escapeSingleQuote(k), escapeSingleQuote(strings.Join(headers[k], ", ")))
 	}
 
 	if req.Body != nil && req.Body != http.NoBody {
 		body, err := io.ReadAll(req.Body)
 		if err == nil && len(body) > 0 {
 			req.Body = io.NopCloser(bytes.NewReader(body))
-			fmt.Fprintf(&b, " --data '%s'", escapeSingleQuote(string(body)))
+			data := string(body)
+			if policy != nil {
+				data = policy.RedactBody(data, req.Header.Get("Content-Type"))
+			}
+			fmt.Fprintf(&b, " --data '%s'", escapeSingleQuote(data))
 		} else {
 			req.Body = io.NopCloser(bytes.NewReader(body))
 		}
@@ -50,6 +70,6 @@ type curlLogTransport struct {
 }
 
 func (t *curlLogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	logger.Tracef(ToCurl(req))
+	logger.Tracef("%s", ToRedactedCurl(req))
 	return t.base.RoundTrip(req)
 }
@@ -20,49 +20,6 @@ import (
 	"github.com/flanksource/commons/properties"
 )
 
-type jsonFormatter struct{}
-
-func (j *jsonFormatter) Match(mediatype string) bool {
-	return strings.Contains(mediatype, "json")
-}
-
-func (j *jsonFormatter) Format(w io.Writer, src []byte) error {
-	var m map[string]any
-	if err := json.Unmarshal(src, &m); err != nil {
-		return err
-	}
-	sanitized := logger.StripSecretsFromMap(m)
-	b, err := json.MarshalIndent(sanitized, "", "    ")
-	if err != nil {
-		return err
-	}
-	fmt.Fprint(w, api.CodeBlock("json", string(b)).ANSI())
-	return nil
-}
-
-type formURLEncodedFormatter struct{}
-
-func (f *formURLEncodedFormatter) Match(mediatype string) bool {
-	return mediatype == "application/x-www-form-urlencoded"
-}
-
-func (f *formURLEncodedFormatter) Format(w io.Writer, src []byte) error {
-	values, err := url.ParseQuery(string(src))
-	if err != nil {
-		return err
-	}
-	m := make(map[string]string)
-	for k, v := range values {
-		joined := strings.Join(v, ",")
-		if logger.IsSensitiveKey(k) {
-			joined = logger.PrintableSecret(joined)
-		}
-		m[k] = joined
-	}
-	fmt.Fprint(w, clicky.Map(m).ANSI())
-	return nil
-}
-
 func getLogger(req *http.Request) logger.Logger {
 	if req == nil || req.Context() == nil {
 		return logger.GetLogger()
@@ -91,23 +48,19 @@ func readBody(body io.ReadCloser) (string, io.ReadCloser) {
 }
 
 func sanitizeBody(body string) any {
+	policy := logger.GetRedactionPolicy()
 	var m map[string]any
 	if err := json.Unmarshal([]byte(body), &m); err == nil {
-		return logger.StripSecretsFromMap(m)
+		return policy.RedactMap(m)
 	}
 	if values, err := url.ParseQuery(body); err == nil && len(values) > 0 {
 		sanitized := make(map[string]string, len(values))
-		for k := range values {
-			v := values.Get(k)
-			if logger.IsSensitiveKey(k) {
-				sanitized[k] = logger.PrintableSecret(v)
-			} else {
-				sanitized[k] = v
-			}
+		for k, v := range policy.RedactValues(values) {
+			sanitized[k] = v[0]
 		}
 		return sanitized
 	}
-	return body
+	return policy.RedactText(body)
 }
 
 func formParams(req *http.Request) (url.Values, bool) {
@@ -129,12 +82,8 @@ func formParams(req *http.Request) (url.Values, bool) {
 
 func valueMap(values url.Values) map[string]string {
 	m := make(map[string]string, len(values))
-	for key, vals := range values {
-		joined := strings.Join(vals, ",")
-		if logger.IsSensitiveKey(key) {
-			joined = logger.PrintableSecret(joined)
-		}
-		m[key] = joined
+	for key, vals := range logger.GetRedactionPolicy().RedactValues(values) {
+		m[key] = strings.Join(vals, ",")
 	}
 	return m
 }
@@ -153,7 +102,13 @@ func accessURL(req *http.Request) string {
 	u := *req.URL
 	u.RawQuery = ""
 	u.Fragment = ""
-	return u.String()
+	return logger.GetRedactionPolicy().RedactURL(u.String())
+}
+
+// redactedURL returns the request URL with credentials and sensitive query
+// parameters masked.
+func redactedURL(req *http.Request) string {
+	return logger.GetRedactionPolicy().RedactURL(req.URL.String())
 }
 
 func hasDetailedTrace(config TraceConfig) bool {
@@ -247,7 +202,7 @@ func jsonLogger(config TraceConfig, verbose logger.Verbose, rt http.RoundTripper
 	if err != nil {
 		// Transport errors surface at INFO so a failed request is visible at -v=0.
 		kv = append(kv, "error", err.Error())
-		jsonLogAt(verbose, req, 0, kv, "%s %s error %s", req.Method, req.URL, elapsed.Truncate(time.Millisecond))
+		jsonLogAt(verbose, req, 0, kv, "%s %s error %s", req.Method, redactedURL(req), elapsed.Truncate(time.Millisecond))
 		return nil, err
 	}
 
@@ -272,7 +227,7 @@ func jsonLogger(config TraceConfig, verbose logger.Verbose, rt http.RoundTripper
 				kv = append(kv, "responseBody", sanitizeBody(body))
 			}
 		}
-		jsonLogAt(verbose, req, 0, kv, "%s %s %d %s", req.Method, req.URL, resp.StatusCode, elapsed.Truncate(time.Millisecond))
+		jsonLogAt(verbose, req, 0, kv, "%s %s %d %s", req.Method, redactedURL(req), resp.StatusCode, elapsed.Truncate(time.Millisecond))
 		return resp, nil
 	}
 
@@ -280,10 +235,35 @@ func jsonLogger(config TraceConfig, verbose logger.Verbose, rt http.RoundTripper
 	if config.AccessLogErrorsOnly {
 		return resp, nil
 	}
-	jsonLogAt(verbose, req, level, kv, "%s %s %d %s", req.Method, req.URL, resp.StatusCode, elapsed.Truncate(time.Millisecond))
+	jsonLogAt(verbose, req, level, kv, "%s %s %d %s", req.Method, redactedURL(req), resp.StatusCode, elapsed.Truncate(time.Millisecond))
 	return resp, nil
 }
 
+// prettyFormatters returns logger.BodyFormatters, with JSON bodies rendered
+// as syntax highlighted code blocks.
+func prettyFormatters() []httpretty.Formatter {
+	formatters := logger.BodyFormatters()
+	for i, f := range formatters {
+		if f.Match("application/json") {
+			formatters[i] = highlightedJSON{f}
+		}
+	}
+	return formatters
+}
+
+type highlightedJSON struct {
+	httpretty.Formatter
+}
+
+func (f highlightedJSON) Format(w io.Writer, src []byte) error {
+	var buf bytes.Buffer
+	if err := f.Formatter.Format(&buf, src); err != nil {
+		return err
+	}
+	fmt.Fprint(w, api.CodeBlock("json", buf.String()).ANSI())
+	return nil
+}
+
 func prettyLogger(config TraceConfig, verbose logger.Verbose, rt http.RoundTripper, req *http.Request) (*http.Response, error) {
 	var form url.Values
 	if config.FormParams && !config.Body {
@@ -308,10 +288,16 @@ func prettyLogger(config TraceConfig, verbose logger.Verbose, rt http.RoundTripp
 		ResponseBody:    config.Response,
 		Auth:            config.Auth,
 		Colors:          true,
-		Formatters:      []httpretty.Formatter{&jsonFormatter{}, &formURLEncodedFormatter{}},
+		Formatters:      prettyFormatters(),
 		MaxRequestBody:  config.MaxBodyLength,
 		MaxResponseBody: config.MaxBodyLength,
-		RedactedHeaders: append(config.RedactedHeaders, logger.CommonRedactedHeaders...),
+		RedactedHeaders: append(config.RedactedHeaders, logger.GetRedactionPolicy().Headers...),
+		RedactURL: func(u *url.URL) *url.URL {
+			if redacted, err := url.Parse(logger.GetRedactionPolicy().RedactURL(u.String())); err == nil {
+				return redacted
+			}
+			return u
+		},
 	}
 	l.SetOutput(&buf)
 	inner := l.RoundTripper(rt)
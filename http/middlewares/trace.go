@@ -249,9 +249,10 @@ func (t *traceTransport) RoundTripper(rt netHttp.RoundTripper) netHttp.RoundTrip
 		_, span := t.tracer.Start(req.Context(), "http-"+spanName)
 		defer span.End()
 
+		policy := logger.GetRedactionPolicy()
 		span.SetAttributes(
 			attribute.String("request.method", req.Method),
-			attribute.String("request.url", req.URL.String()),
+			attribute.String("request.url", policy.RedactURL(req.URL.String())),
 			attribute.String("request.host", req.Host),
 		)
 
@@ -266,13 +267,13 @@ func (t *traceTransport) RoundTripper(rt netHttp.RoundTripper) netHttp.RoundTrip
 		span.SetAttributes(attribute.Int64("request.content-length", req.ContentLength))
 		if req.Body != nil && t.Config.Body {
 			if b, err := io.ReadAll(req.Body); err == nil {
-				span.SetAttributes(attribute.String("request.body", string(b)))
+				span.SetAttributes(attribute.String("request.body", policy.RedactBody(string(b), req.Header.Get("Content-Type"))))
 				req.Body = io.NopCloser(bytes.NewBuffer(b))
 			}
 		}
 
 		if t.Config.QueryParam && req.URL.RawQuery != "" {
-			for q, val := range req.URL.Query() {
+			for q, val := range policy.RedactQuery(req.URL.Query()) {
 				span.SetAttributes(attribute.StringSlice("request.query."+q, val))
 			}
 		}
@@ -299,7 +300,7 @@ func (t *traceTransport) RoundTripper(rt netHttp.RoundTripper) netHttp.RoundTrip
 				if bSize > len(b) {
 					bSize = len(b)
 				}
-				span.SetAttributes(attribute.String("response.body", string(b[:bSize])))
+				span.SetAttributes(attribute.String("response.body", policy.RedactBody(string(b[:bSize]), resp.Header.Get("Content-Type"))))
 				resp.Body = io.NopCloser(bytes.NewBuffer(b))
 			}
 		}
@@ -6,6 +6,7 @@ import (
 	"io"
 	netHTTP "net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -485,6 +486,54 @@ func TestWithContextFullHAR(t *testing.T) {
 	}
 }
 
+// TestWithContextStreamingHAR: a bounded collector streaming to the HAR
+// path keeps only MaxEntries in memory, while WriteHARFile finishes the
+// stream with every entry instead of overwriting it.
+func TestWithContextStreamingHAR(t *testing.T) {
+	srv := httptest.NewServer(netHTTP.HandlerFunc(func(w netHTTP.ResponseWriter, r *netHTTP.Request) {
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "takealot.har")
+	writer, err := har.NewWriter(path, har.WriterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := har.DefaultConfig()
+	cfg.MaxEntries = 2
+	collector := har.NewCollector(cfg).StreamTo(writer)
+	ctx := &fakeContext{
+		log:      newTestLogger(t, logger.Info),
+		harColl:  collector,
+		harPath:  path,
+		harLevel: HARMetadata,
+	}
+	c := NewClient().WithContext(ctx, "takealot")
+
+	for range 5 {
+		resp, err := c.R(context.Background()).Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if got := len(collector.Entries()); got != 2 {
+		t.Errorf("expected 2 entries retained in memory, got %d", got)
+	}
+	if err := WriteHARFile(collector, c.harPath); err != nil {
+		t.Fatal(err)
+	}
+	file, err := har.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Log.Entries) != 5 {
+		t.Errorf("expected all 5 entries in the streamed file, got %d", len(file.Log.Entries))
+	}
+}
+
 func hasHeaderCaseInsensitive(headers []string, want string) bool {
 	for _, h := range headers {
 		if strings.EqualFold(h, want) {
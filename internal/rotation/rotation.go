@@ -0,0 +1,94 @@
+// Package rotation names and prunes the backups of files rotated by the har
+// Writer and the logger's RotatingFile.
+//
+// A backup of app.log is named app-<timestamp>.log, optionally compressed to
+// app-<timestamp>.log.gz, where the timestamp is UTC with millisecond
+// precision. Names are unique and sort in rotation order: a rotation within
+// the same millisecond as the newest backup is named a millisecond after it.
+package rotation
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const timeFormat = "20060102T150405.000"
+
+type backup struct {
+	path string
+	time time.Time
+}
+
+// Rename moves path to a new backup named after t and returns its path.
+func Rename(path string, t time.Time) (string, error) {
+	backups, err := list(path)
+	if err != nil {
+		return "", err
+	}
+	t = t.UTC().Truncate(time.Millisecond)
+	if n := len(backups); n > 0 && !t.After(backups[n-1].time) {
+		t = backups[n-1].time.Add(time.Millisecond)
+	}
+	base, ext := split(path)
+	rotated := base + "-" + t.Format(timeFormat) + ext
+	return rotated, os.Rename(path, rotated)
+}
+
+// Backups returns the backups of path, oldest first. Other files in the same
+// directory, e.g. app-audit.log next to app.log, are not backups.
+func Backups(path string) ([]string, error) {
+	backups, err := list(path)
+	paths := make([]string, 0, len(backups))
+	for _, b := range backups {
+		paths = append(paths, b.path)
+	}
+	return paths, err
+}
+
+// Prune removes the oldest backups of path, keeping keep of them.
+func Prune(path string, keep int) error {
+	backups, err := list(path)
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(backups[0].path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+func split(path string) (base, ext string) {
+	ext = filepath.Ext(path)
+	return strings.TrimSuffix(path, ext), ext
+}
+
+func list(path string) ([]backup, error) {
+	dir := filepath.Dir(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	base, ext := split(filepath.Base(path))
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(base) + `-(\d{8}T\d{6}\.\d{3})` + regexp.QuoteMeta(ext) + `(\.gz)?$`)
+	var backups []backup
+	for _, e := range entries {
+		m := pattern.FindStringSubmatch(e.Name())
+		if m == nil || e.IsDir() {
+			continue
+		}
+		t, err := time.Parse(timeFormat, m[1])
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, e.Name()), time: t})
+	}
+	slices.SortStableFunc(backups, func(a, b backup) int { return a.time.Compare(b.time) })
+	return backups, nil
+}
@@ -0,0 +1,65 @@
+package rotation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenameWithinTheSameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	now := time.Now()
+	var rotated []string
+	for i := range 5 {
+		if err := os.WriteFile(path, []byte{byte('0' + i)}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		name, err := Rename(path, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotated = append(rotated, name)
+	}
+
+	backups, err := Backups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 5 {
+		t.Fatalf("expected 5 backups, got %v", backups)
+	}
+	for i, b := range backups {
+		if b != rotated[i] {
+			t.Errorf("backup %d = %s, want %s", i, b, rotated[i])
+		}
+		if data, _ := os.ReadFile(b); string(data) != string(rune('0'+i)) {
+			t.Errorf("%s = %q, want %d", b, data, i)
+		}
+	}
+}
+
+func TestPruneIgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	others := []string{"app-audit.log", "app-audit-20260101T000000.000.log", "app-old.log.gz", "app.log.1"}
+	for _, name := range append(others, "app-20260101T000000.000.log.gz", "app-20260102T000000.000.log") {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Prune(path, 1); err != nil {
+		t.Fatal(err)
+	}
+	backups, _ := Backups(path)
+	if len(backups) != 1 || filepath.Base(backups[0]) != "app-20260102T000000.000.log" {
+		t.Errorf("expected the newest backup to be kept, got %v", backups)
+	}
+	for _, name := range others {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was removed: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flanksource/commons/properties"
+)
+
+// OverflowPolicy decides what an AsyncWriter does with a record when its
+// queue is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest queued record to make room.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards the record being written.
+	DropNewest
+	// Block waits for room in the queue, applying backpressure to the caller.
+	Block
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropNewest:
+		return "drop-newest"
+	case Block:
+		return "block"
+	default:
+		return "drop-oldest"
+	}
+}
+
+// ParseOverflowPolicy parses drop-oldest, drop-newest or block.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "drop-oldest", "oldest", "":
+		return DropOldest, nil
+	case "drop-newest", "newest":
+		return DropNewest, nil
+	case "block":
+		return Block, nil
+	}
+	return DropOldest, fmt.Errorf("invalid log overflow policy %q: expected drop-oldest, drop-newest or block", s)
+}
+
+// AsyncOptions configures an AsyncWriter.
+type AsyncOptions struct {
+	// QueueSize is the maximum number of records waiting to be written.
+	// Default: 1024.
+	QueueSize int
+
+	// Policy applies when the queue is full. Default: DropOldest.
+	Policy OverflowPolicy
+
+	// FlushInterval bounds how long written records stay buffered while
+	// records keep arriving; the buffer is also flushed whenever the queue
+	// drains. Default: 1s.
+	FlushInterval time.Duration
+
+	// FlushTimeout is the deadline used by the package-level Flush (called
+	// from Fatalf) and DisableAsync. Default: 5s.
+	FlushTimeout time.Duration
+}
+
+// AsyncOptionsFromProperties reads log.async.queueSize, log.async.policy,
+// log.async.flushInterval and log.async.flushTimeout.
+func AsyncOptionsFromProperties() AsyncOptions {
+	policy, err := ParseOverflowPolicy(properties.String("", "log.async.policy"))
+	if err != nil {
+		GetLogger().Warnf("%v", err)
+	}
+	return AsyncOptions{
+		QueueSize:     properties.Int(0, "log.async.queueSize"),
+		Policy:        policy,
+		FlushInterval: properties.Duration(0, "log.async.flushInterval"),
+		FlushTimeout:  properties.Duration(0, "log.async.flushTimeout"),
+	}
+}
+
+// AsyncWriter decouples log output from the goroutines logging: Write copies
+// the record into a bounded queue and returns, and a background goroutine
+// writes queued records to the underlying writer. Install it with
+// EnableAsync, or pass it to SetOutput / NewWithWriter directly.
+type AsyncWriter struct {
+	w    io.Writer
+	out  *bufio.Writer
+	opts AsyncOptions
+
+	mu        sync.Mutex
+	notFull   *sync.Cond
+	queue     [][]byte
+	spare     [][]byte
+	enqueued  int64
+	flushed   int64
+	flushedCh chan struct{}
+	closed    bool
+
+	dropped  atomic.Int64
+	reported atomic.Int64
+	wake     chan struct{}
+	done     chan struct{}
+}
+
+// NewAsyncWriter starts an AsyncWriter writing to w. Call Close to stop it.
+func NewAsyncWriter(w io.Writer, opts AsyncOptions) *AsyncWriter {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = 5 * time.Second
+	}
+	a := &AsyncWriter{
+		w:         w,
+		out:       bufio.NewWriterSize(w, 64*1024),
+		opts:      opts,
+		flushedCh: make(chan struct{}),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	a.notFull = sync.NewCond(&a.mu)
+	go a.run()
+	return a
+}
+
+// Write queues a copy of p. It only blocks when the queue is full and the
+// policy is Block. Once the writer is closed, p is written synchronously.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	for a.opts.Policy == Block && len(a.queue) >= a.opts.QueueSize && !a.closed {
+		a.notFull.Wait()
+	}
+	if a.closed {
+		a.mu.Unlock()
+		return a.w.Write(p)
+	}
+	if len(a.queue) >= a.opts.QueueSize {
+		a.dropped.Add(1)
+		if a.opts.Policy == DropNewest {
+			a.mu.Unlock()
+			return len(p), nil
+		}
+		a.queue[0] = nil
+		a.queue = a.queue[1:]
+	}
+	a.queue = append(a.queue, append([]byte(nil), p...))
+	a.enqueued++
+	a.mu.Unlock()
+
+	a.signal()
+	return len(p), nil
+}
+
+// Dropped returns the number of records discarded because the queue was full.
+func (a *AsyncWriter) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+// Flush waits until every record queued before the call has been written to
+// the underlying writer, or ctx is done.
+func (a *AsyncWriter) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	target := a.enqueued
+	for a.flushed < target {
+		ch := a.flushedCh
+		a.mu.Unlock()
+		a.signal()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			a.mu.Lock()
+			pending := len(a.queue)
+			a.mu.Unlock()
+			return fmt.Errorf("flushing logs: %w (%d records still queued)", ctx.Err(), pending)
+		}
+		a.mu.Lock()
+	}
+	a.mu.Unlock()
+	return nil
+}
+
+// Close flushes the queue within ctx's deadline and stops the background
+// goroutine. Writes after Close go straight to the underlying writer.
+func (a *AsyncWriter) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.notFull.Broadcast()
+	a.mu.Unlock()
+	a.signal()
+
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("closing async log writer: %w", ctx.Err())
+	}
+}
+
+func (a *AsyncWriter) signal() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.opts.FlushInterval)
+	defer ticker.Stop()
+	lastFlush := time.Now()
+
+	for {
+		select {
+		case <-a.wake:
+		case <-ticker.C:
+		}
+		for {
+			batch, seq, closed := a.take()
+			if len(batch) == 0 {
+				a.flush(seq)
+				lastFlush = time.Now()
+				if closed {
+					return
+				}
+				break
+			}
+			for _, p := range batch {
+				_, _ = a.out.Write(p)
+			}
+			a.recycle(batch)
+			if time.Since(lastFlush) >= a.opts.FlushInterval {
+				_ = a.out.Flush()
+				lastFlush = time.Now()
+			}
+		}
+	}
+}
+
+// take removes all queued records, returning them with the number of records
+// enqueued so far.
+func (a *AsyncWriter) take() (batch [][]byte, seq int64, closed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	batch, a.queue, a.spare = a.queue, a.spare[:0], nil
+	a.notFull.Broadcast()
+	return batch, a.enqueued, a.closed
+}
+
+func (a *AsyncWriter) recycle(batch [][]byte) {
+	clear(batch)
+	a.mu.Lock()
+	if a.spare == nil {
+		a.spare = batch[:0]
+	}
+	a.mu.Unlock()
+}
+
+// flush writes out buffered records and marks everything up to seq as flushed.
+func (a *AsyncWriter) flush(seq int64) {
+	_ = a.out.Flush()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if seq > a.flushed {
+		a.flushed = seq
+		close(a.flushedCh)
+		a.flushedCh = make(chan struct{})
+	}
+}
+
+// reportDropped logs the number of records dropped since the last report,
+// returning true if there were any.
+func (a *AsyncWriter) reportDropped() bool {
+	dropped := a.dropped.Load()
+	n := dropped - a.reported.Swap(dropped)
+	if n > 0 {
+		GetLogger().Warnf("log queue overflowed (%s): %d records dropped", a.opts.Policy, n)
+	}
+	return n > 0
+}
+
+// EnableAsync routes all log output through an AsyncWriter wrapping the
+// current output, see SetOutput. If output is already asynchronous the
+// existing writer is returned.
+//
+// Fatalf flushes the queue, but the logger does not own the process exit, so
+// records still queued when main returns or os.Exit is called are lost unless
+// the program flushes first:
+//
+//	logger.EnableAsync(logger.AsyncOptions{})
+//	defer logger.Flush() // before any os.Exit too
+//
+// Setting the log.async property enables it as well, configured with
+// AsyncOptionsFromProperties.
+func EnableAsync(opts AsyncOptions) *AsyncWriter {
+	if a, ok := GetOutput().(*AsyncWriter); ok {
+		return a
+	}
+	a := NewAsyncWriter(GetOutput(), opts)
+	SetOutput(a)
+	return a
+}
+
+// DisableAsync closes the AsyncWriter installed by EnableAsync, if any, and
+// restores synchronous output to the writer it wrapped.
+func DisableAsync() error {
+	a, ok := GetOutput().(*AsyncWriter)
+	if !ok {
+		return nil
+	}
+	SetOutput(a.w)
+	ctx, cancel := context.WithTimeout(context.Background(), a.opts.FlushTimeout)
+	defer cancel()
+	err := a.Close(ctx)
+	a.reportDropped()
+	return err
+}
+
+// Flush waits, up to the FlushTimeout, for asynchronous log output to be
+// written and logs how many records were dropped. It is a no-op when output
+// is synchronous. Fatalf calls it; flushing on exit is the caller's job, see
+// EnableAsync.
+func Flush() error {
+	a, ok := GetOutput().(*AsyncWriter)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.opts.FlushTimeout)
+	defer cancel()
+	if err := a.Flush(ctx); err != nil {
+		return err
+	}
+	if a.reportDropped() {
+		return a.Flush(ctx)
+	}
+	return nil
+}
+
+// updateAsync applies the log.async property.
+func updateAsync(props *properties.Properties) {
+	_, async := GetOutput().(*AsyncWriter)
+	if enable := props.On(false, "log.async"); enable && !async {
+		EnableAsync(AsyncOptionsFromProperties())
+	} else if !enable && async && props.Get("log.async") != "" {
+		_ = DisableAsync()
+	}
+}
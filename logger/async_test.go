@@ -0,0 +1,184 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedWriter blocks every Write until open is closed.
+type gatedWriter struct {
+	open chan struct{}
+	mu   sync.Mutex
+	data strings.Builder
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{open: make(chan struct{})}
+}
+
+func (g *gatedWriter) Write(p []byte) (int, error) {
+	<-g.open
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.data.Write(p)
+}
+
+func (g *gatedWriter) String() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.data.String()
+}
+
+func (g *gatedWriter) lines() []string {
+	return strings.Fields(g.String())
+}
+
+// fill writes n records, returning once the writer has taken the first one so
+// the rest all queue up behind the blocked Write.
+func fill(t *testing.T, a *AsyncWriter, n int) {
+	t.Helper()
+	fmt.Fprintln(a, 0)
+	deadline := time.Now().Add(time.Second)
+	for {
+		a.mu.Lock()
+		taken := len(a.queue) == 0
+		a.mu.Unlock()
+		if taken {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("writer never picked up the first record")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i < n; i++ {
+		fmt.Fprintln(a, i)
+	}
+}
+
+func TestAsyncWriter_DoesNotBlockOnSlowOutput(t *testing.T) {
+	for _, tc := range []struct {
+		policy OverflowPolicy
+		want   string
+	}{
+		{DropNewest, "0 1 2 3"},
+		{DropOldest, "0 7 8 9"},
+	} {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			out := newGatedWriter()
+			a := NewAsyncWriter(out, AsyncOptions{QueueSize: 3, Policy: tc.policy})
+
+			start := time.Now()
+			fill(t, a, 10)
+			if time.Since(start) > 500*time.Millisecond {
+				t.Errorf("writes blocked for %v", time.Since(start))
+			}
+			if a.Dropped() != 6 {
+				t.Errorf("expected 6 dropped, got %d", a.Dropped())
+			}
+
+			close(out.open)
+			if err := a.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(out.lines(), " "); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			_ = a.Close(context.Background())
+		})
+	}
+}
+
+func TestAsyncWriter_BlockPolicy(t *testing.T) {
+	out := newGatedWriter()
+	a := NewAsyncWriter(out, AsyncOptions{QueueSize: 2, Policy: Block})
+	fill(t, a, 3)
+
+	written := make(chan struct{})
+	go func() {
+		fmt.Fprintln(a, 3)
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("write should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(out.open)
+	<-written
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(out.lines(), " "); got != "0 1 2 3" || a.Dropped() != 0 {
+		t.Errorf("got %q with %d dropped, want every record", got, a.Dropped())
+	}
+	_ = a.Close(context.Background())
+}
+
+func TestAsyncWriter_FlushDeadline(t *testing.T) {
+	out := newGatedWriter()
+	defer close(out.open)
+	a := NewAsyncWriter(out, AsyncOptions{})
+	fill(t, a, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := a.Flush(ctx)
+	if err == nil || !strings.Contains(err.Error(), "2 records still queued") {
+		t.Errorf("expected a deadline error reporting queued records, got %v", err)
+	}
+}
+
+func TestAsyncWriter_PeriodicFlush(t *testing.T) {
+	out := newGatedWriter()
+	close(out.open)
+	a := NewAsyncWriter(out, AsyncOptions{FlushInterval: 10 * time.Millisecond})
+	defer a.Close(context.Background())
+
+	fmt.Fprintln(a, "hello")
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(out.String(), "hello") {
+		if time.Now().After(deadline) {
+			t.Fatal("record was not flushed without an explicit Flush")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEnableAsync_FatalfFlushesAndReportsDrops(t *testing.T) {
+	original := GetOutput()
+	t.Cleanup(func() { SetOutput(original) })
+	out := newGatedWriter()
+	SetOutput(out)
+
+	a := EnableAsync(AsyncOptions{QueueSize: 2, Policy: DropNewest})
+	if EnableAsync(AsyncOptions{}) != a {
+		t.Error("EnableAsync should reuse the installed writer")
+	}
+	lg := New("async-test")
+	lg.Infof("first")
+	for range 5 {
+		lg.Infof("overflow")
+	}
+	close(out.open)
+	lg.Fatalf("giving up")
+
+	got := out.String()
+	for _, want := range []string{"first", "giving up", "records dropped"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+
+	if err := DisableAsync(); err != nil {
+		t.Fatal(err)
+	}
+	if GetOutput() != out {
+		t.Error("DisableAsync should restore the wrapped writer")
+	}
+}
@@ -1,10 +1,17 @@
 package logger
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,14 +20,47 @@ type BufferedLogEntry struct {
 	Message string
 	Time    time.Time
 	Level   LogLevel
+	// Logger is the name set with Named, empty for the unnamed logger.
+	Logger string
+	// Fields are the WithValues pairs of the logger, with string values
+	// redacted. They are shared between entries and must not be modified.
+	Fields map[string]any
+
+	seq uint64
+}
+
+// MarshalJSON encodes the entry with its level as a string, as written by
+// WriteJSONLines.
+func (e BufferedLogEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Time    time.Time      `json:"time"`
+		Level   string         `json:"level"`
+		Logger  string         `json:"logger,omitempty"`
+		Message string         `json:"message"`
+		Fields  map[string]any `json:"fields,omitempty"`
+	}{e.Time, e.Level.String(), e.Logger, e.Message, e.Fields})
+}
+
+// BufferedLogger implements Logger interface with in-memory log storage, e.g.
+// as a flight recorder for a request or task whose logs are only worth
+// keeping when something goes wrong (see DumpOnError and Recover).
+// Loggers derived with Named and WithValues share the buffer of their parent.
+type BufferedLogger struct {
+	*logBuffer
+	name   string
+	fields map[string]any
 }
 
-// BufferedLogger implements Logger interface with in-memory log storage
-type BufferedLogger struct {
+type logBuffer struct {
 	mu             sync.RWMutex
 	logsByLevel    map[LogLevel][]BufferedLogEntry
 	maxLogsByLevel map[LogLevel]int
 	logLevel       LogLevel
+	seq            uint64
+
+	dumpLast int
+	dump     func(BufferedLogEntries)
+	dumping  atomic.Bool
 }
 
 // NewBufferedLogger creates a new BufferedLogger with default retention strategy
@@ -42,11 +82,11 @@ func NewBufferedLoggerWithRetention(config RetentionConfig) *BufferedLogger {
 		maxLogsByLevel[level] = maxCount
 	}
 
-	return &BufferedLogger{
+	return &BufferedLogger{logBuffer: &logBuffer{
 		logsByLevel:    logsByLevel,
 		maxLogsByLevel: maxLogsByLevel,
 		logLevel:       Info,
-	}
+	}}
 }
 
 // getDefaultRetentionConfig returns default retention limits based on total maxLogs
@@ -75,15 +115,18 @@ func getDefaultRetentionConfig(maxLogs int) RetentionConfig {
 	return baseConfig
 }
 
-// appendLog adds a log entry to the appropriate level buffer
+// appendLog adds a log entry to the appropriate level buffer, dumping the
+// most recent entries if it is an error
 func (b *BufferedLogger) appendLog(level LogLevel, format string, args ...interface{}) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
-
+	b.seq++
 	entry := BufferedLogEntry{
 		Message: fmt.Sprintf(format, args...),
 		Time:    time.Now(),
 		Level:   level,
+		Logger:  b.name,
+		Fields:  b.fields,
+		seq:     b.seq,
 	}
 
 	// Get or create buffer for this level
@@ -105,6 +148,12 @@ func (b *BufferedLogger) appendLog(level LogLevel, format string, args ...interf
 	if len(b.logsByLevel[level]) > maxForLevel {
 		b.logsByLevel[level] = b.logsByLevel[level][len(b.logsByLevel[level])-maxForLevel:]
 	}
+	dump, last := b.dump, b.dumpLast
+	b.mu.Unlock()
+
+	if level <= Error && dump != nil {
+		b.dumpLogs(dump, last)
+	}
 }
 
 // GetLogs returns a copy of all buffered log entries, sorted by timestamp
@@ -119,13 +168,12 @@ func (b *BufferedLogger) GetLogs() []BufferedLogEntry {
 	}
 
 	// Sort by timestamp (oldest first)
-	for i := 0; i < len(allEntries)-1; i++ {
-		for j := i + 1; j < len(allEntries); j++ {
-			if allEntries[i].Time.After(allEntries[j].Time) {
-				allEntries[i], allEntries[j] = allEntries[j], allEntries[i]
-			}
+	slices.SortFunc(allEntries, func(a, b BufferedLogEntry) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
 		}
-	}
+		return int(a.seq) - int(b.seq)
+	})
 
 	return allEntries
 }
@@ -152,6 +200,140 @@ func (b *BufferedLogger) GetLogsByLevel(level LogLevel) []BufferedLogEntry {
 	return []BufferedLogEntry{}
 }
 
+// BufferedLogEntries are buffered log entries, oldest first.
+type BufferedLogEntries []BufferedLogEntry
+
+// LogQuery selects buffered log entries, zero fields match every entry.
+type LogQuery struct {
+	// Since and Until bound the entry time, inclusive.
+	Since, Until time.Time
+	// Name matches entries logged by the named logger or its children, i.e.
+	// "db" matches "db" and "db.pool".
+	Name string
+	// Levels matches entries at any of the given levels.
+	Levels []LogLevel
+	// Fields matches entries with all the given fields, comparing values
+	// formatted with fmt.Sprint.
+	Fields map[string]any
+	// Message matches entries containing the substring.
+	Message string
+	// Last limits the result to the most recent entries.
+	Last int
+}
+
+// Matches returns true if the entry matches the query, ignoring Last.
+func (q LogQuery) Matches(e BufferedLogEntry) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	if q.Name != "" && e.Logger != q.Name && !strings.HasPrefix(e.Logger, q.Name+".") {
+		return false
+	}
+	if len(q.Levels) > 0 && !slices.Contains(q.Levels, e.Level) {
+		return false
+	}
+	for k, v := range q.Fields {
+		actual, ok := e.Fields[k]
+		if !ok || fmt.Sprint(actual) != fmt.Sprint(v) {
+			return false
+		}
+	}
+	return q.Message == "" || strings.Contains(e.Message, q.Message)
+}
+
+// Filter returns the entries matching the query.
+func (e BufferedLogEntries) Filter(q LogQuery) BufferedLogEntries {
+	var out BufferedLogEntries
+	for _, entry := range e {
+		if q.Matches(entry) {
+			out = append(out, entry)
+		}
+	}
+	return out.Tail(q.Last)
+}
+
+// Tail returns the last n entries, or all of them if n <= 0.
+func (e BufferedLogEntries) Tail(n int) BufferedLogEntries {
+	if n <= 0 || n >= len(e) {
+		return e
+	}
+	return e[len(e)-n:]
+}
+
+// WriteJSONLines writes the entries as one JSON object per line.
+func (e BufferedLogEntries) WriteJSONLines(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, entry := range e {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query returns the buffered entries matching q, oldest first.
+func (b *BufferedLogger) Query(q LogQuery) BufferedLogEntries {
+	return BufferedLogEntries(b.GetLogs()).Filter(q)
+}
+
+// DumpOnError calls dump with the last entries across all levels whenever an
+// error or fatal entry is logged, including panics caught by Recover. A nil
+// dump disables dumping. Entries logged by dump itself do not trigger another
+// dump.
+func (b *BufferedLogger) DumpOnError(last int, dump func(BufferedLogEntries)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dumpLast, b.dump = last, dump
+}
+
+// DumpJSONLines returns a DumpOnError func writing entries to w as JSON lines.
+func DumpJSONLines(w io.Writer) func(BufferedLogEntries) {
+	return func(entries BufferedLogEntries) {
+		_ = entries.WriteJSONLines(w)
+	}
+}
+
+func (b *BufferedLogger) dumpLogs(dump func(BufferedLogEntries), last int) {
+	if !b.dumping.CompareAndSwap(false, true) {
+		return
+	}
+	defer b.dumping.Store(false)
+	dump(BufferedLogEntries(b.GetLogs()).Tail(last))
+}
+
+// Recover records a panic as a fatal entry with its stack trace, dumping the
+// buffer (see DumpOnError), and re-panics. It must be deferred directly:
+//
+//	defer log.Recover()
+func (b *BufferedLogger) Recover() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	// Fatalf has already recorded its message before panicking
+	if fatal := b.GetLogsByLevel(Fatal); len(fatal) == 0 || fatal[len(fatal)-1].Message != fmt.Sprint(r) {
+		b.appendLog(Fatal, "panic: %v\n%s", r, debug.Stack())
+	}
+	panic(r)
+}
+
+type bufferedLoggerKey struct{}
+
+// ContextWithBufferedLogger returns a copy of ctx carrying b, to record the
+// logs of a request or task.
+func ContextWithBufferedLogger(ctx context.Context, b *BufferedLogger) context.Context {
+	return context.WithValue(ctx, bufferedLoggerKey{}, b)
+}
+
+// BufferedLoggerFromContext returns the BufferedLogger attached to ctx, or nil.
+func BufferedLoggerFromContext(ctx context.Context) *BufferedLogger {
+	b, _ := ctx.Value(bufferedLoggerKey{}).(*BufferedLogger)
+	return b
+}
+
 // SetRetentionPolicy updates the retention limits for log levels
 func (b *BufferedLogger) SetRetentionPolicy(config RetentionConfig) {
 	b.mu.Lock()
@@ -251,9 +433,25 @@ func (b *BufferedLogger) Fatalf(format string, args ...interface{}) {
 	panic(fmt.Sprintf(format, args...))
 }
 
-// WithValues returns the same logger (noop as requested)
+// WithValues returns a logger sharing the buffer whose entries carry the
+// given key/value pairs in their Fields
 func (b *BufferedLogger) WithValues(keysAndValues ...interface{}) Logger {
-	return b
+	return b.withValues(keysAndValues...)
+}
+
+func (b *BufferedLogger) withValues(keysAndValues ...interface{}) *BufferedLogger {
+	if len(keysAndValues) == 0 {
+		return b
+	}
+	fields := maps.Clone(b.fields)
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	for _, attr := range slog.Group("", keysAndValues...).Value.Group() {
+		attr = redactAttr(nil, attr)
+		fields[attr.Key] = attr.Value.Resolve().Any()
+	}
+	return &BufferedLogger{logBuffer: b.logBuffer, name: b.name, fields: fields}
 }
 
 // IsTraceEnabled checks if trace level is enabled
@@ -310,14 +508,15 @@ func (b *BufferedLogger) WithV(level any) Logger {
 	return b
 }
 
-// Named returns the same logger (noop as requested)
+// Named returns a logger sharing the buffer whose entries are recorded
+// under name
 func (b *BufferedLogger) Named(name string) Logger {
-	return b
+	return &BufferedLogger{logBuffer: b.logBuffer, name: name, fields: b.fields}
 }
 
-// WithoutName returns the same logger (noop as requested)
+// WithoutName returns a logger sharing the buffer without a name
 func (b *BufferedLogger) WithoutName() Logger {
-	return b
+	return b.Named("")
 }
 
 // WithSkipReportLevel returns the same logger (noop as requested)
@@ -347,8 +546,13 @@ func (v *bufferedVerbose) Always() Verbose {
 	}
 }
 
-func (v *bufferedVerbose) WithValues(_ ...interface{}) Verbose {
-	return v
+func (v *bufferedVerbose) WithValues(keysAndValues ...interface{}) Verbose {
+	return &bufferedVerbose{
+		logger:  v.logger.withValues(keysAndValues...),
+		enabled: v.enabled,
+		filters: v.filters,
+		always:  v.always,
+	}
 }
 
 // isFiltered checks if a log line should be filtered out based on filters
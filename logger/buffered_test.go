@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBufferedLoggerRecordsNameAndFields(t *testing.T) {
+	b := NewBufferedLogger(10)
+	b.Named("db").WithValues("table", "users", "rows", 3).Infof("query done")
+	b.WithValues("token", "Bearer abcdefghijklmnopqrstuvwxyz").Warnf("auth")
+	b.V(Info).WithValues("step", 1).Infof("verbose")
+
+	logs := b.GetLogs()
+	if len(logs) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(logs))
+	}
+	if logs[0].Logger != "db" || logs[0].Fields["table"] != "users" || logs[0].Fields["rows"] != int64(3) {
+		t.Errorf("unexpected entry: %+v", logs[0])
+	}
+	if token := logs[1].Fields["token"].(string); strings.Contains(token, "abcdefghijklmnop") {
+		t.Errorf("expected token to be redacted, got %q", token)
+	}
+	if logs[2].Fields["step"] != int64(1) {
+		t.Errorf("expected verbose fields, got %+v", logs[2])
+	}
+	if b.WithValues("a", 1).(*BufferedLogger).Named("x").WithoutName().(*BufferedLogger).fields["a"] != int64(1) {
+		t.Error("expected fields to survive Named and WithoutName")
+	}
+}
+
+func TestBufferedLoggerQuery(t *testing.T) {
+	b := NewBufferedLogger(10)
+	b.Named("db").Infof("connect")
+	start := time.Now()
+	b.Named("db.pool").WithValues("id", 7).Warnf("slow")
+	b.Named("http").WithValues("id", 8).Errorf("failed")
+	b.Infof("done")
+
+	tests := []struct {
+		query LogQuery
+		want  []string
+	}{
+		{LogQuery{}, []string{"connect", "slow", "failed", "done"}},
+		{LogQuery{Name: "db"}, []string{"connect", "slow"}},
+		{LogQuery{Since: start}, []string{"slow", "failed", "done"}},
+		{LogQuery{Until: start}, []string{"connect"}},
+		{LogQuery{Fields: map[string]any{"id": "7"}}, []string{"slow"}},
+		{LogQuery{Levels: []LogLevel{Warn, Error}}, []string{"slow", "failed"}},
+		{LogQuery{Message: "o"}, []string{"connect", "slow", "done"}},
+		{LogQuery{Last: 2}, []string{"failed", "done"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range b.Query(tt.query) {
+			got = append(got, e.Message)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("query %+v: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestBufferedLoggerDumpOnError(t *testing.T) {
+	b := NewBufferedLogger(10)
+	var dumps []BufferedLogEntries
+	b.DumpOnError(2, func(entries BufferedLogEntries) {
+		dumps = append(dumps, entries)
+		b.Errorf("dumping") // must not dump recursively
+	})
+
+	b.Infof("one")
+	b.Infof("two")
+	b.Warnf("three")
+	if len(dumps) != 0 {
+		t.Fatalf("expected no dump before an error, got %d", len(dumps))
+	}
+	b.Named("job").Errorf("boom")
+	if len(dumps) != 1 || len(dumps[0]) != 2 || dumps[0][0].Message != "three" || dumps[0][1].Message != "boom" {
+		t.Fatalf("expected the last 2 entries to be dumped, got %+v", dumps)
+	}
+
+	func() {
+		defer func() { _ = recover() }()
+		defer b.Recover()
+		panic("kaboom")
+	}()
+	if len(dumps) != 2 || !strings.HasPrefix(dumps[1][1].Message, "panic: kaboom") {
+		t.Fatalf("expected the panic to be dumped, got %+v", dumps)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "fatal 1" {
+				t.Errorf("expected Recover to re-panic, got %v", r)
+			}
+		}()
+		defer b.Recover()
+		b.Fatalf("fatal %d", 1)
+	}()
+	if len(dumps) != 3 || len(b.GetLogsByLevel(Fatal)) != 2 {
+		t.Errorf("expected Fatalf to be recorded and dumped once, got %d dumps and %+v", len(dumps), b.GetLogsByLevel(Fatal))
+	}
+}
+
+func TestBufferedLoggerJSONLines(t *testing.T) {
+	b := NewBufferedLogger(10)
+	b.Named("api").WithValues("user", "bob").Errorf("denied")
+
+	var buf bytes.Buffer
+	if err := b.Query(LogQuery{}).WriteJSONLines(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid JSON line %q: %v", buf.String(), err)
+	}
+	if line["level"] != "error" || line["logger"] != "api" || line["message"] != "denied" ||
+		line["fields"].(map[string]any)["user"] != "bob" {
+		t.Errorf("unexpected JSON line: %s", buf.String())
+	}
+}
+
+func TestBufferedLoggerContext(t *testing.T) {
+	if BufferedLoggerFromContext(context.Background()) != nil {
+		t.Error("expected no BufferedLogger in an empty context")
+	}
+	b := NewBufferedLogger(10)
+	ctx := ContextWithBufferedLogger(context.Background(), b)
+	if BufferedLoggerFromContext(ctx) != b {
+		t.Error("expected the attached BufferedLogger")
+	}
+}
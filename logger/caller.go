@@ -11,6 +11,7 @@ var SkipFrameSuffixes = []string{
 	"logger/slog.go",
 	"logger/default.go",
 	"logger/caller.go",
+	"logger/errors.go",
 	"gorm/logger.go",
 	"golang.org/toolchain",
 }
@@ -33,7 +34,7 @@ func Caller(skip ...int) string {
 		// second return value is "more", not "ok"
 		frame, _ := frames.Next()
 		if !skipFrame(frame) {
-			return string(strconv.AppendInt(append([]byte(frame.File), ':'), int64(frame.Line), 10))
+			return frameString(frame)
 		}
 	}
 	return ""
@@ -48,12 +49,17 @@ func Stacktrace() string {
 		// second return value is "more", not "ok"
 		frame, _ := frames.Next()
 		if !skipFrame(frame) {
-			s += string(strconv.AppendInt(append([]byte(frame.File), ':'), int64(frame.Line), 10)) + "\n"
+			s += frameString(frame) + "\n"
 		}
 	}
 	return s
 }
 
+// frameString returns file:line
+func frameString(frame runtime.Frame) string {
+	return string(strconv.AppendInt(append([]byte(frame.File), ':'), int64(frame.Line), 10))
+}
+
 func skipFrame(frame runtime.Frame) bool {
 	for _, suffix := range SkipFrameSuffixes {
 		if strings.HasSuffix(frame.File, suffix) {
@@ -74,6 +74,8 @@ type Flags struct {
 	LogToStderr                   bool // Deprecated: logs always go to stderr, this field is ignored
 	Level                         string
 	LevelCount                    int
+	// Sinks replaces stderr with these destinations, see ParseSink.
+	Sinks []string
 }
 
 func Configure(flags Flags) {
@@ -97,6 +99,10 @@ func Configure(flags Flags) {
 		properties.Set("log.color", "false")
 	}
 
+	if len(flags.Sinks) > 0 {
+		properties.Set("log.sinks", strings.Join(flags.Sinks, ","))
+	}
+
 	currentLogger = *New("")
 
 }
@@ -110,6 +116,7 @@ func BindFlags(flags *pflag.FlagSet) {
 	flags.Bool("color", true, "Print logs using color")
 	flags.Bool("report-caller", false, "Report log caller info")
 	flags.Bool("log-to-stderr", true, "Deprecated: logs always go to stderr")
+	flags.StringArray("log-sink", nil, "Log destination replacing stderr, repeatable: stderr, file:///path.log?format=json&level=debug&maxSize=100MB, syslog+udp://host:514")
 }
 
 // UseCobraFlags initializes the logger using values from parsed cobra flags.
@@ -126,6 +133,10 @@ func UseCobraFlags(flags *pflag.FlagSet) {
 	if jsonLogs, err := flags.GetBool("json-logs"); err == nil && jsonLogs {
 		currentLogger = New("")
 	}
+
+	if sinks, err := flags.GetStringArray("log-sink"); err == nil && len(sinks) > 0 {
+		properties.Set("log.sinks", strings.Join(sinks, ","))
+	}
 }
 
 // Warnf logs a warning message with formatting support.
@@ -166,6 +177,18 @@ func Errorf(format string, args ...interface{}) {
 	currentLogger.Errorf(format, args...)
 }
 
+// LogError logs err with its wrap chain, stack trace and oops metadata, see
+// SlogLogger.LogError.
+func LogError(err error, msg ...any) {
+	if l, ok := currentLogger.(ErrorLogger); ok {
+		l.LogError(err, msg...)
+	} else if err != nil && len(msg) > 0 {
+		currentLogger.Errorf("%s: %v", errorMessage(msg...), err)
+	} else if err != nil {
+		currentLogger.Errorf("%v", err)
+	}
+}
+
 // Debugf logs a debug message with formatting support.
 // These messages are only shown when debug logging is enabled.
 func Debugf(format string, args ...interface{}) {
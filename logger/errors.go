@@ -0,0 +1,250 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/samber/oops"
+)
+
+// maxErrorFrames caps the stack frames logged for an error
+const maxErrorFrames = 32
+
+// ErrorLogger is implemented by loggers that log errors with their structure,
+// see SlogLogger.LogError.
+type ErrorLogger interface {
+	LogError(err error, msg ...any)
+}
+
+// ErrorDetails is the structure extracted from an error for logging.
+type ErrorDetails struct {
+	Message string
+	// Type is the Go type of the innermost error.
+	Type string
+	// Chain is the message of each wrapped error, outermost first, when
+	// there is more than one.
+	Chain []string
+	// Stack is the stack trace of the innermost pkg/errors or oops error,
+	// as "file:line function" frames.
+	Stack []string
+
+	// Code, Domain, Tags, Hint, Public, Owner and Context are set from
+	// samber/oops errors.
+	Code    any
+	Domain  string
+	Tags    []string
+	Hint    string
+	Public  string
+	Owner   string
+	Context map[string]any
+}
+
+// ExtractError returns the wrap chain, stack trace and oops metadata of err.
+func ExtractError(err error) ErrorDetails {
+	details := ErrorDetails{Message: err.Error()}
+
+	var frames []runtime.Frame
+	var innermost error
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if msg := e.Error(); len(details.Chain) == 0 || details.Chain[len(details.Chain)-1] != msg {
+			details.Chain = append(details.Chain, msg)
+		}
+		// keep the deepest stack, closest to where the error was created
+		switch st := e.(type) {
+		case oops.OopsError:
+			if f := st.StackFrames(); len(f) > 0 {
+				frames = f
+			}
+		case interface{ StackTrace() pkgerrors.StackTrace }:
+			frames = pcFrames(st.StackTrace())
+		}
+		innermost = e
+	}
+	if len(details.Chain) == 1 {
+		details.Chain = nil
+	}
+	details.Type = fmt.Sprintf("%T", innermost)
+
+	for _, frame := range frames {
+		if skipFrame(frame) {
+			continue
+		}
+		s := frameString(frame)
+		if frame.Function != "" {
+			s += " " + path.Base(frame.Function)
+		}
+		details.Stack = append(details.Stack, s)
+		if len(details.Stack) == maxErrorFrames {
+			break
+		}
+	}
+
+	if o, ok := oops.AsOops(err); ok {
+		details.Code = o.Code()
+		details.Domain = o.Domain()
+		details.Tags = o.Tags()
+		details.Hint = o.Hint()
+		details.Public = o.Public()
+		details.Owner = o.Owner()
+		details.Context = o.Context()
+	}
+	return details
+}
+
+func pcFrames(stack pkgerrors.StackTrace) []runtime.Frame {
+	pcs := make([]uintptr, len(stack))
+	for i, f := range stack {
+		pcs[i] = uintptr(f)
+	}
+	var frames []runtime.Frame
+	iter := runtime.CallersFrames(pcs)
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, frame)
+		if !more {
+			return frames
+		}
+	}
+}
+
+// Attr returns the details as an "error" attribute group, omitting empty
+// fields.
+func (d ErrorDetails) Attr() slog.Attr {
+	attrs := []any{slog.String("message", d.Message), slog.String("type", d.Type)}
+	if len(d.Chain) > 0 {
+		attrs = append(attrs, slog.Any("chain", d.Chain))
+	}
+	if d.Code != nil && d.Code != "" {
+		attrs = append(attrs, slog.Any("code", d.Code))
+	}
+	for _, field := range []struct{ key, value string }{
+		{"domain", d.Domain}, {"hint", d.Hint}, {"public", d.Public}, {"owner", d.Owner},
+	} {
+		if field.value != "" {
+			attrs = append(attrs, slog.String(field.key, field.value))
+		}
+	}
+	if len(d.Tags) > 0 {
+		attrs = append(attrs, slog.Any("tags", d.Tags))
+	}
+	if len(d.Context) > 0 {
+		var context []any
+		for _, k := range sortedKeys(d.Context) {
+			context = append(context, slog.Any(k, d.Context[k]))
+		}
+		attrs = append(attrs, slog.Group("context", context...))
+	}
+	if len(d.Stack) > 0 {
+		attrs = append(attrs, slog.Any("stack", d.Stack))
+	}
+	return slog.Group("error", attrs...)
+}
+
+// Pretty returns the details, other than the message, as indented lines
+// following a log message.
+func (d ErrorDetails) Pretty(color bool) string {
+	label := func(s string) string {
+		if color {
+			return DarkWhite + s + Reset
+		}
+		return s
+	}
+	var b strings.Builder
+	line := func(name, value string) {
+		b.WriteString("\n    " + label(name+":") + " " + value)
+	}
+
+	line("type", d.Type)
+	if d.Code != nil && d.Code != "" {
+		line("code", fmt.Sprint(d.Code))
+	}
+	if d.Domain != "" {
+		line("domain", d.Domain)
+	}
+	if d.Owner != "" {
+		line("owner", d.Owner)
+	}
+	if len(d.Tags) > 0 {
+		line("tags", strings.Join(d.Tags, ", "))
+	}
+	if d.Hint != "" {
+		hint := d.Hint
+		if color {
+			hint = Cyan + hint + Reset
+		}
+		line("hint", hint)
+	}
+	if len(d.Context) > 0 {
+		var pairs []string
+		for _, k := range sortedKeys(d.Context) {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", k, d.Context[k]))
+		}
+		line("context", StripSecrets(strings.Join(pairs, " ")))
+	}
+	for _, cause := range d.Chain[min(1, len(d.Chain)):] {
+		if color {
+			cause = Red + cause + Reset
+		}
+		line("caused by", cause)
+	}
+	for _, frame := range d.Stack {
+		b.WriteString("\n    " + label("at "+frame))
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// LogError logs err at error level with its structure: the wrap chain, the
+// stack trace of pkg/errors and oops errors, and the oops code, tags, hint and
+// context. JSON logs and sinks receive these as an "error" attribute group,
+// pretty logs as indented lines under the message. msg is an optional format
+// and arguments prefixed to the error message.
+func (s SlogLogger) LogError(err error, msg ...any) {
+	if err == nil || !s.Logger.Enabled(todo, slog.LevelError) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), slog.LevelError, "", CallerPC())
+	details := ExtractError(err)
+	message := details.Message
+	if len(msg) > 0 {
+		message = errorMessage(msg...) + ": " + message
+	}
+	if structuredOutput() {
+		r.AddAttrs(details.Attr())
+	} else {
+		message += details.Pretty(isTTY && flags.color)
+	}
+	s.handleRaw(r, message)
+}
+
+func errorMessage(msg ...any) string {
+	if len(msg) == 1 {
+		return fmt.Sprintf("%v", msg[0])
+	}
+	return fmt.Sprintf(fmt.Sprintf("%v", msg[0]), msg[1:]...)
+}
+
+// firstError returns the first error in args.
+func firstError(args []any) error {
+	for _, arg := range args {
+		if err, ok := arg.(error); ok && err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/samber/oops"
+)
+
+func saveFile() error {
+	return pkgerrors.New("disk full")
+}
+
+func TestExtractError_PkgErrors(t *testing.T) {
+	err := fmt.Errorf("request failed: %w", pkgerrors.Wrap(saveFile(), "saving report"))
+	d := ExtractError(err)
+
+	want := []string{"request failed: saving report: disk full", "saving report: disk full", "disk full"}
+	if strings.Join(d.Chain, "|") != strings.Join(want, "|") {
+		t.Errorf("chain = %q, want %q", d.Chain, want)
+	}
+	if len(d.Stack) == 0 || !strings.Contains(d.Stack[0], "errors_test.go") || !strings.HasSuffix(d.Stack[0], "logger.saveFile") {
+		t.Errorf("stack should start where the error was created: %q", d.Stack)
+	}
+	if d.Type != "*errors.fundamental" {
+		t.Errorf("type = %s", d.Type)
+	}
+}
+
+func TestExtractError_Oops(t *testing.T) {
+	err := oops.Code("E_STORAGE").
+		In("storage").
+		Tags("io", "retryable").
+		Hint("free up disk space").
+		With("path", "/var/data", "password", "hunter2").
+		Wrapf(fmt.Errorf("disk full"), "writing report")
+	d := ExtractError(err)
+
+	if d.Code != "E_STORAGE" || d.Domain != "storage" || d.Hint != "free up disk space" ||
+		strings.Join(d.Tags, ",") != "io,retryable" || d.Context["path"] != "/var/data" {
+		t.Errorf("oops metadata missing: %+v", d)
+	}
+	if len(d.Stack) == 0 || !strings.Contains(d.Stack[0], "errors_test.go") {
+		t.Errorf("expected the oops stack trace, got %q", d.Stack)
+	}
+}
+
+func TestLogError_JSON(t *testing.T) {
+	var buf lockedBuffer
+	useSinks(t, NewSink(&buf, FormatJSON, "info"))
+	err := oops.Code("E_STORAGE").Tags("io").With("path", "/var/data").Wrapf(saveFile(), "writing report")
+
+	New("errors-json").LogError(err, "export %s failed", "daily")
+
+	var record struct {
+		Msg   string `json:"msg"`
+		Error struct {
+			Message string            `json:"message"`
+			Code    string            `json:"code"`
+			Tags    []string          `json:"tags"`
+			Chain   []string          `json:"chain"`
+			Context map[string]string `json:"context"`
+			Stack   []string          `json:"stack"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(buf.String()), &record); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if record.Msg != "export daily failed: writing report: disk full" || record.Error.Code != "E_STORAGE" ||
+		record.Error.Context["path"] != "/var/data" || len(record.Error.Chain) < 2 || len(record.Error.Stack) == 0 {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestErrorf_AddsErrorAttrsInJSON(t *testing.T) {
+	var buf lockedBuffer
+	useSinks(t, NewSink(&buf, FormatJSON, "info"))
+	New("errors-errorf").Errorf("sync failed: %v", pkgerrors.Wrap(saveFile(), "saving"))
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(buf.String()), &record); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := record["error"].(map[string]any); !ok || e["message"] != "saving: disk full" || e["stack"] == nil {
+		t.Errorf("expected an error group, got %v", record)
+	}
+}
+
+func TestLogError_Pretty(t *testing.T) {
+	original := GetOutput()
+	t.Cleanup(func() { SetOutput(original) })
+	var buf bytes.Buffer
+	SetOutput(&buf)
+
+	err := oops.Code("E_STORAGE").Hint("free up disk space").Wrapf(saveFile(), "writing report")
+	New("errors-pretty").LogError(err)
+
+	out := buf.String()
+	for _, want := range []string{"writing report: disk full", "code: E_STORAGE", "hint: free up disk space", "caused by: disk full", "at ", "errors_test.go"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("pretty output missing %q:\n%s", want, out)
+		}
+	}
+}
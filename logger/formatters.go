@@ -0,0 +1,335 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/flanksource/commons/logger/httpretty"
+	"gopkg.in/yaml.v3"
+)
+
+var bodyFormatters = struct {
+	sync.RWMutex
+	registered []httpretty.Formatter
+}{}
+
+// defaultBodyFormatters are tried after registered formatters, in order.
+func defaultBodyFormatters() []httpretty.Formatter {
+	return []httpretty.Formatter{
+		&JSONBodyFormatter{},
+		&FormBodyFormatter{},
+		&XMLFormatter{},
+		&YAMLFormatter{},
+		&MultipartFormatter{},
+		&TextBodyFormatter{},
+		&HexFormatter{},
+	}
+}
+
+// RegisterBodyFormatter adds f to the formatters returned by BodyFormatters,
+// ahead of the built in ones and those registered before it.
+func RegisterBodyFormatter(f httpretty.Formatter) {
+	bodyFormatters.Lock()
+	defer bodyFormatters.Unlock()
+	bodyFormatters.registered = append([]httpretty.Formatter{f}, bodyFormatters.registered...)
+}
+
+// BodyFormatters returns the formatters used to log HTTP bodies: registered
+// formatters followed by JSON, form, XML, YAML, multipart, text and hex dump
+// formatters. Each masks secrets in its output with the redaction policy.
+func BodyFormatters() []httpretty.Formatter {
+	bodyFormatters.RLock()
+	formatters := append(slices.Clone(bodyFormatters.registered), defaultBodyFormatters()...)
+	bodyFormatters.RUnlock()
+	for i, f := range formatters {
+		formatters[i] = redactingFormatter{f}
+	}
+	return formatters
+}
+
+// redactingFormatter masks token patterns the formatter itself did not
+// recognize as secrets.
+type redactingFormatter struct {
+	httpretty.Formatter
+}
+
+func (f redactingFormatter) Format(w io.Writer, src []byte) error {
+	var buf bytes.Buffer
+	if err := f.Formatter.Format(&buf, src); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, GetRedactionPolicy().RedactString(buf.String()))
+	return err
+}
+
+// JSONBodyFormatter indents JSON bodies, masking the values of sensitive keys.
+type JSONBodyFormatter struct{}
+
+func (f *JSONBodyFormatter) Match(mediatype string) bool {
+	return (&httpretty.JSONFormatter{}).Match(mediatype)
+}
+
+func (f *JSONBodyFormatter) Format(w io.Writer, src []byte) error {
+	if !json.Valid(src) {
+		return errors.New("invalid JSON")
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(GetRedactionPolicy().RedactJSON(string(src))), "", "    "); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// FormBodyFormatter lists URL encoded form fields, masking sensitive ones.
+type FormBodyFormatter struct{}
+
+func (f *FormBodyFormatter) Match(mediatype string) bool {
+	return mediatype == "application/x-www-form-urlencoded"
+}
+
+func (f *FormBodyFormatter) Format(w io.Writer, src []byte) error {
+	values, err := url.ParseQuery(string(src))
+	if err != nil {
+		return err
+	}
+	values = GetRedactionPolicy().RedactValues(values)
+	for _, k := range sortedKeys(values) {
+		for _, v := range values[k] {
+			fmt.Fprintf(w, "  %s=%s\n", k, v)
+		}
+	}
+	return nil
+}
+
+// TextBodyFormatter masks "key: value" and "key=value" secrets in plain text.
+type TextBodyFormatter struct{}
+
+func (f *TextBodyFormatter) Match(mediatype string) bool {
+	return strings.HasPrefix(mediatype, "text/")
+}
+
+func (f *TextBodyFormatter) Format(w io.Writer, src []byte) error {
+	_, err := io.WriteString(w, GetRedactionPolicy().RedactText(string(src)))
+	return err
+}
+
+// XMLFormatter indents XML bodies, masking the text of elements and the
+// attributes with sensitive names.
+type XMLFormatter struct{}
+
+func (f *XMLFormatter) Match(mediatype string) bool {
+	return mediatype == "application/xml" || mediatype == "text/xml" || strings.HasSuffix(mediatype, "+xml")
+}
+
+func (f *XMLFormatter) Format(w io.Writer, src []byte) error {
+	policy := GetRedactionPolicy()
+	dec := xml.NewDecoder(bytes.NewReader(src))
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "    ")
+	var sensitive []bool
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			for i, attr := range t.Attr {
+				if policy.IsSensitiveKey(attr.Name.Local) {
+					t.Attr[i].Value = policy.MaskValue(attr.Value)
+				}
+			}
+			sensitive = append(sensitive, policy.IsSensitiveKey(t.Name.Local))
+			tok = t
+		case xml.EndElement:
+			sensitive = sensitive[:max(len(sensitive)-1, 0)]
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" {
+				continue // re-indented by the encoder
+			}
+			if len(sensitive) > 0 && sensitive[len(sensitive)-1] {
+				text = policy.MaskValue(text)
+			}
+			tok = xml.CharData(text)
+		}
+		if err := enc.EncodeToken(tok); err != nil {
+			return err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// YAMLFormatter re-indents YAML bodies, masking the values of sensitive keys.
+type YAMLFormatter struct{}
+
+func (f *YAMLFormatter) Match(mediatype string) bool {
+	return strings.HasSuffix(mediatype, "yaml") || strings.HasSuffix(mediatype, "yml")
+}
+
+func (f *YAMLFormatter) Format(w io.Writer, src []byte) error {
+	policy := GetRedactionPolicy()
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		redactYAML(policy, &doc)
+		if err := enc.Encode(&doc); err != nil {
+			return err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func redactYAML(policy *RedactionPolicy, node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if value := node.Content[i+1]; value.Kind == yaml.ScalarNode && policy.IsSensitiveKey(node.Content[i].Value) {
+				value.Value = policy.MaskValue(value.Value)
+				value.Style = 0
+			}
+		}
+	}
+	for _, child := range node.Content {
+		redactYAML(policy, child)
+	}
+}
+
+// MultipartFormatter summarizes multipart parts: the value of short text
+// fields, sensitive ones masked, and the name, content type and size of
+// files and other parts.
+type MultipartFormatter struct {
+	// MaxValue is the longest field value printed. Default: 256.
+	MaxValue int
+}
+
+func (f *MultipartFormatter) Match(mediatype string) bool {
+	return strings.HasPrefix(mediatype, "multipart/")
+}
+
+func (f *MultipartFormatter) Format(w io.Writer, src []byte) error {
+	// the formatter only sees the media type, so the boundary is read from
+	// the first delimiter line
+	first, _, _ := bytes.Cut(src, []byte("\n"))
+	boundary, ok := strings.CutPrefix(strings.TrimSpace(string(first)), "--")
+	if !ok || boundary == "" {
+		return errors.New("multipart boundary not found")
+	}
+	maxValue := f.MaxValue
+	if maxValue <= 0 {
+		maxValue = 256
+	}
+	policy := GetRedactionPolicy()
+	reader := multipart.NewReader(bytes.NewReader(src), boundary)
+	for i := 1; ; i++ {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return err
+		}
+		name := part.FormName()
+		if name == "" {
+			name = fmt.Sprintf("part %d", i)
+		}
+		contentType := part.Header.Get("Content-Type")
+		mediatype, _, _ := mime.ParseMediaType(contentType)
+		switch {
+		case part.FileName() != "":
+			fmt.Fprintf(w, "  %s: file %q (%s, %d bytes)\n", name, part.FileName(), orDefault(contentType, "application/octet-stream"), len(data))
+		case (mediatype == "" || strings.HasPrefix(mediatype, "text/")) && len(data) <= maxValue && !isBinaryData(data):
+			value := string(data)
+			if policy.IsSensitiveKey(name) {
+				value = policy.MaskValue(value)
+			}
+			fmt.Fprintf(w, "  %s=%s\n", name, value)
+		default:
+			fmt.Fprintf(w, "  %s: %s, %d bytes\n", name, orDefault(contentType, "text/plain"), len(data))
+		}
+	}
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// HexFormatter prints a hex dump preview of binary bodies.
+type HexFormatter struct {
+	// Max is the number of bytes dumped. Default: 256.
+	Max int
+}
+
+func (f *HexFormatter) Match(mediatype string) bool {
+	switch mediatype {
+	case "application/octet-stream", "application/pdf", "application/zip", "application/gzip", "application/x-gzip",
+		"application/wasm", "application/protobuf", "application/x-protobuf", "application/grpc", "application/grpc-web",
+		"application/grpc-web+proto", "application/grpc+proto", "application/cbor", "application/msgpack":
+		return true
+	}
+	major, _, _ := strings.Cut(mediatype, "/")
+	return major == "image" || major == "audio" || major == "video" || major == "font"
+}
+
+func (f *HexFormatter) Format(w io.Writer, src []byte) error {
+	limit := f.Max
+	if limit <= 0 {
+		limit = 256
+	}
+	fmt.Fprintf(w, "* binary body, %d bytes\n", len(src))
+	dumper := hex.Dumper(w)
+	_, _ = dumper.Write(src[:min(len(src), limit)])
+	_ = dumper.Close()
+	if len(src) > limit {
+		fmt.Fprintf(w, "… %d more bytes\n", len(src)-limit)
+	}
+	return nil
+}
+
+// isBinaryData returns true if data is not printable text.
+func isBinaryData(data []byte) bool {
+	for _, r := range string(data) {
+		if r == '�' || (r < ' ' && r != '\n' && r != '\r' && r != '\t') {
+			return true
+		}
+	}
+	return false
+}
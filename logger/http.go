@@ -36,7 +36,7 @@ func NewHttpLogger(logger Logger, rt http.RoundTripper) http.RoundTripper {
 		ResponseHeader: logger.IsLevelEnabled(5),
 		ResponseBody:   logger.IsLevelEnabled(7),
 		Colors:         true, // erase line if you don't like colors
-		Formatters:     []httpretty.Formatter{&httpretty.JSONFormatter{}},
+		Formatters:     BodyFormatters(),
 	}
 
 	l.SkipHeader(SensitiveHeaders)
@@ -70,7 +70,7 @@ func NewHttpLoggerWithLevels(logger Logger, rt http.RoundTripper, headerLevel, b
 		ResponseBody:    logger.IsLevelEnabled(bodyLevel),
 		Auth:            logger.IsLevelEnabled(headerLevel),
 		Colors:          true, // erase line if you don't like colors
-		Formatters:      []httpretty.Formatter{&httpretty.JSONFormatter{}},
+		Formatters:      BodyFormatters(),
 		MaxResponseBody: int64(properties.Int(4*1024, "http.log.response.body.length")),
 	}
 
@@ -121,6 +121,10 @@ type Logger struct {
 	// whose values should be printed redacted.
 	RedactedHeaders []string
 
+	// RedactURL, when set, is applied to request URLs before they are printed,
+	// e.g. to mask credentials and sensitive query parameters.
+	RedactURL func(*url.URL) *url.URL
+
 	// Auth set to print OAuth / AWSSigned requests with redacted Authorization header and additional information about the signature.
 	Auth bool
 
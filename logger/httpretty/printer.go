@@ -9,6 +9,7 @@ import (
 	"mime"
 	"net"
 	"net/http"
+	"net/url"
 	"slices"
 	"sort"
 	"strings"
@@ -94,16 +95,25 @@ func (p *printer) printRequest(req *http.Request) {
 	}
 }
 
+// requestURL returns the request URL with the logger's RedactURL applied.
+func (p *printer) requestURL(req *http.Request) *url.URL {
+	if p.logger.RedactURL == nil {
+		return req.URL
+	}
+	return p.logger.RedactURL(req.URL)
+}
+
 func (p *printer) printRequestInfo(req *http.Request) {
-	host := req.URL.Host
+	u := p.requestURL(req)
+	host := u.Host
 	if host == "" {
 		host = req.Host
 	}
 	p.printf("%s\n", p.format(color.FgBlue, host))
 	p.printf("%s %s\n",
 		p.format(color.FgBlue, color.Bold, req.Method),
-		p.format(color.FgYellow, req.URL.Path))
-	for key, values := range req.URL.Query() {
+		p.format(color.FgYellow, u.Path))
+	for key, values := range u.Query() {
 		p.printf("  %s: %s\n",
 			p.format(color.FgBlue, key),
 			p.format(color.FgYellow, strings.Join(values, ",")))
@@ -176,7 +186,7 @@ func (p *printer) printResponseBodyOut(resp *http.Response) {
 	if skip {
 		return
 	}
-	if contentType := resp.Header.Get("Content-Type"); contentType != "" && isBinaryMediatype(contentType) {
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && isBinaryMediatype(contentType) && !p.hasFormatter(contentType) {
 		p.println("* body contains binary data")
 		return
 	}
@@ -500,7 +510,7 @@ func (p *printer) printServerResponse(req *http.Request, rec *responseRecorder)
 	if skip {
 		return
 	}
-	if mediatype := req.Header.Get("Content-Type"); mediatype != "" && isBinaryMediatype(mediatype) {
+	if mediatype := req.Header.Get("Content-Type"); mediatype != "" && isBinaryMediatype(mediatype) && !p.hasFormatter(mediatype) {
 		p.println("* body contains binary data")
 		return
 	}
@@ -526,16 +536,15 @@ func (p *printer) printBodyReader(contentType string, r io.Reader) {
 		p.printf("* cannot read body: %v\n", p.format(color.FgRed, err.Error()))
 		return
 	}
-	if isBinary(body) {
-		p.println("* body contains binary data")
-		return
-	}
+	binary := isBinary(body)
 	for _, f := range p.logger.Formatters {
 		if ok := p.safeBodyMatch(f, mediatype); !ok {
 			continue
 		}
 		var formatted bytes.Buffer
 		switch err := p.safeBodyFormat(f, &formatted, body); {
+		case err != nil && binary:
+			p.printf("* body contains binary data that cannot be formatted: %v\n", p.format(color.FgRed, err.Error()))
 		case err != nil:
 			p.printf("* body cannot be formatted: %v\n%s\n", p.format(color.FgRed, err.Error()), string(body))
 		default:
@@ -543,10 +552,27 @@ func (p *printer) printBodyReader(contentType string, r io.Reader) {
 		}
 		return
 	}
+	if binary {
+		p.println("* body contains binary data")
+		return
+	}
 
 	p.println(string(body))
 }
 
+// hasFormatter returns true if a formatter matches the media type of
+// contentType, so binary bodies of that type are formatted, e.g. as a hex
+// dump, rather than skipped.
+func (p *printer) hasFormatter(contentType string) bool {
+	mediatype, _, _ := mime.ParseMediaType(contentType)
+	for _, f := range p.logger.Formatters {
+		if p.safeBodyMatch(f, mediatype) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *printer) safeBodyMatch(f Formatter, mediatype string) bool {
 	defer func() {
 		if e := recover(); e != nil {
@@ -674,13 +700,14 @@ func sortHeaderKeys(h http.Header, skipped map[string]struct{}) (int, []string)
 }
 
 func (p *printer) printRequestHeader(req *http.Request) {
-	uri := req.URL.String()
-	if req.URL.Host == "" {
+	u := p.requestURL(req)
+	uri := u.String()
+	if u.Host == "" {
 		host := req.Host
 		if host == "" {
 			host = req.Header.Get("Host")
 		}
-		scheme := req.URL.Scheme
+		scheme := u.Scheme
 		if scheme == "" {
 			if req.TLS != nil {
 				scheme = "https"
@@ -688,7 +715,7 @@ func (p *printer) printRequestHeader(req *http.Request) {
 				scheme = "http"
 			}
 		}
-		uri = fmt.Sprintf("%s://%s%s", scheme, host, req.URL.RequestURI())
+		uri = fmt.Sprintf("%s://%s%s", scheme, host, u.RequestURI())
 	}
 
 	proto := ""
@@ -737,7 +764,7 @@ func (p *printer) printRequestBody(req *http.Request) {
 	if skip {
 		return
 	}
-	if mediatype := req.Header.Get("Content-Type"); mediatype != "" && isBinaryMediatype(mediatype) {
+	if mediatype := req.Header.Get("Content-Type"); mediatype != "" && isBinaryMediatype(mediatype) && !p.hasFormatter(mediatype) {
 		p.println("* body contains binary data")
 		return
 	}
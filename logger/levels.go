@@ -0,0 +1,320 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/properties"
+)
+
+// LoggerLevel is the level of a named logger, as listed by LevelHandler.
+type LoggerLevel struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+	// RevertAt and RevertTo are set while a change with a TTL is active.
+	RevertAt *time.Time `json:"revertAt,omitempty"`
+	RevertTo string     `json:"revertTo,omitempty"`
+}
+
+// LevelChange describes a level change made through a LevelHandler.
+type LevelChange struct {
+	Name string
+	From string
+	To   string
+	// TTL is how long the change lasts, 0 if it is permanent.
+	TTL time.Duration
+	// User is the basic auth user or remote address of the request, or
+	// "ttl" when the change is an automatic revert.
+	User string
+	Time time.Time
+}
+
+func (c LevelChange) String() string {
+	s := fmt.Sprintf("log level for %s changed from %s to %s by %s", c.Name, c.From, c.To, c.User)
+	if c.TTL > 0 {
+		s += fmt.Sprintf(" for %s", c.TTL)
+	}
+	return s
+}
+
+// LevelHandler is an http.Handler to inspect and change logger levels at
+// runtime:
+//
+//	GET               lists every named logger and its level
+//	PUT|POST          ?name=db&level=debug&ttl=10m, or the same fields as a JSON body
+//	DELETE            ?name=db reverts a change with a TTL immediately
+//
+// Only DELETE of an existing change and PUT or POST with a valid logger
+// name and level create or modify loggers.
+//
+// Changes are made by setting the log.level.<name> property (log.level for
+// the root logger) on Properties, so property listeners see them, and are
+// audit logged. The handler does no authentication: mount it behind
+// whatever protects the service's other admin endpoints.
+type LevelHandler struct {
+	// Properties receives level changes. Default: properties.Global.
+	Properties *properties.Properties
+
+	// OnChange is called after every change and revert, in addition to the
+	// audit log.
+	OnChange func(LevelChange)
+
+	mu      sync.Mutex
+	reverts map[string]*levelRevert
+}
+
+type levelRevert struct {
+	to string
+	// override is false if the level was not set at runtime before the
+	// change, so reverting removes the runtime value instead of setting it
+	override bool
+	at       time.Time
+	timer    *time.Timer
+}
+
+// NewLevelHandler returns a LevelHandler changing properties.Global.
+func NewLevelHandler() *LevelHandler {
+	return &LevelHandler{}
+}
+
+type levelRequest struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+	TTL   string `json:"ttl"`
+}
+
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.Levels())
+	case http.MethodPut, http.MethodPost:
+		req := levelRequest{
+			Name:  r.URL.Query().Get("name"),
+			Level: r.URL.Query().Get("level"),
+			TTL:   r.URL.Query().Get("ttl"),
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			d, err := duration.ParseDuration(req.TTL)
+			if err != nil || d < 0 {
+				http.Error(w, fmt.Sprintf("invalid ttl %q", req.TTL), http.StatusBadRequest)
+				return
+			}
+			ttl = time.Duration(d)
+		}
+		level, err := h.SetLevel(req.Name, req.Level, ttl, requestUser(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, level)
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if !h.Revert(name, requestUser(r)) {
+			http.Error(w, fmt.Sprintf("no temporary level set for %q", name), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.level(loggerName(name)))
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Levels returns every named logger and its level, sorted by name.
+func (h *LevelHandler) Levels() []LoggerLevel {
+	var levels []LoggerLevel
+	for name := range GetNamedLoggingLevels() {
+		levels = append(levels, h.level(name))
+	}
+	slices.SortFunc(levels, func(a, b LoggerLevel) int { return strings.Compare(a.Name, b.Name) })
+	return levels
+}
+
+// SetLevel sets the level of the named logger ("" or root for the root
+// logger). With a ttl > 0 the previous level is restored once it expires;
+// a later change to the same logger cancels the pending revert, although a
+// later change with a ttl still reverts to the level before the first one.
+func (h *LevelHandler) SetLevel(name, level string, ttl time.Duration, user string) (LoggerLevel, error) {
+	if !isValidLevel(level) {
+		return LoggerLevel{}, fmt.Errorf("invalid log level %q", level)
+	}
+	level = ParseLevel(nil, level).String()
+	name = loggerName(name)
+	if name != rootName && !validLoggerName.MatchString(name) {
+		return LoggerLevel{}, fmt.Errorf("invalid logger name %q", name)
+	}
+
+	h.mu.Lock()
+	from := h.current(name)
+	revertTo, override := from, h.properties().Origin(levelKey(name)) == properties.RuntimeSource
+	if pending, ok := h.reverts[name]; ok {
+		pending.timer.Stop()
+		revertTo, override = pending.to, pending.override
+		delete(h.reverts, name)
+	}
+	if ttl > 0 {
+		if h.reverts == nil {
+			h.reverts = map[string]*levelRevert{}
+		}
+		h.reverts[name] = &levelRevert{
+			to:       revertTo,
+			override: override,
+			at:       time.Now().Add(ttl),
+			timer:    time.AfterFunc(ttl, func() { h.expire(name) }),
+		}
+	}
+	h.mu.Unlock()
+	// property listeners run synchronously, outside h.mu
+	h.apply(name, level, true)
+
+	h.audit(LevelChange{Name: name, From: from, To: level, TTL: ttl, User: user, Time: time.Now()})
+	return h.level(name), nil
+}
+
+// Revert restores the level a temporary change replaced, returning false if
+// the named logger has no pending revert.
+func (h *LevelHandler) Revert(name, user string) bool {
+	return h.revert(loggerName(name), user, false)
+}
+
+func (h *LevelHandler) expire(name string) {
+	h.revert(name, "ttl", true)
+}
+
+func (h *LevelHandler) revert(name, user string, expired bool) bool {
+	h.mu.Lock()
+	pending, ok := h.reverts[name]
+	if !ok || (expired && time.Now().Before(pending.at)) {
+		// nothing pending, or replaced by a later change as the timer fired
+		h.mu.Unlock()
+		return false
+	}
+	pending.timer.Stop()
+	delete(h.reverts, name)
+	from := h.current(name)
+	h.mu.Unlock()
+	h.apply(name, pending.to, pending.override)
+
+	h.audit(LevelChange{Name: name, From: from, To: pending.to, User: user, Time: time.Now()})
+	return true
+}
+
+func (h *LevelHandler) properties() *properties.Properties {
+	if h.Properties != nil {
+		return h.Properties
+	}
+	return properties.Global
+}
+
+// levelKey returns the property holding the level of the named logger.
+func levelKey(name string) string {
+	if name == rootName {
+		return "log.level"
+	}
+	return "log.level." + name
+}
+
+// apply sets the level property, firing listeners, and the logger's level in
+// case the property is not the one loggers listen to. Without override, the
+// runtime value of the property is removed instead, so the level of the
+// property's sources, if any, applies again.
+func (h *LevelHandler) apply(name, level string, override bool) {
+	props := h.properties()
+	if override {
+		props.Set(levelKey(name), level)
+	} else {
+		props.Set(levelKey(name), "")
+		if v := props.Get(levelKey(name)); v != "" {
+			level = v
+		}
+	}
+	if name == rootName {
+		GetLogger().SetLogLevel(level)
+		if currentLogger != nil {
+			currentLogger.SetLogLevel(level)
+		}
+		return
+	}
+	GetLogger(name).SetLogLevel(level)
+}
+
+func (h *LevelHandler) current(name string) string {
+	if name == rootName {
+		return GetLogger().GetLevel().String()
+	}
+	return GetLogger(name).GetLevel().String()
+}
+
+func (h *LevelHandler) level(name string) LoggerLevel {
+	level := LoggerLevel{Name: name, Level: h.current(name)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if pending, ok := h.reverts[name]; ok {
+		at := pending.at
+		level.RevertAt, level.RevertTo = &at, pending.to
+	}
+	return level
+}
+
+func (h *LevelHandler) audit(change LevelChange) {
+	GetLogger().V(Info).Always().WithValues(
+		"name", change.Name, "from", change.From, "to", change.To, "user", change.User,
+	).Infof("%s", change)
+	if h.OnChange != nil {
+		h.OnChange(change)
+	}
+}
+
+// validLoggerName matches the names SetLevel accepts, once normalized by
+// loggerName, so a request cannot create loggers with arbitrary names.
+var validLoggerName = regexp.MustCompile(`^[a-z0-9][a-z0-9._ -]{0,127}$`)
+
+// loggerName normalizes a name the way GetLogger does, without creating the
+// logger.
+func loggerName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" || name == rootName {
+		return rootName
+	}
+	return loggerPath(name)
+}
+
+func isValidLevel(level string) bool {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if _, err := strconv.Atoi(level); err == nil {
+		return true
+	}
+	if n, ok := strings.CutPrefix(level, "trace"); ok {
+		_, err := strconv.Atoi(n)
+		return n == "" || err == nil
+	}
+	return slices.Contains([]string{"debug", "info", "warn", "error", "fatal", "silent"}, level)
+}
+
+func requestUser(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok && user != "" {
+		return user
+	}
+	return r.RemoteAddr
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
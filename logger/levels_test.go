@@ -0,0 +1,181 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flanksource/commons/properties"
+)
+
+func TestLevelHandler(t *testing.T) {
+	t.Cleanup(func() { properties.Set("log.level.level-handler", "") })
+	var mu sync.Mutex
+	var changes []LevelChange
+	h := &LevelHandler{OnChange: func(c LevelChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, c)
+	}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	lg := GetLogger("level-handler")
+	lg.SetLogLevel("info")
+
+	do := func(method, query, body string) (*http.Response, LoggerLevel) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+"?"+query, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.SetBasicAuth("alice", "")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var level LoggerLevel
+		_ = json.NewDecoder(resp.Body).Decode(&level)
+		return resp, level
+	}
+
+	resp, level := do(http.MethodPut, "name=level-handler&level=debug&ttl=100ms", "")
+	if resp.StatusCode != http.StatusOK || level.Level != "debug" || level.RevertTo != "info" || level.RevertAt == nil {
+		t.Fatalf("unexpected response %d %+v", resp.StatusCode, level)
+	}
+	if !lg.IsDebugEnabled() || properties.Get("log.level.level-handler") != "debug" {
+		t.Error("the logger level and property should both be updated")
+	}
+
+	resp, _ = http.Get(srv.URL)
+	var levels []LoggerLevel
+	_ = json.NewDecoder(resp.Body).Decode(&levels)
+	resp.Body.Close()
+	var listed, root bool
+	for _, l := range levels {
+		listed = listed || (l.Name == "level-handler" && l.Level == "debug" && l.RevertAt != nil)
+		root = root || l.Name == rootName
+	}
+	if !listed || !root {
+		t.Errorf("listing should include the root and changed loggers: %+v", levels)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for lg.IsDebugEnabled() {
+		if time.Now().After(deadline) {
+			t.Fatal("level was not reverted after the ttl")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if origin := properties.Global.Origin("log.level.level-handler"); origin == properties.RuntimeSource {
+		t.Errorf("revert should remove the runtime property set by the change, got %q", properties.Get("log.level.level-handler"))
+	}
+
+	mu.Lock()
+	if len(changes) != 2 || changes[0].User != "alice" || changes[0].TTL != 100*time.Millisecond ||
+		changes[1].User != "ttl" || changes[1].From != "debug" || changes[1].To != "info" {
+		t.Errorf("unexpected audit trail %+v", changes)
+	}
+	mu.Unlock()
+
+	if resp, level := do(http.MethodPost, "", `{"name":"level-handler","level":"trace","ttl":"1h"}`); resp.StatusCode != http.StatusOK || level.Level != "trace" {
+		t.Errorf("json body: %d %+v", resp.StatusCode, level)
+	}
+	if resp, level := do(http.MethodDelete, "name=level-handler", ""); resp.StatusCode != http.StatusOK || level.Level != "info" || level.RevertAt != nil {
+		t.Errorf("delete should revert immediately: %d %+v", resp.StatusCode, level)
+	}
+	if resp, _ := do(http.MethodDelete, "name=level-handler", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("delete without a pending revert: %d", resp.StatusCode)
+	}
+	if resp, _ := do(http.MethodPut, "name=level-handler&level=loud", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid level: %d", resp.StatusCode)
+	}
+	if resp, _ := do(http.MethodPut, "name=level-handler&level=debug&ttl=soon", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid ttl: %d", resp.StatusCode)
+	}
+	if resp, _ := do(http.MethodPatch, "", ""); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("patch: %d", resp.StatusCode)
+	}
+
+	// requests must not create loggers, except a valid PUT
+	if resp, _ := do(http.MethodDelete, "name=level-handler-unknown", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("delete of an unknown logger: %d", resp.StatusCode)
+	}
+	if resp, _ := do(http.MethodPut, "name=%3Cscript%3E&level=debug", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid name: %d", resp.StatusCode)
+	}
+	for _, name := range []string{"level-handler-unknown", "<script>"} {
+		if _, ok := namedLoggers.Load(name); ok {
+			t.Errorf("logger %q was created", name)
+		}
+	}
+}
+
+func TestLevelHandler_ChainedTTLRevertsToOriginal(t *testing.T) {
+	t.Cleanup(func() { properties.Set("log.level.level-chain", "") })
+	h := NewLevelHandler()
+	lg := GetLogger("level-chain")
+	lg.SetLogLevel("warn")
+
+	if _, err := h.SetLevel("level-chain", "info", time.Hour, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.SetLevel("level-chain", "debug", time.Hour, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if !h.Revert("level-chain", "test") || lg.GetLevel() != Warn {
+		t.Errorf("expected the level before the first change, got %s", lg.GetLevel())
+	}
+}
+
+func TestLevelHandler_RevertKeepsRuntimeOverride(t *testing.T) {
+	p := &properties.Properties{}
+	h := &LevelHandler{Properties: p}
+	lg := GetLogger("level-override")
+	p.Set("log.level.level-override", "warn")
+	lg.SetLogLevel("warn")
+
+	if _, err := h.SetLevel("level-override", "debug", time.Hour, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if !h.Revert("level-override", "test") {
+		t.Fatal("expected a pending revert")
+	}
+	if p.Get("log.level.level-override") != "warn" || p.Origin("log.level.level-override") != properties.RuntimeSource || lg.GetLevel() != Warn {
+		t.Errorf("revert should restore the runtime value set before the change, got %q from %q",
+			p.Get("log.level.level-override"), p.Origin("log.level.level-override"))
+	}
+}
+
+func TestLevelHandler_RevertRestoresSourceLevel(t *testing.T) {
+	p := &properties.Properties{}
+	if err := p.AddSource(properties.MapSource{Values: map[string]string{"log.level.level-source": "error"}}, properties.PrecedenceFile); err != nil {
+		t.Fatal(err)
+	}
+	h := &LevelHandler{Properties: p}
+	lg := GetLogger("level-source")
+	lg.SetLogLevel("error")
+
+	if _, err := h.SetLevel("level-source", "debug", time.Hour, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if p.Origin("log.level.level-source") != properties.RuntimeSource {
+		t.Fatal("the change should be a runtime value")
+	}
+	// the file changes while the temporary level is active
+	if err := p.AddSource(properties.MapSource{Values: map[string]string{"log.level.level-source": "warn"}}, properties.PrecedenceFile); err != nil {
+		t.Fatal(err)
+	}
+	if !h.Revert("level-source", "test") {
+		t.Fatal("expected a pending revert")
+	}
+	if p.Origin("log.level.level-source") != "defaults" || p.Get("log.level.level-source") != "warn" || lg.GetLevel() != Warn {
+		t.Errorf("revert should apply the source's level again, got %q from %q, logger at %s",
+			p.Get("log.level.level-source"), p.Origin("log.level.level-source"), lg.GetLevel())
+	}
+}
@@ -137,6 +137,8 @@ const (
 	Cyan      = cyan + Normal
 	magenta   = "\x1b[35"
 	Magenta   = magenta + Normal
+	red       = "\x1b[31"
+	Red       = red + Normal
 	DarkWhite = "\x1b[38;5;244m"
 	Normal    = "m"
 	Reset     = "\x1b[0m"
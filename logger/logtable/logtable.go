@@ -0,0 +1,76 @@
+// Package logtable renders logger.BufferedLogger entries as clicky tables.
+// It is separate from the logger package as clicky depends on it:
+//
+//	clicky.MustPrint(logtable.Table(recorder.Query(logger.LogQuery{Last: 50})))
+package logtable
+
+import (
+	"slices"
+	"time"
+
+	"github.com/flanksource/clicky/api"
+	"github.com/flanksource/commons/logger"
+)
+
+// Entry is a buffered log entry as a table row, with its fields as the
+// expandable row detail.
+type Entry logger.BufferedLogEntry
+
+// Table returns a TextTable of the entries, oldest first.
+func Table(entries logger.BufferedLogEntries) api.TextTable {
+	rows := make([]Entry, len(entries))
+	for i, e := range entries {
+		rows[i] = Entry(e)
+	}
+	return api.NewTableFrom(rows)
+}
+
+func levelStyle(level logger.LogLevel) string {
+	switch {
+	case level <= logger.Error:
+		return "text-red-500 font-bold"
+	case level == logger.Warn:
+		return "text-yellow-500 font-bold"
+	case level == logger.Info:
+		return "text-green-500"
+	default:
+		return "text-muted"
+	}
+}
+
+// Columns implements api.TableProvider.
+func (e Entry) Columns() []api.ColumnDef {
+	return []api.ColumnDef{
+		api.Column("time").Label("Time").Build(),
+		api.Column("level").Label("Level").Build(),
+		api.Column("logger").Label("Logger").Build(),
+		api.Column("message").Label("Message").MaxWidth(120).Build(),
+	}
+}
+
+// Row implements api.TableProvider.
+func (e Entry) Row() map[string]any {
+	return map[string]any{
+		"time":    api.Text{}.AddText(e.Time.Format(time.TimeOnly+".000"), "text-muted"),
+		"level":   api.Text{}.AddText(e.Level.String(), levelStyle(e.Level)),
+		"logger":  e.Logger,
+		"message": e.Message,
+	}
+}
+
+// RowDetail implements api.DetailProvider, listing the entry's fields.
+func (e Entry) RowDetail() api.Textable {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	items := make([]api.KeyValuePair, len(keys))
+	for i, k := range keys {
+		items[i] = api.KeyValuePair{Key: k, Value: e.Fields[k]}
+	}
+	return api.DescriptionList{Items: items}
+}
@@ -0,0 +1,28 @@
+package logtable_test
+
+import (
+	"testing"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/commons/logger/logtable"
+)
+
+func TestTable(t *testing.T) {
+	b := logger.NewBufferedLogger(10)
+	b.Named("db").WithValues("table", "users").Infof("query")
+	b.Errorf("failed")
+
+	table := logtable.Table(b.Query(logger.LogQuery{}))
+	if len(table.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(table.Rows))
+	}
+	if len(table.Headers) != 4 {
+		t.Fatalf("expected 4 headers, got %d", len(table.Headers))
+	}
+	if table.RowDetail == nil || table.RowDetail[0] == nil {
+		t.Fatal("expected the fields as the first row's detail")
+	}
+	if table.RowDetail[1] != nil {
+		t.Error("expected no detail for an entry without fields")
+	}
+}
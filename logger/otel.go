@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+// NewOTelSink returns a sink emitting records at level (anything ParseLevel
+// accepts) and above through the OpenTelemetry Logs API. Each named logger
+// becomes an instrumentation scope, WithValues pairs become attributes, and
+// the span in the logging context (see WithContext) is attached by the SDK.
+func NewOTelSink(provider otellog.LoggerProvider, level any) *Sink {
+	return &Sink{Name: "otel", LoggerProvider: provider, Level: ParseLevel(nil, level).Slog()}
+}
+
+// OTelSeverity maps a slog level to an OpenTelemetry severity number. Each
+// slog level above a named level moves one step up the OTel range (INFO2 for
+// slog.LevelInfo+1), SlogFatal maps to FATAL, and trace levels count down from
+// TRACE4 at SlogTraceLevel to TRACE.
+func OTelSeverity(level slog.Level) otellog.Severity {
+	step := func(base otellog.Severity, offset slog.Level) otellog.Severity {
+		return base + otellog.Severity(min(offset, 3))
+	}
+	switch {
+	case level >= SlogFatal:
+		return step(otellog.SeverityFatal, level-SlogFatal)
+	case level >= slog.LevelError:
+		return otellog.SeverityError
+	case level >= slog.LevelWarn:
+		return step(otellog.SeverityWarn, level-slog.LevelWarn)
+	case level >= slog.LevelInfo:
+		return step(otellog.SeverityInfo, level-slog.LevelInfo)
+	case level >= slog.LevelDebug:
+		return step(otellog.SeverityDebug, level-slog.LevelDebug)
+	default:
+		return otellog.SeverityTrace4 - otellog.Severity(min(SlogTraceLevel-level, 3))
+	}
+}
+
+// otelHandler emits records through an OpenTelemetry LoggerProvider, using
+// the "logger" attribute added by SlogLogger as the instrumentation scope.
+type otelHandler struct {
+	provider otellog.LoggerProvider
+	loggers  *sync.Map // scope name -> otellog.Logger
+	attrs    []otellog.KeyValue
+	group    string
+}
+
+func newOTelHandler(provider otellog.LoggerProvider) *otelHandler {
+	return &otelHandler{provider: provider, loggers: &sync.Map{}}
+}
+
+func (h *otelHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *otelHandler) Handle(ctx context.Context, r slog.Record) error {
+	scope := rootName
+	var record otellog.Record
+	record.SetTimestamp(r.Time)
+	record.SetObservedTimestamp(time.Now())
+	record.SetSeverity(OTelSeverity(r.Level))
+	record.SetSeverityText(FromSlogLevel(r.Level).String())
+	record.SetBody(otellog.StringValue(r.Message))
+	record.AddAttributes(h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "logger":
+			scope = a.Value.String()
+		case TraceIDKey, SpanIDKey:
+			// the SDK sets the record's trace context from ctx
+		default:
+			if kv, ok := otelAttr(h.group, a); ok {
+				record.AddAttributes(kv)
+			}
+		}
+		return true
+	})
+	h.logger(scope).Emit(ctx, record)
+	return nil
+}
+
+func (h *otelHandler) logger(scope string) otellog.Logger {
+	if l, ok := h.loggers.Load(scope); ok {
+		return l.(otellog.Logger)
+	}
+	l, _ := h.loggers.LoadOrStore(scope, h.provider.Logger(scope))
+	return l.(otellog.Logger)
+}
+
+func (h *otelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *h
+	derived.attrs = h.attrs[:len(h.attrs):len(h.attrs)]
+	for _, a := range attrs {
+		if kv, ok := otelAttr(h.group, a); ok {
+			derived.attrs = append(derived.attrs, kv)
+		}
+	}
+	return &derived
+}
+
+func (h *otelHandler) WithGroup(name string) slog.Handler {
+	derived := *h
+	derived.group = h.group + name + "."
+	return &derived
+}
+
+// otelAttr converts a redacted slog attribute to an OTel attribute, with the
+// group prefix added to its key.
+func otelAttr(prefix string, a slog.Attr) (otellog.KeyValue, bool) {
+	a.Value = a.Value.Resolve()
+	a = redactAttr(nil, a)
+	if a.Equal(slog.Attr{}) {
+		return otellog.KeyValue{}, false
+	}
+	return otellog.KeyValue{Key: prefix + a.Key, Value: otelValue(a.Value)}, true
+}
+
+func otelValue(v slog.Value) otellog.Value {
+	switch v.Kind() {
+	case slog.KindBool:
+		return otellog.BoolValue(v.Bool())
+	case slog.KindInt64:
+		return otellog.Int64Value(v.Int64())
+	case slog.KindUint64:
+		return otellog.Int64Value(int64(v.Uint64()))
+	case slog.KindFloat64:
+		return otellog.Float64Value(v.Float64())
+	case slog.KindString:
+		return otellog.StringValue(v.String())
+	case slog.KindDuration:
+		return otellog.StringValue(v.Duration().String())
+	case slog.KindTime:
+		return otellog.StringValue(v.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		var kvs []otellog.KeyValue
+		for _, a := range v.Group() {
+			if kv, ok := otelAttr("", a); ok {
+				kvs = append(kvs, kv)
+			}
+		}
+		return otellog.MapValue(kvs...)
+	default:
+		if b, ok := v.Any().([]byte); ok {
+			return otellog.BytesValue(b)
+		}
+		return otellog.StringValue(fmt.Sprint(v.Any()))
+	}
+}
@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// memoryExporter keeps exported log records in memory.
+type memoryExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *memoryExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error   { return nil }
+func (e *memoryExporter) ForceFlush(context.Context) error { return nil }
+
+func (e *memoryExporter) Records() []sdklog.Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]sdklog.Record(nil), e.records...)
+}
+
+func newOTelLogs(t *testing.T) (*sdklog.LoggerProvider, *memoryExporter) {
+	t.Helper()
+	exporter := &memoryExporter{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return provider, exporter
+}
+
+func TestOTelSeverity(t *testing.T) {
+	for level, want := range map[slog.Level]otellog.Severity{
+		Trace4.Slog():       otellog.SeverityTrace1,
+		Trace2.Slog():       otellog.SeverityTrace2,
+		Trace1.Slog():       otellog.SeverityTrace3,
+		SlogTraceLevel:      otellog.SeverityTrace4,
+		slog.LevelDebug:     otellog.SeverityDebug,
+		slog.LevelInfo:      otellog.SeverityInfo,
+		slog.LevelInfo + 1:  otellog.SeverityInfo2,
+		slog.LevelWarn:      otellog.SeverityWarn,
+		slog.LevelError:     otellog.SeverityError,
+		SlogFatal:           otellog.SeverityFatal,
+		SlogFatal + 10:      otellog.SeverityFatal4,
+		slog.Level(-100000): otellog.SeverityTrace1,
+	} {
+		if got := OTelSeverity(level); got != want {
+			t.Errorf("OTelSeverity(%v) = %v, want %v", level, got, want)
+		}
+	}
+}
+
+func TestOTelSink(t *testing.T) {
+	provider, exporter := newOTelLogs(t)
+	useSinks(t, NewOTelSink(provider, "trace"))
+	ctx, _, end := startSpan(t)
+	defer end()
+
+	lg := New("otel-bridge")
+	lg.SetLogLevel("trace")
+	lg.Tracef("tracing")
+	lg.WithValues("user", "alice", "attempt", 2).Warnf("retrying")
+	WithContext(lg, ctx).Errorf("failed")
+	lg.Fatalf("giving up")
+	New("").Infof("from root")
+
+	records := exporter.Records()
+	if len(records) != 5 {
+		t.Fatalf("expected 5 records, got %d", len(records))
+	}
+	for i, want := range []struct {
+		body     string
+		severity otellog.Severity
+		scope    string
+	}{
+		{"tracing", otellog.SeverityTrace4, "otel-bridge"},
+		{"retrying", otellog.SeverityWarn, "otel-bridge"},
+		{"failed", otellog.SeverityError, "otel-bridge"},
+		{"giving up", otellog.SeverityFatal, "otel-bridge"},
+		{"from root", otellog.SeverityInfo, rootName},
+	} {
+		r := records[i]
+		if r.Body().AsString() != want.body || r.Severity() != want.severity || r.InstrumentationScope().Name != want.scope {
+			t.Errorf("record %d = %q %v scope=%q, want %q %v scope=%q", i,
+				r.Body().AsString(), r.Severity(), r.InstrumentationScope().Name, want.body, want.severity, want.scope)
+		}
+	}
+
+	attrs := map[string]otellog.Value{}
+	records[1].WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	if attrs["user"].AsString() != "alice" || attrs["attempt"].AsInt64() != 2 {
+		t.Errorf("WithValues pairs should become attributes, got %v", attrs)
+	}
+	if _, ok := attrs["logger"]; ok {
+		t.Error("the logger name should be the scope, not an attribute")
+	}
+
+	sc := trace.SpanContextFromContext(ctx)
+	if records[2].TraceID() != sc.TraceID() || records[2].SpanID() != sc.SpanID() {
+		t.Error("records logged with a span context should carry its ids")
+	}
+	records[2].WalkAttributes(func(kv otellog.KeyValue) bool {
+		if kv.Key == TraceIDKey || kv.Key == SpanIDKey {
+			t.Errorf("trace ids should not be duplicated as attribute %s", kv.Key)
+		}
+		return true
+	})
+}
+
+func TestParseSink_OTel(t *testing.T) {
+	provider, exporter := newOTelLogs(t)
+	previous := global.GetLoggerProvider()
+	global.SetLoggerProvider(provider)
+	t.Cleanup(func() { global.SetLoggerProvider(previous) })
+
+	sink, err := ParseSink("otel?level=warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useSinks(t, sink)
+	lg := New("otel-global")
+	lg.Infof("filtered")
+	lg.Warnf("exported")
+
+	if records := exporter.Records(); len(records) != 1 || records[0].Body().AsString() != "exported" {
+		t.Errorf("expected only the warning through the global provider, got %d records", len(records))
+	}
+}
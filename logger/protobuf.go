@@ -0,0 +1,190 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufFormatter prints protobuf, gRPC and gRPC-web bodies as JSON, with
+// sensitive fields masked, using message types from a descriptor set. It is
+// not a default formatter, register one with RegisterBodyFormatter:
+//
+//	set, _ := os.ReadFile("api.pb") // protoc --include_imports --descriptor_set_out=api.pb
+//	f, err := logger.NewProtobufFormatter(set)
+//	logger.RegisterBodyFormatter(f)
+type ProtobufFormatter struct {
+	Files *protoregistry.Files
+	// MessageTypes are the types a message may be, as a formatter does not
+	// know the gRPC method or direction of a body. The type decoding the
+	// message without unknown fields and with the most fields set is
+	// printed. Default: every message type in Files.
+	MessageTypes []protoreflect.FullName
+}
+
+// NewProtobufFormatter returns a ProtobufFormatter for the messages of a
+// serialized FileDescriptorSet, optionally limited to messageTypes.
+func NewProtobufFormatter(descriptorSet []byte, messageTypes ...string) (*ProtobufFormatter, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(descriptorSet, &set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	f := &ProtobufFormatter{Files: files}
+	for _, name := range messageTypes {
+		if _, err := files.FindDescriptorByName(protoreflect.FullName(name)); err != nil {
+			return nil, fmt.Errorf("message type %s: %w", name, err)
+		}
+		f.MessageTypes = append(f.MessageTypes, protoreflect.FullName(name))
+	}
+	return f, nil
+}
+
+func (f *ProtobufFormatter) Match(mediatype string) bool {
+	switch mediatype {
+	case "application/protobuf", "application/x-protobuf", "application/vnd.google.protobuf",
+		"application/grpc", "application/grpc+proto",
+		"application/grpc-web", "application/grpc-web+proto",
+		"application/grpc-web-text", "application/grpc-web-text+proto":
+		return true
+	}
+	return false
+}
+
+// Format prints each gRPC frame of src, or src as a single message when it is
+// not framed.
+func (f *ProtobufFormatter) Format(w io.Writer, src []byte) error {
+	if decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(src))); err == nil {
+		if _, ok := grpcFrames(decoded); ok {
+			src = decoded // grpc-web-text
+		}
+	}
+	frames, ok := grpcFrames(src)
+	if !ok {
+		return f.formatMessage(w, src)
+	}
+	for i, frame := range frames {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		switch {
+		case frame.flags&0x80 != 0:
+			fmt.Fprintf(w, "trailers:\n%s", indentLines(strings.TrimSpace(GetRedactionPolicy().RedactText(string(frame.data)))))
+		case frame.flags&0x01 != 0:
+			fmt.Fprintf(w, "* compressed message, %d bytes", len(frame.data))
+		default:
+			if err := f.formatMessage(w, frame.data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *ProtobufFormatter) formatMessage(w io.Writer, data []byte) error {
+	types := dynamicpb.NewTypes(f.Files)
+	var best *dynamicpb.Message
+	bestFields := -1
+	for _, name := range f.messageTypes() {
+		desc, err := f.Files.FindDescriptorByName(name)
+		if err != nil {
+			continue
+		}
+		md, ok := desc.(protoreflect.MessageDescriptor)
+		if !ok {
+			continue
+		}
+		msg := dynamicpb.NewMessage(md)
+		if err := (proto.UnmarshalOptions{Resolver: types}).Unmarshal(data, msg); err != nil || len(msg.GetUnknown()) > 0 {
+			continue
+		}
+		fields := 0
+		msg.Range(func(protoreflect.FieldDescriptor, protoreflect.Value) bool {
+			fields++
+			return true
+		})
+		if fields > bestFields {
+			best, bestFields = msg, fields
+		}
+	}
+	if best == nil {
+		return fmt.Errorf("no message type in the descriptor set matches the %d byte body", len(data))
+	}
+	out, err := protojson.MarshalOptions{Resolver: types}.Marshal(best)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(GetRedactionPolicy().RedactJSON(string(out))), "", "    "); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s %s", best.Descriptor().FullName(), buf.String())
+	return nil
+}
+
+func (f *ProtobufFormatter) messageTypes() []protoreflect.FullName {
+	if len(f.MessageTypes) > 0 {
+		return f.MessageTypes
+	}
+	var names []protoreflect.FullName
+	f.Files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		names = appendMessageNames(names, file.Messages())
+		return true
+	})
+	slices.Sort(names)
+	return names
+}
+
+func appendMessageNames(names []protoreflect.FullName, messages protoreflect.MessageDescriptors) []protoreflect.FullName {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if !md.IsMapEntry() {
+			names = append(names, md.FullName())
+		}
+		names = appendMessageNames(names, md.Messages())
+	}
+	return names
+}
+
+type grpcFrame struct {
+	flags byte
+	data  []byte
+}
+
+// grpcFrames splits a body into length prefixed gRPC frames, returning false
+// if it is not exactly a sequence of frames.
+func grpcFrames(src []byte) ([]grpcFrame, bool) {
+	var frames []grpcFrame
+	for len(src) > 0 {
+		if len(src) < 5 || src[0]&^0x81 != 0 {
+			return nil, false
+		}
+		size := binary.BigEndian.Uint32(src[1:5])
+		if uint64(len(src)-5) < uint64(size) {
+			return nil, false
+		}
+		frames = append(frames, grpcFrame{flags: src[0], data: src[5 : 5+size]})
+		src = src[5+size:]
+	}
+	return frames, len(frames) > 0
+}
+
+func indentLines(s string) string {
+	return "    " + strings.ReplaceAll(s, "\n", "\n    ")
+}
@@ -0,0 +1,644 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/flanksource/commons/collections"
+	"github.com/flanksource/commons/properties"
+	"sigs.k8s.io/yaml"
+)
+
+// MaskStyle selects how a RedactionPolicy replaces a secret value.
+type MaskStyle string
+
+const (
+	// MaskPartial keeps a few characters of the value to aid troubleshooting,
+	// see PrintableSecret.
+	MaskPartial MaskStyle = "partial"
+
+	// MaskHash replaces the value with a short SHA-256 prefix, so identical
+	// secrets can be correlated across log lines without being revealed.
+	MaskHash MaskStyle = "hash"
+
+	// MaskFull replaces the value with ****.
+	MaskFull MaskStyle = "full"
+)
+
+// TokenPatterns are the well-known credential formats a RedactionPolicy
+// masks wherever they appear, even under keys or headers not otherwise
+// considered sensitive.
+var TokenPatterns = map[string]string{
+	"jwt":         `eyJ[A-Za-z0-9_-]{4,}\.eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]*`,
+	"aws":         `\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`,
+	"github":      `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`,
+	"slack":       `\bxox[abposr]-[A-Za-z0-9-]{10,}`,
+	"bearer":      `(?i)\bbearer\s+[A-Za-z0-9._~+/-]{8,}=*`,
+	"private-key": `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+var tokenPatterns = func() []*regexp.Regexp {
+	var out []*regexp.Regexp
+	for _, p := range TokenPatterns {
+		out = append(out, regexp.MustCompile(p))
+	}
+	return out
+}()
+
+// RedactionPolicy declares what is considered secret, and how it is masked,
+// for every output that may carry credentials: log messages and fields, HAR
+// files, trace spans, HTTP request logs and curl commands.
+//
+// The zero value applies no rules beyond the built-in TokenPatterns; use
+// DefaultRedactionPolicy for the standard rules. A policy is loaded from
+// YAML with LoadRedactionPolicy, or from redact.* properties:
+//
+//	redact.headers=X-Internal-*
+//	redact.keys=ssn,iban
+//	redact.jsonPaths=customer.card.number,items[*].cvv
+//	redact.queryParams=sig,X-Amz-*
+//	redact.patterns=acme_[a-z0-9]{32}
+//	redact.mask=hash
+//	redact.file=/etc/app/redaction.yaml
+type RedactionPolicy struct {
+	// Headers are header name glob patterns (e.g. "X-*-Token") whose values
+	// are masked.
+	Headers []string `json:"headers,omitempty"`
+
+	// Keys are map keys, form fields, query parameters and key=value text
+	// keys considered sensitive. A key matches when it contains one of these
+	// (case-insensitive), or matches it as a glob when it contains *.
+	Keys []string `json:"keys,omitempty"`
+
+	// AllowKeys are exceptions to Keys, e.g. token_type.
+	AllowKeys []string `json:"allowKeys,omitempty"`
+
+	// JSONPaths are dotted paths into JSON bodies and maps whose values are
+	// masked regardless of their key, e.g. "customer.card.number". A *
+	// segment matches any key or array index: "items[*].cvv", "*.pin".
+	JSONPaths []string `json:"jsonPaths,omitempty"`
+
+	// QueryParams are URL query parameter glob patterns masked in URLs, on
+	// top of parameters named exactly as one of Keys. Names are compared
+	// case-insensitively.
+	QueryParams []string `json:"queryParams,omitempty"`
+
+	// Patterns are additional regular expressions masked anywhere in text.
+	Patterns []string `json:"patterns,omitempty"`
+
+	// DisableTokenPatterns turns off the built-in TokenPatterns.
+	DisableTokenPatterns bool `json:"disableTokenPatterns,omitempty"`
+
+	// Mask is the masking style, default MaskPartial.
+	Mask MaskStyle `json:"mask,omitempty"`
+
+	once     sync.Once
+	compiled []*regexp.Regexp
+	// anyPattern matches where any of compiled does, to skip text without
+	// secrets in a single pass
+	anyPattern *regexp.Regexp
+	jsonPaths  [][]string
+}
+
+// DefaultRedactionPolicy returns the standard rules, built from
+// CommonRedactedHeaders, SensitiveKeys, NonSensitiveKeys and
+// SensitiveQueryParams.
+func DefaultRedactionPolicy() *RedactionPolicy {
+	return &RedactionPolicy{
+		Headers:     append([]string{}, CommonRedactedHeaders...),
+		Keys:        append([]string{}, SensitiveKeys...),
+		AllowKeys:   append([]string{}, NonSensitiveKeys...),
+		QueryParams: append([]string{}, SensitiveQueryParams...),
+		Mask:        MaskPartial,
+	}
+}
+
+// ParseRedactionPolicy parses a YAML (or JSON) policy.
+func ParseRedactionPolicy(data []byte) (*RedactionPolicy, error) {
+	var p RedactionPolicy
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid redaction policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LoadRedactionPolicy reads a YAML policy from path. The rules are added to
+// DefaultRedactionPolicy, so a file only needs to list what is specific to
+// the application.
+func LoadRedactionPolicy(path string) (*RedactionPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParseRedactionPolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return DefaultRedactionPolicy().Merge(p), nil
+}
+
+// RedactionPolicyFromProperties returns DefaultRedactionPolicy extended with
+// the redact.* properties, including the YAML file named by redact.file.
+func RedactionPolicyFromProperties() (*RedactionPolicy, error) {
+	list := func(key string) []string {
+		var out []string
+		for _, v := range strings.Split(properties.String("", key), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+
+	p := DefaultRedactionPolicy().Merge(&RedactionPolicy{
+		Headers:              list("redact.headers"),
+		Keys:                 list("redact.keys"),
+		AllowKeys:            list("redact.allowKeys"),
+		JSONPaths:            list("redact.jsonPaths"),
+		QueryParams:          list("redact.queryParams"),
+		Patterns:             list("redact.patterns"),
+		DisableTokenPatterns: properties.On(false, "redact.disableTokenPatterns"),
+		Mask:                 MaskStyle(properties.String("", "redact.mask")),
+	})
+	if file := properties.String("", "redact.file"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fromFile, err := ParseRedactionPolicy(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		p = p.Merge(fromFile)
+	}
+	return p, p.Validate()
+}
+
+// Validate checks the mask style and that all patterns compile.
+func (p *RedactionPolicy) Validate() error {
+	switch p.Mask {
+	case "", MaskPartial, MaskHash, MaskFull:
+	default:
+		return fmt.Errorf("invalid redaction mask %q, expected one of: partial, hash, full", p.Mask)
+	}
+	for _, pattern := range p.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Merge returns a new policy with the rules of both p and other. Other's
+// Mask takes precedence when set; token patterns stay off if either policy
+// disables them.
+func (p *RedactionPolicy) Merge(other *RedactionPolicy) *RedactionPolicy {
+	merged := &RedactionPolicy{
+		Headers:              append(append([]string{}, p.Headers...), other.Headers...),
+		Keys:                 append(append([]string{}, p.Keys...), other.Keys...),
+		AllowKeys:            append(append([]string{}, p.AllowKeys...), other.AllowKeys...),
+		JSONPaths:            append(append([]string{}, p.JSONPaths...), other.JSONPaths...),
+		QueryParams:          append(append([]string{}, p.QueryParams...), other.QueryParams...),
+		Patterns:             append(append([]string{}, p.Patterns...), other.Patterns...),
+		DisableTokenPatterns: p.DisableTokenPatterns || other.DisableTokenPatterns,
+		Mask:                 p.Mask,
+	}
+	if other.Mask != "" {
+		merged.Mask = other.Mask
+	}
+	return merged
+}
+
+var (
+	redactionPolicy atomic.Pointer[RedactionPolicy]
+	// propertiesPolicy caches RedactionPolicyFromProperties until the next
+	// property update
+	propertiesPolicy atomic.Pointer[RedactionPolicy]
+)
+
+// SetRedactionPolicy replaces the policy returned by GetRedactionPolicy.
+// Passing nil reverts to the policy built from properties.
+func SetRedactionPolicy(p *RedactionPolicy) {
+	redactionPolicy.Store(p)
+}
+
+// GetRedactionPolicy returns the process-wide policy honoured by the logger,
+// HAR capture, tracing, HTTP request logging and curl logging: the one set
+// with SetRedactionPolicy, or else RedactionPolicyFromProperties. An invalid
+// properties configuration is reported and the default policy used instead.
+func GetRedactionPolicy() *RedactionPolicy {
+	if p := redactionPolicy.Load(); p != nil {
+		return p
+	}
+	if p := propertiesPolicy.Load(); p != nil {
+		return p
+	}
+	p, err := RedactionPolicyFromProperties()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid redaction policy, using defaults: %v\n", err)
+		p = DefaultRedactionPolicy()
+	}
+	propertiesPolicy.Store(p)
+	return p
+}
+
+func (p *RedactionPolicy) compile() {
+	p.once.Do(func() {
+		if !p.DisableTokenPatterns {
+			p.compiled = append(p.compiled, tokenPatterns...)
+		}
+		for _, pattern := range p.Patterns {
+			// invalid patterns are reported by Validate
+			if re, err := regexp.Compile(pattern); err == nil {
+				p.compiled = append(p.compiled, re)
+			}
+		}
+		if len(p.compiled) > 0 {
+			alternatives := make([]string, len(p.compiled))
+			for i, re := range p.compiled {
+				alternatives[i] = "(?:" + re.String() + ")"
+			}
+			p.anyPattern = regexp.MustCompile(strings.Join(alternatives, "|"))
+		}
+		for _, path := range p.JSONPaths {
+			p.jsonPaths = append(p.jsonPaths, splitJSONPath(path))
+		}
+	})
+}
+
+// MaskValue masks a secret using the policy's mask style.
+func (p *RedactionPolicy) MaskValue(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	switch p.Mask {
+	case MaskFull:
+		return "****"
+	case MaskHash:
+		sum := sha256.Sum256([]byte(secret))
+		return "sha256(" + hex.EncodeToString(sum[:])[:12] + ")"
+	default:
+		return PrintableSecret(secret)
+	}
+}
+
+// IsSensitiveKey reports whether a map key, form field or query parameter
+// holds a secret.
+func (p *RedactionPolicy) IsSensitiveKey(key string) bool {
+	key = strings.Trim(strings.TrimSpace(strings.ToLower(key)), "_")
+	if key == "" {
+		return false
+	}
+	for _, k := range p.AllowKeys {
+		if key == strings.ToLower(k) {
+			return false
+		}
+	}
+	for _, k := range p.Keys {
+		k = strings.ToLower(k)
+		if strings.Contains(k, "*") {
+			if collections.MatchItems(key, k) {
+				return true
+			}
+		} else if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSensitiveQueryParam reports whether a URL query parameter holds a secret:
+// its whole name is one of Keys, or matches QueryParams.
+func (p *RedactionPolicy) IsSensitiveQueryParam(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return false
+	}
+	for _, k := range p.AllowKeys {
+		if name == strings.ToLower(k) {
+			return false
+		}
+	}
+	for _, k := range p.Keys {
+		k = strings.ToLower(k)
+		if name == k || (strings.Contains(k, "*") && collections.MatchItems(name, k)) {
+			return true
+		}
+	}
+	if len(p.QueryParams) == 0 {
+		return false
+	}
+	patterns := make([]string, len(p.QueryParams))
+	for i, q := range p.QueryParams {
+		patterns[i] = strings.ToLower(q)
+	}
+	return collections.MatchItems(name, patterns...)
+}
+
+// IsSensitiveHeader reports whether a header's value is masked, given the
+// policy's Headers and any extra patterns.
+func (p *RedactionPolicy) IsSensitiveHeader(name string, extra ...string) bool {
+	patterns := append(append([]string{}, extra...), p.Headers...)
+	return collections.MatchItems(http.CanonicalHeaderKey(name), patterns...)
+}
+
+// RedactString masks token patterns anywhere in s.
+func (p *RedactionPolicy) RedactString(s string) string {
+	p.compile()
+	if p.anyPattern == nil || !p.anyPattern.MatchString(s) {
+		return s
+	}
+	for _, re := range p.compiled {
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			// keep the scheme of "Bearer <token>" matches
+			if scheme, cred, ok := strings.Cut(match, " "); ok && strings.EqualFold(scheme, "bearer") {
+				return scheme + " " + p.MaskValue(strings.TrimSpace(cred))
+			}
+			return p.MaskValue(match)
+		})
+	}
+	return s
+}
+
+// RedactHeaders returns a copy of h with sensitive header values masked and token
+// patterns masked in all other values.
+func (p *RedactionPolicy) RedactHeaders(h http.Header, extra ...string) http.Header {
+	redacted := http.Header{}
+	for key, values := range h {
+		key = http.CanonicalHeaderKey(key)
+		if p.IsSensitiveHeader(key, extra...) {
+			redacted.Add(key, p.MaskValue(strings.Join(values, ",")))
+			continue
+		}
+		for _, v := range values {
+			redacted.Add(key, p.RedactString(v))
+		}
+	}
+	return redacted
+}
+
+// RedactValues returns a copy of v with sensitive keys, and keys matching
+// QueryParams, masked.
+func (p *RedactionPolicy) RedactValues(v url.Values) url.Values {
+	return p.redactValues(v, func(k string) bool {
+		return p.IsSensitiveKey(k) || p.IsSensitiveQueryParam(k)
+	})
+}
+
+// RedactQuery returns a copy of the query parameters v with sensitive ones
+// masked, see IsSensitiveQueryParam.
+func (p *RedactionPolicy) RedactQuery(v url.Values) url.Values {
+	return p.redactValues(v, p.IsSensitiveQueryParam)
+}
+
+func (p *RedactionPolicy) redactValues(v url.Values, sensitive func(string) bool) url.Values {
+	out := make(url.Values, len(v))
+	for k, vals := range v {
+		mask := sensitive(k)
+		masked := make([]string, len(vals))
+		for i, val := range vals {
+			if mask {
+				masked[i] = p.MaskValue(val)
+			} else {
+				masked[i] = p.RedactString(val)
+			}
+		}
+		out[k] = masked
+	}
+	return out
+}
+
+// RedactURL masks the password in the user info and sensitive query parameters
+// of a URL, see IsSensitiveQueryParam. The rest of the URL, including the order
+// and encoding of the other parameters, is kept as is. Text that does not parse
+// as a URL has token patterns masked.
+func (p *RedactionPolicy) RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return p.RedactString(raw)
+	}
+	changed := false
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), p.MaskValue(pass))
+			changed = true
+		}
+	}
+	if query, ok := p.redactRawQuery(u.RawQuery); ok {
+		u.RawQuery = query
+		changed = true
+	}
+	if !changed {
+		return raw
+	}
+	return u.String()
+}
+
+// redactRawQuery masks sensitive parameters of an encoded query string,
+// returning false if none were.
+func (p *RedactionPolicy) redactRawQuery(query string) (string, bool) {
+	if query == "" {
+		return query, false
+	}
+	params := strings.Split(query, "&")
+	changed := false
+	for i, param := range params {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || value == "" {
+			continue
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		var masked string
+		if p.IsSensitiveQueryParam(name) {
+			masked = p.MaskValue(value)
+		} else if masked = p.RedactString(value); masked == value {
+			continue
+		}
+		// keep the **** of masks readable, * is valid in a query
+		params[i] = param[:strings.Index(param, "=")+1] + strings.ReplaceAll(url.QueryEscape(masked), "%2A", "*")
+		changed = true
+	}
+	return strings.Join(params, "&"), changed
+}
+
+// RedactForm masks sensitive fields of an application/x-www-form-urlencoded body.
+func (p *RedactionPolicy) RedactForm(text string) string {
+	vals, err := url.ParseQuery(text)
+	if err != nil {
+		return p.RedactString(text)
+	}
+	return p.RedactValues(vals).Encode()
+}
+
+// RedactJSON masks sensitive keys, JSONPaths and token patterns in a JSON
+// document. Text that is not JSON has token patterns masked.
+func (p *RedactionPolicy) RedactJSON(text string) string {
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return p.RedactString(text)
+	}
+	out, err := json.Marshal(p.walk(v, nil))
+	if err != nil {
+		return p.RedactString(text)
+	}
+	return string(out)
+}
+
+// RedactMap returns a copy of m with sensitive keys, JSONPaths and token patterns
+// masked in nested maps and slices.
+func (p *RedactionPolicy) RedactMap(m map[string]any) map[string]any {
+	out, _ := p.walk(m, nil).(map[string]any)
+	return out
+}
+
+// RedactBody masks a request or response body according to its content type.
+func (p *RedactionPolicy) RedactBody(text, contentType string) string {
+	ct := strings.TrimSpace(strings.ToLower(strings.Split(contentType, ";")[0]))
+	switch {
+	case strings.Contains(ct, "json"):
+		return p.RedactJSON(text)
+	case ct == "application/x-www-form-urlencoded":
+		return p.RedactForm(text)
+	default:
+		return p.RedactText(text)
+	}
+}
+
+// RedactText masks values of sensitive keys in "key: value" and "key=value" lines
+// (e.g. YAML, INI or .env files) and token patterns anywhere in the text.
+func (p *RedactionPolicy) RedactText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		sep := strings.IndexAny(line, ":=")
+		if sep < 0 {
+			continue
+		}
+		key, value := line[:sep], line[sep+1:]
+		if !p.IsSensitiveKey(strings.Trim(strings.TrimSpace(key), `"'`)) {
+			continue
+		}
+		trimmed := strings.TrimSpace(value)
+		if trimmed == "" {
+			continue
+		}
+		lead := value[:strings.Index(value, trimmed)]
+		lines[i] = key + line[sep:sep+1] + lead + p.MaskValue(trimmed)
+	}
+	return p.RedactString(strings.Join(lines, "\n"))
+}
+
+func (p *RedactionPolicy) walk(v any, path []string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			itemPath := append(path[:len(path):len(path)], k)
+			if p.IsSensitiveKey(k) || p.matchesJSONPath(itemPath) {
+				out[k] = p.maskAny(item)
+			} else {
+				out[k] = p.walk(item, itemPath)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			itemPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+			if p.matchesJSONPath(itemPath) {
+				out[i] = p.maskAny(item)
+			} else {
+				out[i] = p.walk(item, itemPath)
+			}
+		}
+		return out
+	case string:
+		return p.RedactString(val)
+	default:
+		return v
+	}
+}
+
+// maskAny masks a value found under a sensitive key: scalars are masked
+// whole, containers have every leaf masked.
+func (p *RedactionPolicy) maskAny(v any) any {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = p.maskAny(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = p.maskAny(item)
+		}
+		return out
+	case string:
+		return p.MaskValue(val)
+	default:
+		return p.MaskValue(fmt.Sprintf("%v", val))
+	}
+}
+
+func (p *RedactionPolicy) matchesJSONPath(path []string) bool {
+	p.compile()
+outer:
+	for _, pattern := range p.jsonPaths {
+		if len(pattern) != len(path) {
+			continue
+		}
+		for i, seg := range pattern {
+			if seg != "*" && !strings.EqualFold(seg, path[i]) {
+				continue outer
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// splitJSONPath turns "$.items[*].card" into ["items", "*", "card"].
+func splitJSONPath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	var out []string
+	for _, seg := range strings.Split(path, ".") {
+		if seg != "" {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+// redactAttr is the slog ReplaceAttr hook masking token patterns in log
+// messages and string attributes.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindString {
+		if s := a.Value.String(); s != "" {
+			a.Value = slog.StringValue(GetRedactionPolicy().RedactString(s))
+		}
+	}
+	return a
+}
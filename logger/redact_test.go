@@ -0,0 +1,225 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/commons/properties"
+	"sigs.k8s.io/yaml"
+)
+
+type corpusSecret struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Keyed bool   `json:"keyed"`
+}
+
+func loadCorpus(t *testing.T) []corpusSecret {
+	t.Helper()
+	data, err := os.ReadFile("testdata/redaction_corpus.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var corpus []corpusSecret
+	if err := yaml.Unmarshal(data, &corpus); err != nil {
+		t.Fatal(err)
+	}
+	return corpus
+}
+
+// assertNoLeak fails if any 6 character window of secret appears in output.
+func assertNoLeak(t *testing.T, output, secret string) {
+	t.Helper()
+	const window = 6
+	for i := 0; i+window <= len(secret); i++ {
+		if strings.Contains(output, secret[i:i+window]) {
+			t.Errorf("secret leaked (%q found):\n%s", secret[i:i+window], output)
+			return
+		}
+	}
+}
+
+// redactionOutputs renders secret through every redaction entry point of p.
+func redactionOutputs(p *RedactionPolicy, s corpusSecret) map[string]string {
+	out := map[string]string{
+		"authorization header": fmt.Sprint(p.RedactHeaders(http.Header{"Authorization": {"Bearer " + s.Value}})),
+		"api key header":       fmt.Sprint(p.RedactHeaders(http.Header{"X-Api-Key": {s.Value}})),
+		"url password":         p.RedactURL("https://admin:" + url.QueryEscape(s.Value) + "@example.com/"),
+		"query param":          p.RedactURL("https://example.com/cb?access_token=" + url.QueryEscape(s.Value)),
+		"json key":             p.RedactJSON(`{"auth": {"password": "` + s.Value + `"}}`),
+		"form field":           p.RedactForm("client_secret=" + url.QueryEscape(s.Value)),
+		"text key":             p.RedactText("db:\n  password: " + s.Value + "\n"),
+		"env key":              p.RedactText("API_TOKEN=" + s.Value),
+		"map key":              fmt.Sprint(p.RedactMap(map[string]any{"creds": map[string]any{"secret": s.Value}})),
+	}
+	if !s.Keyed {
+		out["free text"] = p.RedactString("calling upstream with " + s.Value + " as credential")
+		out["innocuous header"] = fmt.Sprint(p.RedactHeaders(http.Header{"X-Debug": {"sent " + s.Value}}))
+		out["innocuous query"] = p.RedactURL("https://example.com/?q=" + url.QueryEscape(s.Value))
+		out["innocuous json"] = p.RedactJSON(`{"items": [{"note": "` + s.Value + `"}]}`)
+		out["text body"] = p.RedactBody("value is "+s.Value, "text/plain")
+	}
+	return out
+}
+
+func TestRedactionCorpus(t *testing.T) {
+	corpus := loadCorpus(t)
+	for _, mask := range []MaskStyle{MaskPartial, MaskHash, MaskFull} {
+		p := DefaultRedactionPolicy()
+		p.Mask = mask
+		for _, s := range corpus {
+			for name, output := range redactionOutputs(p, s) {
+				t.Run(fmt.Sprintf("%s/%s/%s", mask, s.Name, name), func(t *testing.T) {
+					assertNoLeak(t, output, s.Value)
+				})
+			}
+		}
+	}
+}
+
+func TestRedactionCorpus_LogOutput(t *testing.T) {
+	for _, json := range []bool{false, true} {
+		properties.Set("log.json", fmt.Sprint(json))
+		var buf bytes.Buffer
+		log := NewWithWriter(&buf)
+		for _, s := range loadCorpus(t) {
+			if s.Keyed {
+				log.Secretf("password=%s", s.Value)
+				continue
+			}
+			log.Infof("calling upstream with %s", s.Value)
+			log.WithValues("response", "token "+s.Value).Infof("done")
+		}
+		properties.Set("log.json", "")
+
+		for _, s := range loadCorpus(t) {
+			t.Run(fmt.Sprintf("json=%v/%s", json, s.Name), func(t *testing.T) {
+				assertNoLeak(t, buf.String(), s.Value)
+			})
+		}
+	}
+}
+
+func TestRedactionPolicy_MaskStyles(t *testing.T) {
+	secret := "s3cr3t-value-1234"
+	cases := map[MaskStyle]string{
+		MaskPartial: PrintableSecret(secret),
+		MaskFull:    "****",
+		MaskHash:    "sha256(",
+	}
+	for mask, want := range cases {
+		p := &RedactionPolicy{Mask: mask}
+		if got := p.MaskValue(secret); !strings.HasPrefix(got, want) {
+			t.Errorf("%s: got %q, want prefix %q", mask, got, want)
+		}
+	}
+
+	hashed := &RedactionPolicy{Mask: MaskHash}
+	if hashed.MaskValue(secret) != hashed.MaskValue(secret) || hashed.MaskValue(secret) == hashed.MaskValue("other") {
+		t.Error("hash mask should be stable and distinguish values")
+	}
+}
+
+func TestRedactionPolicy_JSONPathsAndQueryParams(t *testing.T) {
+	p := DefaultRedactionPolicy().Merge(&RedactionPolicy{
+		JSONPaths:   []string{"customer.card.number", "$.items[*].cvv"},
+		QueryParams: []string{"sig", "X-Amz-*"},
+		Mask:        MaskFull,
+	})
+
+	got := p.RedactJSON(`{"customer":{"card":{"number":"4111111111111111","brand":"visa"}},"items":[{"cvv":123,"sku":"a"}]}`)
+	want := `{"customer":{"card":{"brand":"visa","number":"****"}},"items":[{"cvv":"****","sku":"a"}]}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	gotURL := p.RedactURL("https://bucket.s3.amazonaws.com/key?X-Amz-Signature=abcdef&sig=123&page=2")
+	u, _ := url.Parse(gotURL)
+	q := u.Query()
+	if q.Get("X-Amz-Signature") != "****" || q.Get("sig") != "****" || q.Get("page") != "2" {
+		t.Errorf("unexpected query redaction: %s", gotURL)
+	}
+}
+
+func TestRedactionPolicy_AllowKeys(t *testing.T) {
+	p := DefaultRedactionPolicy()
+	got := p.RedactJSON(`{"token_type":"bearer","access_token":"abcdefghijkl"}`)
+	if !strings.Contains(got, `"token_type":"bearer"`) || strings.Contains(got, "abcdefghijkl") {
+		t.Errorf("unexpected redaction: %s", got)
+	}
+}
+
+func TestLoadRedactionPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("headers: [X-Internal-*]\nkeys: [ssn]\nmask: hash\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadRedactionPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Mask != MaskHash || !p.IsSensitiveHeader("X-Internal-Id") || !p.IsSensitiveKey("customer_ssn") {
+		t.Errorf("policy not loaded: %+v", p)
+	}
+	// defaults still apply
+	if !p.IsSensitiveHeader("Authorization") || !p.IsSensitiveKey("password") {
+		t.Error("expected default rules to be kept")
+	}
+
+	for _, invalid := range []string{"mask: sometimes\n", "patterns: ['(']\n", "unknown: true\n"} {
+		if err := os.WriteFile(path, []byte(invalid), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRedactionPolicy(path); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestRedactionPolicyFromProperties(t *testing.T) {
+	properties.Set("redact.keys", "iban, ssn")
+	properties.Set("redact.mask", "full")
+	properties.Set("redact.patterns", `acme_[a-z0-9]{12}`)
+	defer func() {
+		for _, k := range []string{"redact.keys", "redact.mask", "redact.patterns"} {
+			properties.Set(k, "")
+		}
+	}()
+
+	p := GetRedactionPolicy()
+	if !p.IsSensitiveKey("iban") || p.Mask != MaskFull {
+		t.Errorf("properties not applied: %+v", p)
+	}
+	if got := StripSecrets("key is acme_0123456789ab"); strings.Contains(got, "acme_0123456789ab") {
+		t.Errorf("custom pattern not applied: %s", got)
+	}
+
+	override := &RedactionPolicy{Mask: MaskHash}
+	SetRedactionPolicy(override)
+	defer SetRedactionPolicy(nil)
+	if GetRedactionPolicy() != override {
+		t.Error("SetRedactionPolicy should take precedence over properties")
+	}
+}
+
+func TestRedactionPolicy_RedactURL(t *testing.T) {
+	p := DefaultRedactionPolicy()
+	p.Mask = MaskFull
+
+	unchanged := "https://example.com/search?z=1&sort_key=name&user_id=7&q=a+b%2Fc"
+	if got := p.RedactURL(unchanged); got != unchanged {
+		t.Errorf("URL without secrets changed: %s", got)
+	}
+
+	got := p.RedactURL("https://example.com/cb?z=1&access_token=abcdef&key=k1&user=alice&page=2")
+	want := "https://example.com/cb?z=1&access_token=****&key=****&user=****&page=2"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
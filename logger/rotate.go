@@ -0,0 +1,192 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/internal/rotation"
+)
+
+// RotatingFileOptions controls when a RotatingFile rotates and which rotated
+// files are kept.
+type RotatingFileOptions struct {
+	// MaxSize rotates the file before a write would grow it beyond this many
+	// bytes. 0 disables.
+	MaxSize int64
+
+	// MaxAge rotates the file once it has been open this long. The check runs
+	// on write, so an idle file is not rotated. 0 disables.
+	MaxAge time.Duration
+
+	// MaxBackups is the number of rotated files to keep, oldest removed
+	// first. 0 keeps all of them.
+	MaxBackups int
+
+	// Compress gzips rotated files in the background.
+	Compress bool
+}
+
+// RotatingFile is an io.WriteCloser appending to a log file that is rotated
+// by size and/or age. The active file is always at the original path; rotated
+// files are renamed to <name>-<timestamp><ext>[.gz] alongside it, with unique
+// names even when rotations happen within the same millisecond.
+type RotatingFile struct {
+	path string
+	opts RotatingFileOptions
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	// background serializes compression and pruning of rotated files
+	background sync.Mutex
+	pending    sync.WaitGroup
+}
+
+// OpenRotatingFile opens (or creates) the log file at path for appending,
+// creating its directory if needed.
+func OpenRotatingFile(path string, opts RotatingFileOptions) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f := &RotatingFile{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the path of the active file.
+func (f *RotatingFile) Path() string {
+	return f.path
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file now, regardless of the limits.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Close closes the active file and waits for rotated files to be compressed.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+	f.pending.Wait()
+	return err
+}
+
+func (f *RotatingFile) shouldRotate(next int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.opts.MaxSize > 0 && f.size+next > f.opts.MaxSize {
+		return true
+	}
+	return f.opts.MaxAge > 0 && time.Since(f.opened) >= f.opts.MaxAge
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	rotated, err := rotation.Rename(f.path, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.pending.Add(1)
+	go func() {
+		defer f.pending.Done()
+		f.background.Lock()
+		defer f.background.Unlock()
+		if f.opts.Compress {
+			// a backup pruned by an earlier rotation has nothing to compress
+			if err := gzipFile(rotated); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "failed to compress %s: %v\n", rotated, err)
+			}
+		}
+		if f.opts.MaxBackups > 0 {
+			if err := rotation.Prune(f.path, f.opts.MaxBackups); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to remove old log files: %v\n", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// gzipFile replaces path with path.gz.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(out.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = out.Close()
+		_ = os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,234 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/commons/properties"
+	"github.com/lrita/cmap"
+)
+
+// SampleConfig controls sampling and deduplication of a named logger's
+// records. It is read from properties, where every key can be set for all
+// loggers or overridden for one logger by inserting its name:
+//
+//	log.sample.first=100          # log.sample.<name>.first
+//	log.sample.thereafter=50      # log.sample.<name>.thereafter
+//	log.sample.interval=1s        # log.sample.<name>.interval
+//	log.sample.dedup=5s           # log.sample.<name>.dedup
+//
+// Fatal records are never sampled or collapsed.
+type SampleConfig struct {
+	// First is the number of records with the same level and message template
+	// logged in each Interval before sampling starts. 0 disables sampling.
+	First int
+
+	// Thereafter logs every Thereafter'th record once First is reached; the
+	// rest are dropped until the Interval ends. 0 drops all of them.
+	Thereafter int
+
+	// Interval is the window the First/Thereafter counts are kept for. Default: 1s.
+	Interval time.Duration
+
+	// Dedup collapses consecutive identical messages logged within this window
+	// into the first one, followed by a single "(repeated N times)" summary.
+	// 0 disables deduplication.
+	Dedup time.Duration
+}
+
+// Enabled returns true when sampling or deduplication is configured.
+func (c SampleConfig) Enabled() bool {
+	return c.First > 0 || c.Dedup > 0
+}
+
+// SampleConfigFromProperties returns the sampling settings for the logger
+// named name (use "root" for the root logger).
+func SampleConfigFromProperties(name string) SampleConfig {
+	intProperty := func(key string) int {
+		return properties.Int(properties.Int(0, "log.sample."+key), fmt.Sprintf("log.sample.%s.%s", name, key))
+	}
+	durationProperty := func(key string, def time.Duration) time.Duration {
+		return properties.Duration(def, fmt.Sprintf("log.sample.%s.%s", name, key), "log.sample."+key)
+	}
+	return SampleConfig{
+		First:      intProperty("first"),
+		Thereafter: intProperty("thereafter"),
+		Interval:   durationProperty("interval", time.Second),
+		Dedup:      durationProperty("dedup", 0),
+	}
+}
+
+// samplers holds the sampler of each named logger, created on first use from
+// properties and reset by onPropertyUpdate when a log.sample.* key changes.
+var samplers cmap.Map[string, *sampler]
+
+func getSampler(name string) *sampler {
+	if p, ok := samplers.Load(name); ok {
+		return p
+	}
+	p, _ := samplers.LoadOrStore(name, newSampler(SampleConfigFromProperties(name)))
+	return p
+}
+
+var (
+	samplersLock sync.Mutex
+	// samplersSpec are the log.sample.* properties the samplers were built with
+	samplersSpec string
+)
+
+// updateSamplers resets the samplers when a log.sample.* property changed,
+// other updates keep their counts and pending "repeated" summaries.
+func updateSamplers(props *properties.Properties) {
+	values := props.GetAll()
+	var spec strings.Builder
+	for _, k := range sortedKeys(values) {
+		if strings.HasPrefix(k, "log.sample.") {
+			spec.WriteString(k + "=" + values[k] + "\n")
+		}
+	}
+	samplersLock.Lock()
+	changed := spec.String() != samplersSpec
+	samplersSpec = spec.String()
+	samplersLock.Unlock()
+	if changed {
+		resetSamplers()
+	}
+}
+
+// resetSamplers flushes pending "repeated" summaries and discards all
+// samplers so they are rebuilt with the current properties.
+func resetSamplers() {
+	samplers.Range(func(name string, p *sampler) bool {
+		p.flush()
+		samplers.Delete(name)
+		return true
+	})
+}
+
+type sampler struct {
+	config SampleConfig
+
+	mu     sync.Mutex
+	window time.Time
+	counts map[string]int
+	last   *repeat
+}
+
+// repeat is the most recent message logged through a deduplicating sampler.
+type repeat struct {
+	key     string
+	since   time.Time
+	logger  SlogLogger
+	record  slog.Record
+	msg     string
+	repeats int
+	timer   *time.Timer
+}
+
+func newSampler(config SampleConfig) *sampler {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	return &sampler{config: config, counts: map[string]int{}}
+}
+
+// allow reports whether a record should be written. template is the format
+// string the message was built from; records sharing it are sampled together.
+func (p *sampler) allow(s SlogLogger, r slog.Record, template, msg string) bool {
+	if !p.config.Enabled() {
+		return true
+	}
+	p.mu.Lock()
+	allowed, summary := p.allowLocked(s, r, template, msg)
+	p.mu.Unlock()
+	// written without p.mu, so other records are not held up by its I/O
+	summary.write()
+	return allowed
+}
+
+// allowLocked is allow, returning the summary of a message it replaced as
+// well. Callers must hold p.mu.
+func (p *sampler) allowLocked(s SlogLogger, r slog.Record, template, msg string) (bool, *repeat) {
+	if r.Level >= SlogFatal {
+		return true, p.takeLocked()
+	}
+
+	var summary *repeat
+	if p.config.Dedup > 0 {
+		key := r.Level.String() + "\x00" + msg
+		if last := p.last; last != nil && last.key == key && r.Time.Sub(last.since) < p.config.Dedup {
+			last.repeats++
+			last.record = r.Clone()
+			if last.timer == nil {
+				last.timer = time.AfterFunc(p.config.Dedup-r.Time.Sub(last.since), func() { p.expire(last) })
+			}
+			return false, nil
+		}
+		summary = p.takeLocked()
+		p.last = &repeat{key: key, since: r.Time, logger: s, msg: msg}
+	}
+
+	if p.config.First > 0 {
+		if r.Time.Sub(p.window) >= p.config.Interval {
+			clear(p.counts)
+			p.window = r.Time
+		}
+		key := r.Level.String() + "\x00" + template
+		p.counts[key]++
+		if n := p.counts[key] - p.config.First; n > 0 && (p.config.Thereafter <= 0 || n%p.config.Thereafter != 0) {
+			return false, summary
+		}
+	}
+	return true, summary
+}
+
+func (p *sampler) flush() {
+	p.mu.Lock()
+	summary := p.takeLocked()
+	p.mu.Unlock()
+	summary.write()
+}
+
+// expire writes the summary for last once its dedup window has passed, unless
+// a different message has replaced it in the meantime.
+func (p *sampler) expire(last *repeat) {
+	p.mu.Lock()
+	var summary *repeat
+	if p.last == last {
+		summary = p.takeLocked()
+	}
+	p.mu.Unlock()
+	summary.write()
+}
+
+// takeLocked forgets the pending message, returning it if it was repeated
+// and needs a summary. Callers must hold p.mu.
+func (p *sampler) takeLocked() *repeat {
+	last := p.last
+	p.last = nil
+	if last == nil {
+		return nil
+	}
+	if last.timer != nil {
+		last.timer.Stop()
+	}
+	if last.repeats == 0 {
+		return nil
+	}
+	return last
+}
+
+// write writes a "(repeated N times)" summary, if r is not nil.
+func (r *repeat) write() {
+	if r == nil {
+		return
+	}
+	times := "times"
+	if r.repeats == 1 {
+		times = "time"
+	}
+	r.logger.write(r.record, fmt.Sprintf("%s (repeated %d %s)", strings.TrimRight(r.msg, "\n"), r.repeats, times))
+}
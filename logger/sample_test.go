@@ -0,0 +1,228 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flanksource/commons/properties"
+)
+
+func setProperties(t *testing.T, props map[string]string) {
+	t.Helper()
+	for k, v := range props {
+		properties.Set(k, v)
+	}
+	t.Cleanup(func() {
+		for k := range props {
+			properties.Set(k, "")
+		}
+	})
+}
+
+func TestSampling_FirstThenEveryM(t *testing.T) {
+	setProperties(t, map[string]string{
+		"log.sample.sampled.first":      "3",
+		"log.sample.sampled.thereafter": "5",
+		"log.sample.sampled.interval":   "1h",
+	})
+	original := GetOutput()
+	t.Cleanup(func() { SetOutput(original) })
+	var buf bytes.Buffer
+	SetOutput(&buf)
+
+	lg := New("sampled")
+	for i := range 20 {
+		lg.Infof("polling item %d", i)
+	}
+	lg.Infof("a different template")
+	// other loggers are not sampled
+	New("unsampled").Infof("polling item %d", 1)
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if i := strings.Index(line, "polling item "); i >= 0 {
+			got = append(got, line[i+len("polling item "):])
+		}
+	}
+	// first 3, then every 5th of the rest (the 8th, 13th and 18th), plus the unsampled logger
+	want := "0,1,2,7,12,17,1"
+	if strings.Join(got, ",") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+	if !strings.Contains(buf.String(), "a different template") {
+		t.Error("templates should be sampled independently")
+	}
+}
+
+func TestSampling_IntervalResetsCounts(t *testing.T) {
+	setProperties(t, map[string]string{
+		"log.sample.interval-reset.first":    "1",
+		"log.sample.interval-reset.interval": "20ms",
+	})
+	original := GetOutput()
+	t.Cleanup(func() { SetOutput(original) })
+	var buf bytes.Buffer
+	SetOutput(&buf)
+
+	lg := New("interval-reset")
+	lg.Infof("tick")
+	lg.Infof("tick")
+	time.Sleep(30 * time.Millisecond)
+	lg.Infof("tick")
+
+	if n := strings.Count(buf.String(), "tick"); n != 2 {
+		t.Errorf("expected 2 ticks, got %d:\n%s", n, buf.String())
+	}
+}
+
+// lockedBuffer is safe to read while the dedup timer writes to it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDedup_CollapsesRepeatedMessages(t *testing.T) {
+	setProperties(t, map[string]string{"log.sample.dedup-test.dedup": "50ms"})
+	original := GetOutput()
+	t.Cleanup(func() { SetOutput(original) })
+	var buf lockedBuffer
+	SetOutput(&buf)
+
+	lg := New("dedup-test")
+	for range 5 {
+		lg.Warnf("connection refused")
+	}
+	lg.Infof("reconnected")
+	lg.Infof("reconnected")
+	time.Sleep(100 * time.Millisecond)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"connection refused",
+		"connection refused (repeated 4 times)",
+		"reconnected",
+		"reconnected (repeated 1 time)",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got:\n%s", len(want), buf.String())
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, want[i]) {
+			t.Errorf("line %d: got %q, want suffix %q", i, line, want[i])
+		}
+	}
+	if !strings.Contains(lines[1], "WRN") {
+		t.Errorf("summary should keep the original level: %q", lines[1])
+	}
+}
+
+func TestDedup_SurvivesUnrelatedPropertyUpdates(t *testing.T) {
+	setProperties(t, map[string]string{"log.sample.dedup-update.dedup": "50ms"})
+	original := GetOutput()
+	t.Cleanup(func() { SetOutput(original) })
+	var buf lockedBuffer
+	SetOutput(&buf)
+
+	lg := New("dedup-update")
+	lg.Warnf("connection refused")
+	lg.Warnf("connection refused")
+	setProperties(t, map[string]string{"dedup-update.unrelated": "true"})
+	lg.Warnf("connection refused")
+	time.Sleep(100 * time.Millisecond)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], "connection refused (repeated 2 times)") {
+		t.Errorf("expected one collapsed summary, got:\n%s", buf.String())
+	}
+}
+
+func TestDedup_FatalIsNeverCollapsed(t *testing.T) {
+	setProperties(t, map[string]string{"log.sample.dedup-fatal.dedup": "1h"})
+	original := GetOutput()
+	t.Cleanup(func() { SetOutput(original) })
+	var buf bytes.Buffer
+	SetOutput(&buf)
+
+	lg := New("dedup-fatal")
+	lg.Errorf("disk full")
+	lg.Errorf("disk full")
+	lg.Fatalf("disk full")
+
+	out := buf.String()
+	if !strings.Contains(out, "disk full (repeated 1 time)") || strings.Count(out, "disk full") != 3 {
+		t.Errorf("expected the summary to be flushed before the fatal record:\n%s", out)
+	}
+}
+
+func TestSampleConfigFromProperties(t *testing.T) {
+	setProperties(t, map[string]string{
+		"log.sample.first":       "10",
+		"log.sample.db.first":    "2",
+		"log.sample.dedup":       "5s",
+		"log.sample.db.interval": "1m",
+		"log.sample.thereafter":  "100",
+	})
+
+	db := SampleConfigFromProperties("db")
+	if db.First != 2 || db.Thereafter != 100 || db.Interval != time.Minute || db.Dedup != 5*time.Second {
+		t.Errorf("unexpected db config: %+v", db)
+	}
+	if root := SampleConfigFromProperties(rootName); root.First != 10 || root.Interval != time.Second {
+		t.Errorf("unexpected root config: %+v", root)
+	}
+}
+
+// blockingWriter blocks writes until release is closed.
+type blockingWriter struct {
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.writing <- struct{}{}
+	<-w.release
+	return len(p), nil
+}
+
+func TestDedup_SummaryIsWrittenOutsideLock(t *testing.T) {
+	w := &blockingWriter{writing: make(chan struct{}, 1), release: make(chan struct{})}
+	lg := *NewWithWriter(w)
+	p := newSampler(SampleConfig{Dedup: time.Hour})
+	record := func(msg string) slog.Record {
+		return slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0)
+	}
+
+	p.allow(lg, record("a"), "a", "a")
+	p.allow(lg, record("a"), "a", "a")
+	// replacing the repeated message writes its summary, which blocks
+	go p.allow(lg, record("b"), "b", "b")
+	<-w.writing
+
+	done := make(chan struct{})
+	go func() {
+		p.allow(lg, record("c"), "c", "c")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Error("records waited for the summary write")
+	}
+	close(w.release)
+}
@@ -8,7 +8,6 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/flanksource/commons/collections"
 	"github.com/samber/lo"
 )
 
@@ -21,38 +20,25 @@ var SensitiveKeys = []string{"user", "pass", "secret", "key", "token", "username
 
 var NonSensitiveKeys = []string{"token_type", "grant_type"}
 
+// SensitiveQueryParams are URL query parameters masked by the default
+// RedactionPolicy, in addition to parameters named exactly as one of
+// SensitiveKeys. Unlike keys they match whole names, so sort_key and
+// user_id are left alone.
+var SensitiveQueryParams = []string{
+	"access_token", "refresh_token", "id_token", "client_secret", "api_key", "apikey", "api-key",
+	"passwd", "sig", "signature", "X-Amz-Signature", "X-Amz-Credential", "X-Amz-Security-Token",
+}
+
+// IsSensitiveKey reports whether a key holds a secret under the current
+// RedactionPolicy.
 func IsSensitiveKey(v string) bool {
-	v = strings.Trim(strings.TrimSpace(strings.ToLower(v)), "_")
-	for _, k := range NonSensitiveKeys {
-		if v == k {
-			return false
-		}
-	}
-	for _, k := range SensitiveKeys {
-		if v == k || strings.Contains(v, k) {
-			return true
-		}
-	}
-	return false
+	return GetRedactionPolicy().IsSensitiveKey(v)
 }
 
+// SanitizeHeaders masks sensitive headers using the current RedactionPolicy,
+// plus any additional redactedHeaders glob patterns.
 func SanitizeHeaders(headers http.Header, redactedHeaders ...string) http.Header {
-	var redacted = http.Header{}
-
-	redactedHeaders = append(redactedHeaders, CommonRedactedHeaders...)
-
-	for key, values := range headers {
-		key = http.CanonicalHeaderKey(key)
-
-		if collections.MatchItems(http.CanonicalHeaderKey(key), redactedHeaders...) {
-			redacted.Add(key, PrintableSecret(strings.Join(values, ",")))
-			continue
-		}
-
-		redacted[key] = values
-	}
-
-	return redacted
+	return GetRedactionPolicy().RedactHeaders(headers, redactedHeaders...)
 }
 
 // PrintableSecret returns an approximation of a secret for debugging.
@@ -103,65 +89,40 @@ func printableValue(s string) string {
 	}
 }
 
+// StripSecretsFromMap returns a copy of m without empty values, and with
+// secrets masked according to the current RedactionPolicy.
 func StripSecretsFromMap[V comparable](m map[string]V) map[string]any {
+	return stripSecretsFromMap(GetRedactionPolicy(), m, nil)
+}
+
+func stripSecretsFromMap[V comparable](p *RedactionPolicy, m map[string]V, path []string) map[string]any {
 	clone := make(map[string]any)
 	for k, v := range m {
 		if lo.IsEmpty(v) {
 			continue
 		}
-		if nestedMap, ok := any(v).(map[string]any); ok {
-			clone[k] = StripSecretsFromMap(nestedMap)
-		} else {
-			if IsSensitiveKey(k) {
-				clone[k] = PrintableSecret(fmt.Sprintf("%v", v))
+		itemPath := append(path[:len(path):len(path)], k)
+		switch {
+		case p.IsSensitiveKey(k) || p.matchesJSONPath(itemPath):
+			clone[k] = p.maskAny(any(v))
+		default:
+			if nestedMap, ok := any(v).(map[string]any); ok {
+				clone[k] = stripSecretsFromMap(p, nestedMap, itemPath)
 			} else {
-				clone[k] = v
+				clone[k] = p.walk(any(v), itemPath)
 			}
 		}
 	}
 	return clone
 }
 
-// StripSecrets takes a URL, YAML or INI formatted text and removes any potentially secret data
-// as denoted by keys containing "pass" or "secret" or exact matches for "key"
-// the last character of the secret is kept to aid in troubleshooting
+// StripSecrets takes a URL, YAML or INI formatted text and masks any potentially
+// secret data according to the current RedactionPolicy: URL passwords and
+// sensitive query parameters, values of sensitive keys, and token patterns.
 func StripSecrets(text string) string {
-	if uri, err := url.Parse(text); err == nil {
-		return uri.Redacted()
+	p := GetRedactionPolicy()
+	if uri, err := url.Parse(text); err == nil && uri.Scheme != "" && uri.Host != "" {
+		return p.RedactURL(text)
 	}
-
-	out := ""
-	for _, line := range strings.Split(text, "\n") {
-
-		var k, v, sep string
-		if strings.Contains(line, ":") {
-			parts := strings.Split(line, ":")
-			k = parts[0]
-			if len(parts) > 1 {
-				v = parts[1]
-			}
-			sep = ":"
-		} else if strings.Contains(line, "=") {
-			parts := strings.Split(line, "=")
-			k = parts[0]
-			if len(parts) > 1 {
-				v = parts[1]
-			}
-			sep = "="
-		} else {
-			v = line
-		}
-
-		if IsSensitiveKey(k) {
-			if len(v) == 0 {
-				out += k + sep + "\n"
-			} else {
-				out += k + sep + "****" + v[len(v)-1:] + "\n"
-			}
-		} else {
-			out += k + sep + v + "\n"
-		}
-	}
-	return out
-
+	return p.RedactText(text)
 }
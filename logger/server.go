@@ -0,0 +1,362 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/commons/properties"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader is the header NewHttpServerLogger reads and sets the request
+// ID from.
+const RequestIDHeader = "X-Request-ID"
+
+// Access log formats of HttpServerLogConfig.Format.
+const (
+	// AccessLogCombined logs the Apache/NGINX combined log format as the
+	// message, with the request ID and duration as values.
+	AccessLogCombined = "combined"
+	// AccessLogJSON logs a short message with the request and response as
+	// values, e.g. for JSON logs and sinks.
+	AccessLogJSON = "json"
+)
+
+// HttpServerLogConfig configures NewHttpServerLogger.
+type HttpServerLogConfig struct {
+	// Logger writes access logs. Default: GetLogger("http").
+	Logger Logger
+	// Format is AccessLogCombined or AccessLogJSON. Default: AccessLogJSON when
+	// IsJsonLogs(), otherwise AccessLogCombined.
+	Format string
+	// Level successful requests are logged at, 4xx responses are logged as
+	// warnings and 5xx responses as errors. Default: Info.
+	Level LogLevel
+
+	// RequestHeaders, RequestBody and ResponseHeaders add the redacted
+	// headers and body to AccessLogJSON logs. The response body of 4xx and
+	// 5xx responses is always added, as on the client side.
+	RequestHeaders  bool
+	RequestBody     bool
+	ResponseHeaders bool
+	// MaxBodyLength truncates logged bodies. Default: the
+	// http.log.response.body.length property (4KB).
+	MaxBodyLength int64
+	// RedactedHeaders are masked in addition to the redaction policy's.
+	RedactedHeaders []string
+
+	// Skip disables access logs and metrics for matching requests, e.g.
+	// health checks. Request IDs are still propagated.
+	Skip func(*http.Request) bool
+
+	// Registerer records the http_server_request_duration_seconds histogram,
+	// by method, route and status, when not nil.
+	Registerer prometheus.Registerer
+	// Route returns the route label of a request. Default: the ServeMux
+	// pattern that matched it, or "unmatched", to keep the label bounded.
+	Route func(*http.Request) string
+}
+
+type requestIDKey struct{}
+
+// logSpanKey holds the trace and span IDs logged for requests without a span,
+// see traceHandler.
+type logSpanKey struct{}
+
+// RequestIDFromContext returns the request ID set by NewHttpServerLogger.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewHttpServerLogger returns a middleware logging inbound requests:
+//
+//   - the X-Request-ID header is propagated, or generated if missing or
+//     invalid, returned in the response and available from
+//     RequestIDFromContext
+//   - a W3C traceparent header is propagated to the request context, so
+//     logs written with WithContext(log, r.Context()) carry its trace_id.
+//     Without one, the logs carry a generated trace_id, but the context has
+//     no span, so the first span the application starts is the root of its
+//     trace
+//   - each request is access logged with URLs, headers and bodies redacted by
+//     the redaction policy, see HttpServerLogConfig
+//   - latency is optionally recorded to Prometheus by route
+func NewHttpServerLogger(config HttpServerLogConfig) func(http.Handler) http.Handler {
+	var latency *prometheus.HistogramVec
+	if config.Registerer != nil {
+		latency = registerLatency(config.Registerer)
+	}
+	propagator := propagation.TraceContext{}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				// for log fields only, a span that does not exist must not
+				// become the parent of the application's spans
+				ctx = context.WithValue(ctx, logSpanKey{}, newSpanContext())
+			}
+			r = r.WithContext(context.WithValue(ctx, requestIDKey{}, id))
+
+			if config.Skip != nil && config.Skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limit := config.maxBodyLength()
+			var reqBody string
+			if config.RequestBody && r.Body != nil && config.format() == AccessLogJSON {
+				reqBody, r.Body = readLimited(r.Body, limit)
+			}
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK, limit: limit}
+			next.ServeHTTP(rec, r)
+
+			if latency != nil {
+				route := routeOf(r)
+				if config.Route != nil {
+					route = config.Route(r)
+				}
+				latency.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+			}
+			config.log(r, rec, reqBody, id, start)
+		})
+	}
+}
+
+func (config HttpServerLogConfig) maxBodyLength() int64 {
+	if config.MaxBodyLength > 0 {
+		return config.MaxBodyLength
+	}
+	return int64(properties.Int(4*1024, "http.log.response.body.length"))
+}
+
+func (config HttpServerLogConfig) format() string {
+	if config.Format != "" {
+		return config.Format
+	}
+	if IsJsonLogs() {
+		return AccessLogJSON
+	}
+	return AccessLogCombined
+}
+
+func (config HttpServerLogConfig) log(r *http.Request, rec *responseRecorder, reqBody, id string, start time.Time) {
+	elapsed := time.Since(start)
+	log := config.Logger
+	if log == nil {
+		log = GetLogger("http")
+	}
+	policy := GetRedactionPolicy()
+	kv := []any{"request_id", id, "duration", elapsed.Truncate(time.Millisecond).String()}
+
+	var msg string
+	if config.format() == AccessLogJSON {
+		msg = fmt.Sprintf("%s %s %d %s", r.Method, policy.RedactURL(r.URL.Path), rec.status, elapsed.Truncate(time.Millisecond))
+		kv = append(kv,
+			"method", r.Method,
+			"url", policy.RedactURL(r.URL.RequestURI()),
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"remote", remoteHost(r),
+			"user_agent", r.UserAgent(),
+		)
+		if ref := r.Referer(); ref != "" {
+			kv = append(kv, "referer", policy.RedactURL(ref))
+		}
+		if config.RequestHeaders {
+			kv = append(kv, "headers", headerValues(r.Header, config.RedactedHeaders))
+		}
+		if reqBody != "" {
+			kv = append(kv, "body", policy.RedactBody(reqBody, r.Header.Get("Content-Type")))
+		}
+		if config.ResponseHeaders {
+			kv = append(kv, "responseHeaders", headerValues(rec.Header(), config.RedactedHeaders))
+		}
+	} else {
+		msg = combinedLogLine(r, rec, start)
+	}
+	if rec.status >= 400 && rec.body.Len() > 0 {
+		body := strings.TrimSpace(rec.body.String())
+		if rec.bytes > rec.limit {
+			body += "… (truncated)"
+		}
+		kv = append(kv, "responseBody", policy.RedactBody(body, rec.Header().Get("Content-Type")))
+	}
+
+	log = WithContext(log, r.Context()).WithValues(kv...)
+	switch {
+	case rec.status >= 500:
+		log.Errorf("%s", msg)
+	case rec.status >= 400:
+		log.Warnf("%s", msg)
+	default:
+		log.V(config.Level).Infof("%s", msg)
+	}
+}
+
+// combinedLogLine formats a request in the combined log format:
+//
+//	host - user [10/Oct/2000:13:55:36 -0700] "GET /a?b=c HTTP/1.1" 200 2326 "referer" "user agent"
+func combinedLogLine(r *http.Request, rec *responseRecorder, start time.Time) string {
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	policy := GetRedactionPolicy()
+	return fmt.Sprintf("%s - %s [%s] %q %d %d %q %q",
+		remoteHost(r), user, start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+policy.RedactURL(r.URL.RequestURI())+" "+r.Proto,
+		rec.status, rec.bytes, orDash(policy.RedactURL(r.Referer())), orDash(r.UserAgent()))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func headerValues(h http.Header, redacted []string) map[string]string {
+	m := make(map[string]string, len(h))
+	for k, v := range SanitizeHeaders(h, redacted...) {
+		m[k] = strings.Join(v, ", ")
+	}
+	return m
+}
+
+// readLimited reads up to limit bytes of body for logging, returning a body
+// that still yields all of it.
+func readLimited(body io.ReadCloser, limit int64) (string, io.ReadCloser) {
+	data, _ := io.ReadAll(io.LimitReader(body, limit))
+	restored := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), body), body}
+	return string(data), restored
+}
+
+// validRequestID accepts IDs of up to 128 printable ASCII characters, so a
+// client cannot inject arbitrary content into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newSpanContext() trace.SpanContext {
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	_, _ = rand.Read(traceID[:])
+	_, _ = rand.Read(spanID[:])
+	return trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+}
+
+func routeOf(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	return "unmatched"
+}
+
+func registerLatency(reg prometheus.Registerer) *prometheus.HistogramVec {
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Duration of inbound HTTP requests by method, route and status",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	if err := reg.Register(latency); err != nil {
+		var registered prometheus.AlreadyRegisteredError
+		if errors.As(err, &registered) {
+			return registered.ExistingCollector.(*prometheus.HistogramVec)
+		}
+		Warnf("failed to register http server metrics: %v", err)
+		return nil
+	}
+	return latency
+}
+
+// responseRecorder captures the status, size and, up to limit, the body of a
+// response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	limit       int64
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	if remaining := r.limit - int64(r.body.Len()); remaining > 0 {
+		r.body.Write(p[:min(int64(len(p)), remaining)])
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
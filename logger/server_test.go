@@ -0,0 +1,212 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestHttpServerLogger_RequestIDAndTraceparent(t *testing.T) {
+	var ids []string
+	var traceIDs []string
+	handler := NewHttpServerLogger(HttpServerLogConfig{Logger: NewBufferedLogger(10)})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ids = append(ids, RequestIDFromContext(r.Context()))
+			traceIDs = append(traceIDs, trace.SpanContextFromContext(r.Context()).TraceID().String())
+		}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	req.Header.Set("traceparent", testTraceparent)
+	if w := serve(handler, req); w.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Errorf("expected the request ID to be propagated, got %q", w.Header().Get(RequestIDHeader))
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "bad\nid")
+	w := serve(handler, req)
+	if generated := w.Header().Get(RequestIDHeader); len(generated) != 32 || generated != ids[1] {
+		t.Errorf("expected a generated request ID, got %q (handler saw %q)", generated, ids[1])
+	}
+
+	if ids[0] != "abc-123" || traceIDs[0] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected request context: ids=%v traces=%v", ids, traceIDs)
+	}
+	if traceIDs[1] != (trace.TraceID{}).String() {
+		t.Errorf("expected no span without a traceparent, so the first span is a root, got trace %s", traceIDs[1])
+	}
+}
+
+func TestHttpServerLogger_Combined(t *testing.T) {
+	log := NewBufferedLogger(10)
+	handler := NewHttpServerLogger(HttpServerLogConfig{Logger: log, Format: AccessLogCombined})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "hello")
+		}))
+
+	req := httptest.NewRequest("GET", "/items?access_token=s3cr3tvalue&page=2", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.SetBasicAuth("bob", "pass")
+	serve(handler, req)
+
+	logs := log.GetLogs()
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 access log, got %d", len(logs))
+	}
+	pattern := regexp.MustCompile(`^192\.0\.2\.1 - bob \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /items\?\S+ HTTP/1\.1" 200 5 "-" "curl/8\.0"$`)
+	if !pattern.MatchString(logs[0].Message) {
+		t.Errorf("not a combined log line: %s", logs[0].Message)
+	}
+	if strings.Contains(logs[0].Message, "s3cr3tvalue") {
+		t.Errorf("expected the token to be redacted: %s", logs[0].Message)
+	}
+	if logs[0].Level != Info || logs[0].Fields["request_id"] == nil || logs[0].Fields["duration"] == nil {
+		t.Errorf("unexpected entry: %+v", logs[0])
+	}
+}
+
+func TestHttpServerLogger_JSONErrorsWithBodies(t *testing.T) {
+	log := NewBufferedLogger(10)
+	handler := NewHttpServerLogger(HttpServerLogConfig{
+		Logger:        log,
+		Format:        AccessLogJSON,
+		RequestBody:   true,
+		MaxBodyLength: 64,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), "hunter2") {
+			t.Errorf("expected the handler to read the whole body, got %s", body)
+		}
+		status := http.StatusInternalServerError
+		if r.URL.Path == "/missing" {
+			status = http.StatusNotFound
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, `{"error":"boom","detail":"`+strings.Repeat("x", 100)+`"}`)
+	}))
+
+	serve(handler, httptest.NewRequest("POST", "/orders", strings.NewReader(`{"password":"hunter2"}`)))
+	req := httptest.NewRequest("POST", "/missing", strings.NewReader(`{"password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	serve(handler, req)
+
+	logs := log.GetLogs()
+	if len(logs) != 2 || logs[0].Level != Error || logs[1].Level != Warn {
+		t.Fatalf("expected an error and a warning, got %+v", logs)
+	}
+	if !strings.HasPrefix(logs[0].Message, "POST /orders 500 ") {
+		t.Errorf("unexpected message %q", logs[0].Message)
+	}
+	fields := logs[1].Fields
+	if fields["status"] != int64(404) || fields["method"] != "POST" || fields["url"] != "/missing" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if body := fields["body"].(string); strings.Contains(body, "hunter2") {
+		t.Errorf("expected the request body to be redacted, got %s", body)
+	}
+	if body := fields["responseBody"].(string); !strings.HasSuffix(body, "(truncated)") || len(body) > 64+len("… (truncated)") {
+		t.Errorf("expected the response body to be truncated, got %s", body)
+	}
+}
+
+func TestHttpServerLogger_TraceIDInLogs(t *testing.T) {
+	var buf lockedBuffer
+	useSinks(t, NewSink(&buf, FormatJSON, "info"))
+	handler := NewHttpServerLogger(HttpServerLogConfig{Logger: New("http-server")})(http.NotFoundHandler())
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	serve(handler, req)
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(buf.String()), &record); err != nil {
+		t.Fatalf("invalid JSON log %q: %v", buf.String(), err)
+	}
+	if record[TraceIDKey] != "4bf92f3577b34da6a3ce929d0e0e4736" || record["request_id"] == nil {
+		t.Errorf("expected trace and request IDs in %v", record)
+	}
+}
+
+func TestHttpServerLogger_GeneratedTraceIDOnlyInLogs(t *testing.T) {
+	var buf lockedBuffer
+	useSinks(t, NewSink(&buf, FormatJSON, "info"))
+	log := New("http-server")
+	var root bool
+	handler := NewHttpServerLogger(HttpServerLogConfig{Logger: log})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			root = !trace.SpanContextFromContext(r.Context()).IsValid()
+			WithContext(log, r.Context()).Infof("handling")
+		}))
+	serve(handler, httptest.NewRequest("GET", "/", nil))
+
+	if !root {
+		t.Error("expected no span in the request context without a traceparent")
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected the handler and access logs, got %q", buf.String())
+	}
+	var traceIDs []any
+	for _, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid JSON log %q: %v", line, err)
+		}
+		traceIDs = append(traceIDs, record[TraceIDKey])
+	}
+	if traceIDs[0] == nil || traceIDs[0] != traceIDs[1] {
+		t.Errorf("expected both logs to carry the same generated trace_id, got %v", traceIDs)
+	}
+}
+
+func TestHttpServerLogger_Metrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	config := HttpServerLogConfig{
+		Logger:     NewBufferedLogger(10),
+		Registerer: reg,
+		Skip:       func(r *http.Request) bool { return r.URL.Path == "/healthz" },
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+	handler := NewHttpServerLogger(config)(mux)
+	// a second middleware on the same registry shares the histogram
+	other := NewHttpServerLogger(config)(mux)
+
+	serve(handler, httptest.NewRequest("GET", "/items/1", nil))
+	serve(other, httptest.NewRequest("GET", "/items/2", nil))
+	serve(handler, httptest.NewRequest("GET", "/healthz", nil))
+	serve(handler, httptest.NewRequest("GET", "/nope", nil))
+
+	families, err := reg.Gather()
+	if err != nil || len(families) != 1 {
+		t.Fatalf("expected 1 metric family, got %v: %v", families, err)
+	}
+	counts := map[string]uint64{}
+	for _, m := range families[0].GetMetric() {
+		var labels []string
+		for _, l := range m.GetLabel() {
+			labels = append(labels, l.GetValue())
+		}
+		counts[strings.Join(labels, " ")] = m.GetHistogram().GetSampleCount()
+	}
+	if counts["GET GET /items/{id} 200"] != 2 || counts["GET unmatched 404"] != 1 || len(counts) != 2 {
+		t.Errorf("unexpected histograms: %v", counts)
+	}
+}
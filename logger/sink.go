@@ -0,0 +1,498 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/properties"
+	"github.com/lmittmann/tint"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+// SinkFormat is the encoding a Sink writes records in.
+type SinkFormat string
+
+const (
+	// FormatPretty is the human readable tint format, colored if Sink.Color is set.
+	FormatPretty SinkFormat = "pretty"
+	// FormatText is the pretty format without color.
+	FormatText SinkFormat = "text"
+	// FormatJSON writes one JSON object per record.
+	FormatJSON SinkFormat = "json"
+	// FormatLogfmt writes key=value pairs, see slog.TextHandler.
+	FormatLogfmt SinkFormat = "logfmt"
+)
+
+// allLevels lets every record through handlers that sit behind the router,
+// which does the level checks itself.
+const allLevels = slog.Level(math.MinInt)
+
+// Sink is a log destination with its own minimum level and format. Install
+// sinks with SetSinks, or with the --log-sink flag / log.sinks property.
+type Sink struct {
+	// Name identifies the sink, e.g. the spec it was parsed from.
+	Name string
+
+	Writer io.Writer
+	Format SinkFormat
+
+	// Level is the minimum level written to this sink. Records must also be
+	// enabled on the logger itself, so a debug sink only receives debug
+	// records from loggers at debug level or below. nil accepts every record.
+	Level slog.Leveler
+
+	// Color enables ANSI colors for FormatPretty.
+	Color bool
+
+	// LoggerProvider, when set, receives records through the OpenTelemetry
+	// Logs API instead of Writer and Format, see NewOTelSink.
+	LoggerProvider otellog.LoggerProvider
+
+	// Handler, when set, receives records instead of Writer. The router does
+	// the level checks, so it should accept every level.
+	Handler slog.Handler
+
+	// owned is true when the sink opened Writer itself and must close it
+	owned bool
+}
+
+// NewSink returns a sink writing to w at level (anything ParseLevel accepts)
+// and above.
+func NewSink(w io.Writer, format SinkFormat, level any) *Sink {
+	return &Sink{Name: string(format), Writer: w, Format: format, Level: ParseLevel(nil, level).Slog()}
+}
+
+func (s *Sink) enabled(level slog.Level) bool {
+	return s.Level == nil || level >= s.Level.Level()
+}
+
+// Close closes the sink's writer if ParseSink opened it.
+func (s *Sink) Close() error {
+	if c, ok := s.Writer.(io.Closer); ok && s.owned {
+		return c.Close()
+	}
+	return nil
+}
+
+func (s *Sink) handler(reportCaller bool, timeFormat string) slog.Handler {
+	if s.Handler != nil {
+		return s.Handler
+	}
+	if s.LoggerProvider != nil {
+		return newOTelHandler(s.LoggerProvider)
+	}
+	var replace func([]string, slog.Attr) slog.Attr = redactAttr
+	syslog, isSyslog := s.Writer.(*SyslogWriter)
+	if isSyslog {
+		// syslog stamps its own time
+		replace = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return redactAttr(groups, a)
+		}
+	}
+
+	var h slog.Handler
+	switch s.Format {
+	case FormatJSON:
+		h = slog.NewJSONHandler(s.Writer, &slog.HandlerOptions{AddSource: reportCaller, Level: allLevels, ReplaceAttr: replace})
+	case FormatLogfmt:
+		h = slog.NewTextHandler(s.Writer, &slog.HandlerOptions{AddSource: reportCaller, Level: allLevels, ReplaceAttr: replace})
+	default:
+		color := s.Color && s.Format != FormatText
+		h = &prefixHandler{
+			Handler: tint.NewHandler(s.Writer, &tint.Options{
+				Level:       allLevels,
+				NoColor:     !color,
+				AddSource:   reportCaller,
+				ReplaceAttr: replace,
+				TimeFormat:  timeFormat,
+			}),
+			color: color,
+		}
+	}
+	if isSyslog {
+		h = &syslogHandler{inner: h, w: syslog}
+	}
+	return h
+}
+
+var (
+	sinks     atomic.Pointer[[]*Sink]
+	sinksLock sync.Mutex
+	// sinksSpec is the log.sinks value the current sinks were parsed from
+	sinksSpec string
+)
+
+// GetSinks returns the sinks installed with SetSinks, or nil when all output
+// goes to the single writer set with SetOutput.
+func GetSinks() []*Sink {
+	if s := sinks.Load(); s != nil {
+		return *s
+	}
+	return nil
+}
+
+// SetSinks routes the output of every logger, existing and future, to sinks,
+// replacing the SetOutput writer (use NewSink(GetOutput(), …) to keep it).
+// Called without sinks, it restores the single-writer output. Sinks
+// previously installed and not in s are closed. Loggers derived with WithValues before the
+// call keep writing to the old destinations, so configure sinks at startup.
+func SetSinks(s ...*Sink) error {
+	sinksLock.Lock()
+	defer sinksLock.Unlock()
+
+	var previous []*Sink
+	if len(s) == 0 {
+		if old := sinks.Swap(nil); old != nil {
+			previous = *old
+		}
+	} else if old := sinks.Swap(&s); old != nil {
+		previous = *old
+	}
+	rebuildHandlers()
+
+	var errs []error
+	for _, old := range previous {
+		if slices.Contains(s, old) {
+			continue
+		}
+		if err := old.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rebuildHandlers points every named logger and the current logger at the
+// installed sinks by swapping the handler behind each of them, so loggers in
+// use by other goroutines are never written to.
+func rebuildHandlers() {
+	namedLoggers.Range(func(name string, l *SlogLogger) bool {
+		swapHandlerOf(l.Logger, newHandler(name, l.Level))
+		return true
+	})
+	if l, ok := currentLogger.(SlogLogger); ok {
+		swapHandlerOf(l.Logger, newHandler(l.name(), l.Level))
+	}
+}
+
+// handlerBox wraps a slog.Handler for atomic stores, as outputBox does for
+// writers.
+type handlerBox struct{ h slog.Handler }
+
+// swapHandler forwards to a handler that SetSinks replaces with a single
+// atomic store, the way sharedOutput forwards to the SetOutput writer.
+// Handlers derived with WithAttrs or WithGroup keep the handler current at
+// the time they were derived.
+type swapHandler struct {
+	current atomic.Pointer[handlerBox]
+}
+
+func newSwapHandler(h slog.Handler) *swapHandler {
+	s := &swapHandler{}
+	s.current.Store(&handlerBox{h: h})
+	return s
+}
+
+// swapHandlerOf replaces the handler behind l, if it was built by New.
+func swapHandlerOf(l *slog.Logger, h slog.Handler) {
+	if l == nil {
+		return
+	}
+	if s, ok := l.Handler().(*swapHandler); ok {
+		s.current.Store(&handlerBox{h: h})
+	}
+}
+
+func (s *swapHandler) handler() slog.Handler {
+	return s.current.Load().h
+}
+
+func (s *swapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.handler().Enabled(ctx, level)
+}
+
+func (s *swapHandler) Handle(ctx context.Context, r slog.Record) error {
+	return s.handler().Handle(ctx, r)
+}
+
+func (s *swapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return s.handler().WithAttrs(attrs)
+}
+
+func (s *swapHandler) WithGroup(name string) slog.Handler {
+	return s.handler().WithGroup(name)
+}
+
+// ParseSink parses a sink spec: a destination with optional query parameters.
+//
+//	stderr | stdout                     ?level=info&format=pretty&color=false
+//	file:///var/log/app.log | ./app.log ?format=json&maxSize=100MB&maxAge=7d&maxBackups=5&compress=true
+//	syslog | syslog+unix:///dev/log | syslog+udp://host:514 ?facility=local0&app=myapp
+//	otel                                ?level=info
+//
+// Formats are pretty (the default for stderr and stdout), text (the default
+// for files and syslog), json and logfmt. The otel sink emits records
+// through the global OpenTelemetry LoggerProvider, see NewOTelSink.
+func ParseSink(spec string) (*Sink, error) {
+	spec = strings.TrimSpace(spec)
+	target, rawQuery, _ := strings.Cut(spec, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log sink %q: %w", spec, err)
+	}
+
+	sink := &Sink{Name: spec, Format: FormatText}
+	scheme, rest, hasScheme := strings.Cut(target, ":")
+	switch {
+	case target == "stderr" || target == "stdout" || target == "syslog" || target == "otel":
+		scheme = target
+	case !hasScheme || len(scheme) == 1:
+		// a plain path (or a windows drive letter)
+		scheme, rest = "file", target
+	}
+
+	switch {
+	case scheme == "stderr" || scheme == "stdout":
+		sink.Writer, sink.Format, sink.Color = sharedWriter, FormatPretty, flags.color && isTTY
+		if target == "stdout" {
+			sink.Writer = os.Stdout
+		}
+	case scheme == "otel":
+		sink.LoggerProvider = global.GetLoggerProvider()
+	case scheme == "file":
+		path := strings.TrimPrefix(rest, "//")
+		if path == "" {
+			return nil, fmt.Errorf("invalid log sink %q: missing path", spec)
+		}
+		opts, err := rotatingFileOptions(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log sink %q: %w", spec, err)
+		}
+		file, err := OpenRotatingFile(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		sink.Writer, sink.owned = file, true
+	case scheme == "syslog" || strings.HasPrefix(scheme, "syslog+"):
+		opts := SyslogOptions{AppName: query.Get("app")}
+		if network, ok := strings.CutPrefix(scheme, "syslog+"); ok {
+			opts.Network, opts.Address = network, strings.TrimPrefix(rest, "//")
+		}
+		if f := query.Get("facility"); f != "" {
+			if opts.Facility, err = ParseSyslogFacility(f); err != nil {
+				return nil, fmt.Errorf("invalid log sink %q: %w", spec, err)
+			}
+		}
+		w, err := DialSyslog(opts)
+		if err != nil {
+			return nil, fmt.Errorf("log sink %q: %w", spec, err)
+		}
+		sink.Writer, sink.owned = w, true
+	default:
+		return nil, fmt.Errorf("invalid log sink %q: unknown destination %q", spec, scheme)
+	}
+
+	if format := query.Get("format"); format != "" {
+		switch f := SinkFormat(strings.ToLower(format)); f {
+		case FormatPretty, FormatText, FormatJSON, FormatLogfmt:
+			sink.Format = f
+		default:
+			_ = sink.Close()
+			return nil, fmt.Errorf("invalid log sink %q: unknown format %q", spec, format)
+		}
+	}
+	if color := query.Get("color"); color != "" {
+		sink.Color, _ = strconv.ParseBool(color)
+	}
+	if level := query.Get("level"); level != "" {
+		sink.Level = ParseLevel(nil, level).Slog()
+	}
+	return sink, nil
+}
+
+func rotatingFileOptions(query url.Values) (RotatingFileOptions, error) {
+	var opts RotatingFileOptions
+	var err error
+	if v := query.Get("maxSize"); v != "" {
+		if opts.MaxSize, err = parseSize(v); err != nil {
+			return opts, err
+		}
+	}
+	if v := query.Get("maxAge"); v != "" {
+		d, err := duration.ParseDuration(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid maxAge %q: %w", v, err)
+		}
+		opts.MaxAge = time.Duration(d)
+	}
+	if v := query.Get("maxBackups"); v != "" {
+		if opts.MaxBackups, err = strconv.Atoi(v); err != nil {
+			return opts, fmt.Errorf("invalid maxBackups %q", v)
+		}
+	}
+	if v := query.Get("compress"); v != "" {
+		opts.Compress, _ = strconv.ParseBool(v)
+	}
+	return opts, nil
+}
+
+// parseSize parses a byte size such as 512, 10KB, 100MB or 1GiB (1024-based).
+func parseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * multiplier, nil
+}
+
+// updateSinks applies the log.sinks property: a comma separated list of sink
+// specs, see ParseSink.
+func updateSinks(props *properties.Properties) {
+	spec := props.Get("log.sinks")
+	sinksLock.Lock()
+	changed := spec != sinksSpec
+	sinksSpec = spec
+	sinksLock.Unlock()
+	if !changed {
+		return
+	}
+
+	var parsed []*Sink
+	for _, s := range strings.Split(spec, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		sink, err := ParseSink(s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			continue
+		}
+		parsed = append(parsed, sink)
+	}
+	if err := SetSinks(parsed...); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to close log sinks: %v\n", err)
+	}
+}
+
+// routeHandler sends each record to the sinks whose level it meets.
+type routeHandler struct {
+	level    slog.Leveler
+	sinks    []*Sink
+	handlers []slog.Handler
+}
+
+func newRouteHandler(level slog.Leveler, sinks []*Sink, reportCaller bool, timeFormat string) *routeHandler {
+	h := &routeHandler{level: level, sinks: sinks}
+	for _, s := range sinks {
+		h.handlers = append(h.handlers, s.handler(reportCaller, timeFormat))
+	}
+	return h
+}
+
+func (h *routeHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if level < h.level.Level() {
+		return false
+	}
+	for _, s := range h.sinks {
+		if s.enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *routeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for i, s := range h.sinks {
+		if !s.enabled(r.Level) {
+			continue
+		}
+		if err := h.handlers[i].Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, fmt.Errorf("log sink %s: %w", s.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *routeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.derive(func(inner slog.Handler) slog.Handler { return inner.WithAttrs(attrs) })
+}
+
+func (h *routeHandler) WithGroup(name string) slog.Handler {
+	return h.derive(func(inner slog.Handler) slog.Handler { return inner.WithGroup(name) })
+}
+
+func (h *routeHandler) derive(fn func(slog.Handler) slog.Handler) slog.Handler {
+	out := &routeHandler{level: h.level, sinks: h.sinks, handlers: make([]slog.Handler, len(h.handlers))}
+	for i, inner := range h.handlers {
+		out.handlers[i] = fn(inner)
+	}
+	return out
+}
+
+// prefixHandler renders the logger attribute as a "(name) " message prefix,
+// as the single-writer pretty output does.
+type prefixHandler struct {
+	slog.Handler
+	color bool
+}
+
+func (h *prefixHandler) Handle(ctx context.Context, r slog.Record) error {
+	var name string
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "logger" && name == "" {
+			name = a.Value.String()
+		} else {
+			out.AddAttrs(a)
+		}
+		return true
+	})
+	if name != "" {
+		if h.color {
+			name = DarkWhite + name + Reset
+		}
+		out.Message = fmt.Sprintf("(%s) %s", name, r.Message)
+	}
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h *prefixHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &prefixHandler{Handler: h.Handler.WithAttrs(attrs), color: h.color}
+}
+
+func (h *prefixHandler) WithGroup(name string) slog.Handler {
+	return &prefixHandler{Handler: h.Handler.WithGroup(name), color: h.color}
+}
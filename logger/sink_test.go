@@ -0,0 +1,287 @@
+package logger
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useSinks(t *testing.T, s ...*Sink) {
+	t.Helper()
+	if err := SetSinks(s...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetSinks() })
+}
+
+func TestSinks_LevelAndFormatPerSink(t *testing.T) {
+	var pretty, structured lockedBuffer
+	useSinks(t,
+		NewSink(&pretty, FormatText, "info"),
+		NewSink(&structured, FormatJSON, "debug"),
+	)
+
+	lg := New("routed")
+	lg.SetLogLevel("debug")
+	lg.Debugf("debug only")
+	lg.Infof("to both")
+
+	if got := pretty.String(); strings.Contains(got, "debug only") || !strings.Contains(got, "(routed) to both") {
+		t.Errorf("text sink should only have the info record, prefixed with the logger name:\n%s", got)
+	}
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(structured.String()), "\n") {
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid JSON %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 2 || records[0]["msg"] != "debug only" || records[1]["logger"] != "routed" {
+		t.Errorf("json sink should have both records with a logger attr, got %v", records)
+	}
+
+	useSinks(t)
+	if len(GetSinks()) != 0 {
+		t.Error("SetSinks() should remove all sinks")
+	}
+}
+
+func TestSinks_SetWhileLogging(t *testing.T) {
+	var out lockedBuffer
+	t.Cleanup(func() { _ = SetSinks() })
+
+	if err := SetSinks(NewSink(&out, FormatText, "info")); err != nil {
+		t.Fatal(err)
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				GetLogger("db").Infof("query")
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if err := SetSinks(NewSink(&out, FormatText, "info")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	<-done
+
+	GetLogger("db").Infof("after")
+	if !strings.Contains(out.String(), "(db) after") {
+		t.Errorf("existing loggers should write to the installed sink:\n%s", out.String())
+	}
+}
+
+func TestParseSink(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		spec   string
+		format SinkFormat
+		level  string
+	}{
+		{"stderr", FormatPretty, ""},
+		{"stdout?format=json&level=warn", FormatJSON, "WARN"},
+		{"file://" + filepath.Join(dir, "a.log") + "?maxSize=1MB&maxBackups=2", FormatText, ""},
+		{filepath.Join(dir, "b.log") + "?format=logfmt&level=debug", FormatLogfmt, "DEBUG"},
+	} {
+		t.Run(tc.spec, func(t *testing.T) {
+			sink, err := ParseSink(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sink.Close()
+			if sink.Format != tc.format {
+				t.Errorf("format = %s, want %s", sink.Format, tc.format)
+			}
+			if tc.level != "" && (sink.Level == nil || sink.Level.Level().String() != tc.level) {
+				t.Errorf("level = %v, want %s", sink.Level, tc.level)
+			}
+		})
+	}
+
+	if sink, _ := ParseSink(filepath.Join(dir, "c.log") + "?maxSize=10KB"); sink.Writer.(*RotatingFile).opts.MaxSize != 10<<10 {
+		t.Error("maxSize was not applied")
+	}
+
+	for _, spec := range []string{
+		"kafka://broker",
+		"stdout?format=xml",
+		"file://" + filepath.Join(dir, "d.log") + "?maxSize=lots",
+		"file://" + filepath.Join(dir, "e.log") + "?maxAge=soon",
+		"syslog+udp://127.0.0.1:514?facility=nope",
+	} {
+		if _, err := ParseSink(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{"512": 512, "10K": 10 << 10, "10kb": 10 << 10, "100MB": 100 << 20, "1GiB": 1 << 30, "7B": 7} {
+		if got, err := parseSize(in); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	if _, err := parseSize("-1MB"); err == nil {
+		t.Error("negative sizes should be rejected")
+	}
+}
+
+func TestRotatingFile_SizeBackupsAndCompression(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	// another log next to app.log, not one of its backups
+	sibling := filepath.Join(dir, "app-audit.log")
+	if err := os.WriteFile(sibling, []byte("audit\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenRotatingFile(path, RotatingFileOptions{MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "line 4\n" {
+		t.Errorf("active file = %q, want the last line", data)
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log.gz"))
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 compressed backups, got %v", backups)
+	}
+	if uncompressed, _ := filepath.Glob(filepath.Join(dir, "app-2*.log")); len(uncompressed) != 0 {
+		t.Errorf("uncompressed backups left behind: %v", uncompressed)
+	}
+	if data, _ := os.ReadFile(sibling); string(data) != "audit\n" {
+		t.Errorf("%s was removed as a backup", sibling)
+	}
+	if got := readGzip(t, backups[1]); got != "line 3\n" {
+		t.Errorf("newest backup = %q, want line 3", got)
+	}
+}
+
+func TestRotatingFile_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	f, err := OpenRotatingFile(filepath.Join(dir, "app.log"), RotatingFileOptions{MaxAge: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, _ = f.Write([]byte("old\n"))
+	_, _ = f.Write([]byte("still young\n"))
+	time.Sleep(30 * time.Millisecond)
+	_, _ = f.Write([]byte("new\n"))
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %v", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != "old\nstill young\n" {
+		t.Errorf("backup = %q", data)
+	}
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSyslogSink(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	unixPath := filepath.Join(t.TempDir(), "log.sock")
+	unixgram, err := net.ListenPacket("unixgram", unixPath)
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer unixgram.Close()
+
+	for _, tc := range []struct {
+		spec     string
+		listener net.PacketConn
+	}{
+		{"syslog+udp://" + udp.LocalAddr().String() + "?app=commons-test", udp},
+		{"syslog+unixgram://" + unixPath + "?app=commons-test&facility=local0", unixgram},
+	} {
+		t.Run(tc.spec, func(t *testing.T) {
+			sink, err := ParseSink(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			useSinks(t, sink)
+			New("syslog").Errorf("disk full")
+
+			msg := readPacket(t, tc.listener)
+			pri := "<11>1 " // user.err
+			if strings.Contains(tc.spec, "local0") {
+				pri = "<131>1 " // local0.err
+			}
+			if !strings.HasPrefix(msg, pri) || !strings.Contains(msg, " commons-test ") || !strings.Contains(msg, "disk full") {
+				t.Errorf("unexpected syslog message %q", msg)
+			}
+		})
+	}
+}
+
+func readPacket(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestSinks_FromProperties(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	setProperties(t, map[string]string{"log.sinks": "file://" + path + "?format=json"})
+	t.Cleanup(func() { _ = SetSinks() })
+
+	if len(GetSinks()) != 1 {
+		t.Fatalf("expected the log.sinks property to install 1 sink, got %d", len(GetSinks()))
+	}
+	New("props").Infof("written to file")
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"msg":"written to file"`) {
+		t.Errorf("file sink got %q", data)
+	}
+}
@@ -59,6 +59,8 @@ var sharedWriter io.Writer = sharedOutput{}
 
 func init() {
 	currentOutput.Store(&outputBox{w: os.Stderr})
+	properties.MaskSecret = PrintableSecret
+	properties.SensitiveKey = func(key string) bool { return GetRedactionPolicy().IsSensitiveKey(key) }
 }
 
 // SetOutput redirects log output for all loggers — root and every named
@@ -112,10 +114,17 @@ func GetSlogLogger() SlogLogger {
 }
 
 func onPropertyUpdate(props *properties.Properties) {
+	// rebuilt from redact.* and log.sample.* properties on next use
+	propertiesPolicy.Store(nil)
+	updateSamplers(props)
+	updateAsync(props)
+	updateSinks(props)
+	updateSpanEvents(props)
+
 	for k, v := range props.GetAll() {
 		if k == "db.log.level" {
 			GetLogger("db").SetLogLevel(v)
-		} else if strings.HasPrefix(k, "log.level") {
+		} else if strings.HasPrefix(k, "log.level.") {
 			name := strings.TrimPrefix(k, "log.level.")
 			named := GetLogger(strings.Split(name, ".")...)
 			if name == "http" {
@@ -136,14 +145,8 @@ func onPropertyUpdate(props *properties.Properties) {
 }
 
 func New(prefix string) *SlogLogger {
-	// create a new slogger
-	var logger *SlogLogger
 	var lvl = &slog.LevelVar{}
 
-	reportCaller := properties.On(flags.reportCaller, fmt.Sprintf("log.caller.%s", prefix), "log.caller")
-	logJson := properties.On(flags.jsonLogs, "log.json")
-	logColor := properties.On(flags.color, fmt.Sprintf("log.color.%s", prefix), "log.color")
-
 	var rootLevel string
 	if flags.level != "" {
 		rootLevel = flags.level
@@ -152,31 +155,9 @@ func New(prefix string) *SlogLogger {
 	}
 	namedLevel := properties.String(rootLevel, "log.level."+prefix)
 
-	// Handlers write through sharedWriter (a thin indirection over
-	// currentOutput) so a later SetOutput retargets every existing logger,
-	// not just those constructed after the swap.
-	destination := sharedWriter
-	if logJson {
-		flags.color = false
-		flags.jsonLogs = true
-		logger = &SlogLogger{
-			Level: lvl,
-			Logger: slog.New(slog.NewJSONHandler(destination, &slog.HandlerOptions{
-				AddSource: reportCaller,
-				Level:     lvl,
-			})),
-		}
-
-	} else {
-		logger = &SlogLogger{
-			Logger: slog.New(tint.NewHandler(destination, &tint.Options{
-				Level:      lvl,
-				NoColor:    !logColor,
-				AddSource:  reportCaller,
-				TimeFormat: properties.String("15:04:05.000", fmt.Sprintf("log.time.format.%s", prefix), "log.time.format"),
-			})),
-			Level: lvl,
-		}
+	logger := &SlogLogger{
+		Logger: slog.New(newSwapHandler(newHandler(prefix, lvl))),
+		Level:  lvl,
 	}
 
 	if prefix != "" && prefix != rootName {
@@ -189,6 +170,41 @@ func New(prefix string) *SlogLogger {
 	return logger
 }
 
+// newHandler builds the handler for the logger named prefix: a router over
+// the sinks installed with SetSinks, or a JSON / tint handler for the
+// SetOutput writer.
+func newHandler(prefix string, lvl *slog.LevelVar) slog.Handler {
+	reportCaller := properties.On(flags.reportCaller, fmt.Sprintf("log.caller.%s", prefix), "log.caller")
+	logJson := properties.On(flags.jsonLogs, "log.json")
+	logColor := properties.On(flags.color, fmt.Sprintf("log.color.%s", prefix), "log.color")
+	timeFormat := properties.String("15:04:05.000", fmt.Sprintf("log.time.format.%s", prefix), "log.time.format")
+
+	if sinks := GetSinks(); len(sinks) > 0 {
+		return newTraceHandler(newRouteHandler(lvl, sinks, reportCaller, timeFormat))
+	}
+
+	// Handlers write through sharedWriter (a thin indirection over
+	// currentOutput) so a later SetOutput retargets every existing logger,
+	// not just those constructed after the swap.
+	destination := sharedWriter
+	if logJson {
+		flags.color = false
+		flags.jsonLogs = true
+		return newTraceHandler(slog.NewJSONHandler(destination, &slog.HandlerOptions{
+			AddSource:   reportCaller,
+			Level:       lvl,
+			ReplaceAttr: redactAttr,
+		}))
+	}
+	return newTraceHandler(tint.NewHandler(destination, &tint.Options{
+		Level:       lvl,
+		NoColor:     !logColor,
+		AddSource:   reportCaller,
+		ReplaceAttr: redactAttr,
+		TimeFormat:  timeFormat,
+	}))
+}
+
 // NewWithWriter creates a new SlogLogger that writes to the specified writer.
 // This is useful for integrating with test frameworks or custom output destinations.
 func NewWithWriter(writer io.Writer) *SlogLogger {
@@ -209,19 +225,21 @@ func NewWithWriter(writer io.Writer) *SlogLogger {
 	if logJson {
 		logger = &SlogLogger{
 			Level: lvl,
-			Logger: slog.New(slog.NewJSONHandler(writer, &slog.HandlerOptions{
-				AddSource: reportCaller,
-				Level:     lvl,
-			})),
+			Logger: slog.New(newTraceHandler(slog.NewJSONHandler(writer, &slog.HandlerOptions{
+				AddSource:   reportCaller,
+				Level:       lvl,
+				ReplaceAttr: redactAttr,
+			}))),
 		}
 	} else {
 		logger = &SlogLogger{
-			Logger: slog.New(tint.NewHandler(writer, &tint.Options{
-				Level:      lvl,
-				NoColor:    !logColor,
-				AddSource:  reportCaller,
-				TimeFormat: properties.String("15:04:05.000", "log.time.format"),
-			})),
+			Logger: slog.New(newTraceHandler(tint.NewHandler(writer, &tint.Options{
+				Level:       lvl,
+				NoColor:     !logColor,
+				AddSource:   reportCaller,
+				ReplaceAttr: redactAttr,
+				TimeFormat:  properties.String("15:04:05.000", "log.time.format"),
+			}))),
 			Level: lvl,
 		}
 	}
@@ -270,6 +288,12 @@ func camelCaseWords(s string) []string {
 	return lo.Map(strings.Fields(result.String()), func(s string, _ int) string { return strings.TrimSpace(s) })
 }
 
+// loggerPath normalizes a name the way GetLogger does, e.g. "dbPool" to
+// "db pool".
+func loggerPath(name string) string {
+	return strings.TrimSpace(strings.ToLower(strings.Join(camelCaseWords(name), " ")))
+}
+
 // GetLogger returns a logger instance, optionally with the specified names.
 // If no names are provided, returns the root logger.
 // Multiple names create a hierarchical logger (e.g., GetLogger("app", "db") creates "app.db").
@@ -286,11 +310,10 @@ func GetLogger(names ...string) *SlogLogger {
 
 	path := ""
 	for i, name := range names {
-		name = strings.ToLower(strings.Join(camelCaseWords(name), " "))
 		if path != "" {
 			path += "."
 		}
-		path = path + strings.TrimSpace(name)
+		path = path + loggerPath(name)
 		if v, ok := namedLoggers.Load(path); ok {
 			return v
 		}
@@ -308,6 +331,8 @@ type SlogLogger struct {
 	Level     *slog.LevelVar
 	Parent    *SlogLogger
 	skipLevel int
+	// ctx is passed to the handler with each record, see WithContext
+	ctx context.Context
 }
 
 func (s SlogLogger) Warnf(format string, args ...interface{}) {
@@ -341,7 +366,11 @@ func (s SlogLogger) Errorf(format string, args ...interface{}) {
 	if !s.Logger.Enabled(todo, slog.LevelError) {
 		return
 	}
-	s.handle(slog.NewRecord(time.Now(), slog.LevelError, "", CallerPC()), format, args...)
+	r := slog.NewRecord(time.Now(), slog.LevelError, "", CallerPC())
+	if err := firstError(args); err != nil && structuredOutput() {
+		r.AddAttrs(ExtractError(err).Attr())
+	}
+	s.handle(r, format, args...)
 }
 
 func (s SlogLogger) Debugf(format string, args ...interface{}) {
@@ -354,14 +383,41 @@ func (s SlogLogger) Debugf(format string, args ...interface{}) {
 
 func (s SlogLogger) handle(r slog.Record, format string, args ...interface{}) {
 	if len(args) == 0 {
-		s.handleRaw(r, format)
+		s.emit(r, format, format)
 	} else {
-		s.handleRaw(r, fmt.Sprintf(format, args...))
+		s.emit(r, format, fmt.Sprintf(format, args...))
 	}
 }
 
 func (s SlogLogger) handleRaw(r slog.Record, msg string) {
-	if IsJsonLogs() {
+	s.emit(r, msg, msg)
+}
+
+// emit writes the record unless it is sampled out or collapsed into a
+// "repeated" summary, see SampleConfig.
+func (s SlogLogger) emit(r slog.Record, template, msg string) {
+	if !getSampler(s.name()).allow(s, r, template, msg) {
+		return
+	}
+	s.write(r, msg)
+}
+
+// name returns the logger name used for per-logger properties.
+func (s SlogLogger) name() string {
+	if s.Prefix == "" {
+		return rootName
+	}
+	return s.Prefix
+}
+
+// structuredOutput returns true if records are written as JSON or to sinks,
+// where attributes are preferred over text in the message.
+func structuredOutput() bool {
+	return IsJsonLogs() || len(GetSinks()) > 0
+}
+
+func (s SlogLogger) write(r slog.Record, msg string) {
+	if structuredOutput() {
 		if s.Prefix != "" {
 			r.Add("logger", s.Prefix)
 		}
@@ -371,7 +427,7 @@ func (s SlogLogger) handleRaw(r slog.Record, msg string) {
 	} else {
 		r.Message = msg
 	}
-	_ = s.Logger.Handler().Handle(context.Background(), r)
+	_ = s.Logger.Handler().Handle(s.context(), r)
 }
 
 func (s SlogLogger) Tracef(format string, args ...interface{}) {
@@ -383,7 +439,10 @@ func (s SlogLogger) Tracef(format string, args ...interface{}) {
 }
 
 func (s SlogLogger) Fatalf(format string, args ...interface{}) {
+	// drain async output first, so the fatal record cannot be dropped
+	_ = Flush()
 	s.handle(slog.NewRecord(time.Now(), SlogFatal, "", CallerPC()), format, args...)
+	_ = Flush()
 }
 
 func (s SlogLogger) DebugLevels() {
@@ -408,6 +467,7 @@ func (v slogVerbose) Always() Verbose {
 		Logger: slog.New(&alwaysHandler{inner: v.SlogLogger.Logger.Handler()}),
 		Level:  v.SlogLogger.Level,
 		Prefix: v.SlogLogger.Prefix,
+		ctx:    v.SlogLogger.ctx,
 	}
 	return v
 }
@@ -611,6 +671,7 @@ func (s SlogLogger) WithSkipReportLevel(i int) Logger {
 		Level:     s.Level,
 		Prefix:    s.Prefix,
 		skipLevel: i,
+		ctx:       s.ctx,
 	}
 }
 
@@ -619,6 +680,7 @@ func (s SlogLogger) WithValues(keysAndValues ...interface{}) Logger {
 		Logger: s.Logger.With(keysAndValues...),
 		Level:  s.Level,
 		Prefix: s.Prefix,
+		ctx:    s.ctx,
 	}
 }
 
@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/flanksource/commons/properties"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceIDKey and SpanIDKey are the attributes added to records logged
+	// with a context carrying a valid span.
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// spanEventLevel is the minimum level recorded as span events, nil when
+// disabled. Set by the log.span.events property.
+var spanEventLevel atomic.Pointer[slog.Level]
+
+// SpanEventsEnabled returns true if records at level are added as events to
+// the span in their context, see the log.span.events property.
+func SpanEventsEnabled(level slog.Level) bool {
+	min := spanEventLevel.Load()
+	return min != nil && level >= *min
+}
+
+// updateSpanEvents applies the log.span.events property: a level (e.g. warn)
+// at or above which records are also added to the active span as events.
+func updateSpanEvents(props *properties.Properties) {
+	level := props.Get("log.span.events")
+	if level == "" || level == "false" {
+		spanEventLevel.Store(nil)
+		return
+	}
+	min := ParseLevel(nil, level).Slog()
+	spanEventLevel.Store(&min)
+}
+
+// WithContext returns a logger that writes records with ctx, so they carry
+// the trace_id and span_id of the span in ctx. Loggers that do not support
+// contexts are returned unchanged.
+func WithContext(log Logger, ctx context.Context) Logger {
+	if l, ok := log.(interface {
+		WithContext(context.Context) Logger
+	}); ok {
+		return l.WithContext(ctx)
+	}
+	return log
+}
+
+// WithContext returns a logger that writes records with ctx, see WithContext.
+func (s SlogLogger) WithContext(ctx context.Context) Logger {
+	s.ctx = ctx
+	return s
+}
+
+func (s SlogLogger) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// traceHandler correlates records with the span in their context: it adds
+// trace_id and span_id attributes and, when enabled, records the message as a
+// span event.
+type traceHandler struct {
+	inner slog.Handler
+	// attrs and group mirror WithAttrs / WithGroup for span event attributes
+	attrs []attribute.KeyValue
+	group string
+}
+
+func newTraceHandler(inner slog.Handler) slog.Handler {
+	return &traceHandler{inner: inner}
+}
+
+func (h *traceHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	span := trace.SpanFromContext(ctx)
+	if SpanEventsEnabled(r.Level) && span.IsRecording() {
+		attrs := append([]attribute.KeyValue{attribute.String("level", FromSlogLevel(r.Level).String())}, h.attrs...)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = appendSpanAttr(attrs, h.group, a)
+			return true
+		})
+		span.AddEvent(r.Message, trace.WithTimestamp(r.Time), trace.WithAttributes(attrs...))
+	}
+	sc := span.SpanContext()
+	if !sc.IsValid() && ctx != nil {
+		// IDs generated by NewHttpServerLogger for a request without a span
+		sc, _ = ctx.Value(logSpanKey{}).(trace.SpanContext)
+	}
+	if sc.IsValid() {
+		r = r.Clone()
+		r.AddAttrs(slog.String(TraceIDKey, sc.TraceID().String()), slog.String(SpanIDKey, sc.SpanID().String()))
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := &traceHandler{inner: h.inner.WithAttrs(attrs), attrs: h.attrs[:len(h.attrs):len(h.attrs)], group: h.group}
+	for _, a := range attrs {
+		derived.attrs = appendSpanAttr(derived.attrs, h.group, a)
+	}
+	return derived
+}
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{inner: h.inner.WithGroup(name), attrs: h.attrs, group: h.group + name + "."}
+}
+
+// appendSpanAttr converts a redacted slog attribute to span event attributes,
+// flattening groups into dotted keys.
+func appendSpanAttr(attrs []attribute.KeyValue, prefix string, a slog.Attr) []attribute.KeyValue {
+	a.Value = a.Value.Resolve()
+	a = redactAttr(nil, a)
+	key := prefix + a.Key
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		for _, member := range a.Value.Group() {
+			attrs = appendSpanAttr(attrs, key+".", member)
+		}
+	case slog.KindBool:
+		attrs = append(attrs, attribute.Bool(key, a.Value.Bool()))
+	case slog.KindInt64:
+		attrs = append(attrs, attribute.Int64(key, a.Value.Int64()))
+	case slog.KindFloat64:
+		attrs = append(attrs, attribute.Float64(key, a.Value.Float64()))
+	default:
+		attrs = append(attrs, attribute.String(key, fmt.Sprint(a.Value.Any())))
+	}
+	return attrs
+}
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func startSpan(t *testing.T) (context.Context, *tracetest.InMemoryExporter, func()) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	ctx, span := provider.Tracer("test").Start(context.Background(), "operation")
+	return ctx, exporter, func() { span.End() }
+}
+
+func TestWithContext_AddsTraceAndSpanIDs(t *testing.T) {
+	ctx, _, end := startSpan(t)
+	defer end()
+	sc := trace.SpanContextFromContext(ctx)
+
+	for _, tc := range []struct {
+		name string
+		json bool
+	}{{"json", true}, {"pretty", false}} {
+		t.Run(tc.name, func(t *testing.T) {
+			setProperties(t, map[string]string{"log.json": strconv.FormatBool(tc.json), "log.color": "false"})
+			var buf bytes.Buffer
+			lg := NewWithWriter(&buf)
+
+			WithContext(lg, ctx).Infof("correlated")
+			lg.Infof("uncorrelated")
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("expected 2 lines, got %q", buf.String())
+			}
+			if tc.json {
+				var record map[string]any
+				if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+					t.Fatal(err)
+				}
+				if record[TraceIDKey] != sc.TraceID().String() || record[SpanIDKey] != sc.SpanID().String() {
+					t.Errorf("record missing trace and span ids: %v", record)
+				}
+			} else if !strings.Contains(lines[0], "trace_id="+sc.TraceID().String()) || !strings.Contains(lines[0], "span_id="+sc.SpanID().String()) {
+				t.Errorf("line missing trace and span ids: %q", lines[0])
+			}
+			if strings.Contains(lines[1], TraceIDKey) {
+				t.Errorf("records without a span should not have a trace_id: %q", lines[1])
+			}
+		})
+	}
+}
+
+func TestWithContext_SurvivesDerivedLoggers(t *testing.T) {
+	ctx, _, end := startSpan(t)
+	defer end()
+	var buf bytes.Buffer
+	lg := WithContext(NewWithWriter(&buf), ctx)
+
+	lg.WithValues("k", "v").Infof("with values")
+	lg.WithSkipReportLevel(1).Infof("skipped")
+	lg.V(Info).Infof("verbose")
+
+	if n := strings.Count(buf.String(), TraceIDKey); n != 3 {
+		t.Errorf("expected every derived logger to keep the context, got %d ids in:\n%s", n, buf.String())
+	}
+}
+
+func TestSpanEvents(t *testing.T) {
+	setProperties(t, map[string]string{"log.span.events": "warn"})
+	ctx, exporter, end := startSpan(t)
+	var buf bytes.Buffer
+	lg := WithContext(NewWithWriter(&buf), ctx).WithValues("attempt", 3)
+
+	lg.Infof("below threshold")
+	lg.Warnf("retrying")
+	lg.Errorf("gave up")
+	end()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	var events []string
+	for _, e := range spans[0].Events {
+		attrs := map[string]string{}
+		for _, a := range e.Attributes {
+			attrs[string(a.Key)] = a.Value.Emit()
+		}
+		events = append(events, e.Name+" "+attrs["level"]+" "+attrs["attempt"])
+	}
+	if got := strings.Join(events, ", "); got != "retrying warn 3, gave up error 3" {
+		t.Errorf("got events %q", got)
+	}
+
+	if !SpanEventsEnabled(slog.LevelError) || SpanEventsEnabled(slog.LevelInfo) {
+		t.Error("SpanEventsEnabled should follow log.span.events")
+	}
+	setProperties(t, map[string]string{"log.span.events": ""})
+	if SpanEventsEnabled(SlogFatal) {
+		t.Error("span events should be disabled when log.span.events is unset")
+	}
+}
@@ -0,0 +1,230 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslog severities, RFC 5424 section 6.2.1
+const (
+	syslogCritical = 2
+	syslogError    = 3
+	syslogWarning  = 4
+	syslogInfo     = 6
+	syslogDebug    = 7
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// ParseSyslogFacility parses a facility name (user, daemon, local0…) or number.
+func ParseSyslogFacility(s string) (int, error) {
+	if f, ok := syslogFacilities[strings.ToLower(s)]; ok {
+		return f, nil
+	}
+	if f, err := strconv.Atoi(s); err == nil && f >= 0 && f <= 23 {
+		return f, nil
+	}
+	return 0, fmt.Errorf("invalid syslog facility %q", s)
+}
+
+// SyslogOptions configures a SyslogWriter.
+type SyslogOptions struct {
+	// Network is unix, unixgram or udp. When empty, the local syslog socket
+	// (/dev/log, /var/run/syslog or /var/run/log) is used.
+	Network string
+
+	// Address is the socket path, or host:port for udp.
+	Address string
+
+	// Facility defaults to 1 (user).
+	Facility int
+
+	// AppName defaults to the program name.
+	AppName string
+
+	// Hostname defaults to os.Hostname.
+	Hostname string
+}
+
+// SyslogWriter sends each Write as an RFC 5424 message to a local or UDP
+// syslog daemon. Written directly, messages have severity info; used as a
+// Sink writer, the severity follows each record's level.
+type SyslogWriter struct {
+	opts SyslogOptions
+	pid  string
+
+	mu   sync.Mutex
+	conn net.Conn
+
+	// handling is held by syslogHandler while a record is written, and
+	// severity is that record's severity
+	handling sync.Mutex
+	severity int
+}
+
+// DialSyslog connects to the syslog daemon described by opts.
+func DialSyslog(opts SyslogOptions) (*SyslogWriter, error) {
+	if opts.Facility == 0 {
+		opts.Facility = 1
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	w := &SyslogWriter{opts: opts, pid: strconv.Itoa(os.Getpid()), severity: syslogInfo}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SyslogWriter) connect() error {
+	if w.opts.Network != "" {
+		conn, err := net.Dial(w.opts.Network, w.opts.Address)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+		return nil
+	}
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				w.conn = conn
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no local syslog socket found")
+}
+
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	msg := w.format(w.severity, time.Now(), strings.TrimRight(string(p), "\n"))
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := w.conn.Write(msg); err != nil {
+		// the daemon may have restarted: reconnect once
+		_ = w.conn.Close()
+		w.conn = nil
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+		if _, err := w.conn.Write(msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to the daemon.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// format builds <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+func (w *SyslogWriter) format(severity int, t time.Time, msg string) []byte {
+	out := fmt.Sprintf("<%d>1 %s %s %s %s - - %s",
+		w.opts.Facility*8+severity,
+		t.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(w.opts.Hostname, 255),
+		syslogField(w.opts.AppName, 48),
+		w.pid,
+		msg)
+	if w.opts.Network == "unix" || w.opts.Network == "tcp" {
+		// stream sockets need a frame delimiter
+		out += "\n"
+	}
+	return []byte(out)
+}
+
+// syslogField returns s as a header field: printable ASCII, max n chars, or "-".
+func syslogField(s string, n int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > n {
+		s = s[:n]
+	}
+	return s
+}
+
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= SlogFatal:
+		return syslogCritical
+	case level >= slog.LevelError:
+		return syslogError
+	case level >= slog.LevelWarn:
+		return syslogWarning
+	case level >= slog.LevelInfo:
+		return syslogInfo
+	default:
+		return syslogDebug
+	}
+}
+
+// syslogHandler sets the syslog severity from the record level before the
+// inner handler formats the record into w.
+type syslogHandler struct {
+	inner slog.Handler
+	w     *SyslogWriter
+}
+
+func (h *syslogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.w.handling.Lock()
+	defer h.w.handling.Unlock()
+	h.w.mu.Lock()
+	h.w.severity = syslogSeverity(r.Level)
+	h.w.mu.Unlock()
+	defer func() {
+		h.w.mu.Lock()
+		h.w.severity = syslogInfo
+		h.w.mu.Unlock()
+	}()
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithAttrs(attrs), w: h.w}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{inner: h.inner.WithGroup(name), w: h.w}
+}
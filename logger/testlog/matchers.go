@@ -0,0 +1,56 @@
+package testlog
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/onsi/gomega/types"
+)
+
+// HaveLogged succeeds if a *Capture holds a record at level (anything
+// logger.ParseLevel accepts, or nil for any level) whose message contains
+// substr:
+//
+//	g.Expect(logs).To(testlog.HaveLogged("error", "connection refused"))
+//	g.Expect(logs).NotTo(testlog.HaveLogged(nil, "password"))
+func HaveLogged(level any, substr string) types.GomegaMatcher {
+	return &haveLoggedMatcher{level: level, substr: substr}
+}
+
+type haveLoggedMatcher struct {
+	level  any
+	substr string
+	logs   string
+}
+
+func (m *haveLoggedMatcher) Match(actual any) (bool, error) {
+	c, ok := actual.(*Capture)
+	if !ok {
+		return false, fmt.Errorf("HaveLogged expects a *testlog.Capture, got %T", actual)
+	}
+	m.logs = c.String()
+	return len(c.Find(m.level, m.substr)) > 0, nil
+}
+
+func (m *haveLoggedMatcher) FailureMessage(any) string {
+	return fmt.Sprintf("Expected a %s record containing %q in:\n%s", m.describeLevel(), m.substr, m.indentedLogs())
+}
+
+func (m *haveLoggedMatcher) NegatedFailureMessage(any) string {
+	return fmt.Sprintf("Expected no %s record containing %q in:\n%s", m.describeLevel(), m.substr, m.indentedLogs())
+}
+
+func (m *haveLoggedMatcher) describeLevel() string {
+	if m.level == nil {
+		return "log"
+	}
+	return logger.ParseLevel(nil, m.level).String()
+}
+
+func (m *haveLoggedMatcher) indentedLogs() string {
+	if m.logs == "" {
+		return "    <no records>"
+	}
+	return "    " + strings.ReplaceAll(strings.TrimSuffix(m.logs, "\n"), "\n", "\n    ")
+}
@@ -0,0 +1,240 @@
+// Package testlog captures log output in tests as structured records, with
+// Gomega matchers and golden file comparison:
+//
+//	func TestSync(t *testing.T) {
+//		logs := testlog.New(t)
+//		sync()
+//		g := gomega.NewWithT(t)
+//		g.Expect(logs).To(testlog.HaveLogged("warn", "retrying"))
+//		logs.MatchGolden(t, "testdata/sync.golden")
+//	}
+//
+// Capturing replaces the process-wide log output (see logger.SetSinks) until
+// the test ends, so tests using it must not run in parallel. The captured
+// records are written to the test log if the test fails.
+package testlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/commons/tokenizer"
+)
+
+// Record is a captured log record.
+type Record struct {
+	Time    time.Time
+	Level   logger.LogLevel
+	Logger  string
+	Message string
+	// Attrs holds the record's attributes and those added with WithValues,
+	// with groups flattened into dotted keys.
+	Attrs map[string]any
+}
+
+// String renders the record without its time, e.g. `warn (db) retrying attempt=2`.
+func (r Record) String() string {
+	return r.render(func(v any) any { return v })
+}
+
+func (r Record) render(value func(any) any) string {
+	var b strings.Builder
+	b.WriteString(r.Level.String())
+	if r.Logger != "" {
+		b.WriteString(" (" + r.Logger + ")")
+	}
+	b.WriteString(" " + fmt.Sprint(value(r.Message)))
+	keys := make([]string, 0, len(r.Attrs))
+	for k := range r.Attrs {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, value(r.Attrs[k]))
+	}
+	return b.String()
+}
+
+// Capture collects the records logged while a test runs.
+type Capture struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// New captures log output until t ends, restoring the previous sinks (or the
+// SetOutput writer) afterwards. Existing sinks keep receiving records; when
+// there are none, output to stderr is suppressed while capturing. Records
+// are still filtered by each logger's level.
+func New(t testing.TB) *Capture {
+	t.Helper()
+	c := &Capture{}
+	previous := logger.GetSinks()
+	sink := &logger.Sink{Name: "testlog", Handler: &handler{capture: c}}
+	if err := logger.SetSinks(append(slices.Clone(previous), sink)...); err != nil {
+		t.Fatalf("capturing logs: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = logger.SetSinks(previous...)
+		if t.Failed() {
+			t.Logf("captured logs:\n%s", c)
+		}
+	})
+	return c
+}
+
+// Records returns a copy of the captured records.
+func (c *Capture) Records() []Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.records)
+}
+
+// Find returns the captured records at level (anything logger.ParseLevel
+// accepts, or nil for any level) whose message contains substr.
+func (c *Capture) Find(level any, substr string) []Record {
+	var found []Record
+	for _, r := range c.Records() {
+		if (level == nil || r.Level == logger.ParseLevel(nil, level)) && strings.Contains(r.Message, substr) {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// Reset discards the captured records.
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = nil
+}
+
+// String renders each record on its own line.
+func (c *Capture) String() string {
+	var b strings.Builder
+	for _, r := range c.Records() {
+		b.WriteString(r.String() + "\n")
+	}
+	return b.String()
+}
+
+// Normalized renders the records like String, with timestamps, durations,
+// UUIDs and hashes replaced by tokens (see the tokenizer package), so output
+// can be compared across runs.
+func (c *Capture) Normalized() string {
+	var b strings.Builder
+	for _, r := range c.Records() {
+		b.WriteString(r.render(normalize) + "\n")
+	}
+	return b.String()
+}
+
+// MatchGolden compares the normalized records with the golden file at path,
+// failing t with both versions if they differ. With UPDATE_GOLDEN=true in
+// the environment the file is (re)written instead.
+func (c *Capture) MatchGolden(t testing.TB, path string) {
+	t.Helper()
+	actual := c.Normalized()
+	if os.Getenv("UPDATE_GOLDEN") == "true" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with UPDATE_GOLDEN=true to create it): %v", err)
+	}
+	if string(expected) != actual {
+		t.Errorf("logs do not match %s (run with UPDATE_GOLDEN=true to update it)\nexpected:\n%s\nactual:\n%s", path, expected, actual)
+	}
+}
+
+func normalize(v any) any {
+	switch v := v.(type) {
+	case time.Duration:
+		return "DURATION"
+	case time.Time:
+		return "TIMESTAMP"
+	case string:
+		// the tokenizer only matches durations following whitespace
+		return strings.TrimSpace(tokenizer.Tokenize(" " + v))
+	}
+	return v
+}
+
+func (c *Capture) add(r Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, r)
+}
+
+// handler converts slog records to Records.
+type handler struct {
+	capture *Capture
+	attrs   map[string]any
+	group   string
+}
+
+func (h *handler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *handler) Handle(_ context.Context, r slog.Record) error {
+	record := Record{
+		Time:    r.Time,
+		Level:   logger.FromSlogLevel(r.Level),
+		Message: r.Message,
+		Attrs:   make(map[string]any, len(h.attrs)+r.NumAttrs()),
+	}
+	for k, v := range h.attrs {
+		record.Attrs[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "logger" {
+			record.Logger = a.Value.String()
+		} else {
+			addAttr(record.Attrs, h.group, a)
+		}
+		return true
+	})
+	h.capture.add(record)
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := &handler{capture: h.capture, group: h.group, attrs: make(map[string]any, len(h.attrs)+len(attrs))}
+	for k, v := range h.attrs {
+		derived.attrs[k] = v
+	}
+	for _, a := range attrs {
+		addAttr(derived.attrs, h.group, a)
+	}
+	return derived
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{capture: h.capture, attrs: h.attrs, group: h.group + name + "."}
+}
+
+func addAttr(attrs map[string]any, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		for _, member := range a.Value.Group() {
+			addAttr(attrs, prefix+a.Key+".", member)
+		}
+		return
+	}
+	attrs[prefix+a.Key] = a.Value.Any()
+}
@@ -0,0 +1,87 @@
+package testlog_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/commons/logger/testlog"
+	. "github.com/onsi/gomega"
+)
+
+func TestCapture(t *testing.T) {
+	g := NewWithT(t)
+	logs := testlog.New(t)
+
+	lg := logger.GetLogger("testlog")
+	lg.WithValues("attempt", 2).Warnf("retrying %s", "sync")
+	logger.Infof("from the root logger")
+
+	g.Expect(logs).To(testlog.HaveLogged("warn", "retrying sync"))
+	g.Expect(logs).To(testlog.HaveLogged(nil, "root logger"))
+	g.Expect(logs).NotTo(testlog.HaveLogged("error", "retrying"))
+
+	records := logs.Find(logger.Warn, "retrying")
+	g.Expect(records).To(HaveLen(1))
+	g.Expect(records[0].Logger).To(Equal("testlog"))
+	g.Expect(records[0].Attrs).To(HaveKeyWithValue("attempt", int64(2)))
+	g.Expect(records[0].String()).To(Equal("warn (testlog) retrying sync attempt=2"))
+
+	logs.Reset()
+	g.Expect(logs.Records()).To(BeEmpty())
+}
+
+func TestHaveLogged_FailureMessage(t *testing.T) {
+	g := NewWithT(t)
+	logs := testlog.New(t)
+	logger.GetLogger("testlog").Infof("started")
+
+	matcher := testlog.HaveLogged("error", "crashed")
+	ok, err := matcher.Match(logs)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ok).To(BeFalse())
+	g.Expect(matcher.FailureMessage(logs)).To(ContainSubstring("Expected a error record containing \"crashed\" in:\n    info (testlog) started"))
+
+	_, err = matcher.Match("not a capture")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestMatchGolden(t *testing.T) {
+	logs := testlog.New(t)
+	lg := logger.GetLogger("testlog")
+	lg.Infof("request %s received at %s", "3f2504e0-4f89-11d3-9a0c-0305e82c3301", time.Now().Format(time.RFC3339))
+	lg.WithValues("took", fmt.Sprintf("%dms", time.Now().Nanosecond()%1000)).Infof("request completed")
+
+	logs.MatchGolden(t, "testdata/capture.golden")
+}
+
+// fakeT records what the harness does with a failed test.
+type fakeT struct {
+	testing.TB
+	cleanups []func()
+	logs     strings.Builder
+}
+
+func (f *fakeT) Helper()                           {}
+func (f *fakeT) Failed() bool                      { return true }
+func (f *fakeT) Cleanup(fn func())                 { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeT) Logf(format string, args ...any)   { fmt.Fprintf(&f.logs, format, args...) }
+func (f *fakeT) Fatalf(format string, args ...any) { panic(fmt.Sprintf(format, args...)) }
+
+func TestDumpOnFailure(t *testing.T) {
+	ft := &fakeT{}
+	testlog.New(ft)
+	logger.GetLogger("testlog").Errorf("something broke")
+	for _, fn := range ft.cleanups {
+		fn()
+	}
+
+	if !strings.Contains(ft.logs.String(), "error (testlog) something broke") {
+		t.Errorf("failed tests should dump the captured logs, got %q", ft.logs.String())
+	}
+	if len(logger.GetSinks()) != 0 {
+		t.Error("the previous output should be restored")
+	}
+}
@@ -0,0 +1,334 @@
+package properties
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/timeinterval"
+)
+
+var (
+	timeDurationType  = reflect.TypeFor[time.Duration]()
+	durationType      = reflect.TypeFor[duration.Duration]()
+	timeIntervalsType = reflect.TypeFor[timeinterval.TimeIntervals]()
+	textUnmarshaler   = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Binding keeps a struct populated from properties, see Bind.
+type Binding[T any] struct {
+	p        *Properties
+	prefix   string
+	defaults T
+	current  atomic.Pointer[T]
+	// lock serializes updates and protects listeners
+	lock      sync.Mutex
+	listeners []func(Change[T])
+	remove    func()
+}
+
+// Change describes an update of a bound struct, see Binding.OnChange.
+type Change[T any] struct {
+	Old, New T
+	// Fields are the paths of the changed fields, e.g. "Timeout" or "DB.Host".
+	Fields []string
+}
+
+// Changed returns true if the field at path changed.
+func (c Change[T]) Changed(path string) bool {
+	return slices.Contains(c.Fields, path)
+}
+
+// Bind populates target, a pointer to a struct, from the global properties
+// under prefix and updates it whenever they change, see BindProperties.
+func Bind[T any](target *T, prefix string) (*Binding[T], error) {
+	return BindProperties(Global, target, prefix)
+}
+
+// BindProperties populates target, a pointer to a struct, from properties
+// under prefix, and keeps a copy that Load returns updated whenever they
+// change, e.g. when a watched file is reloaded.
+//
+// Each exported field is read from the property prefix.<name>, where name is
+// the field's `property` tag, or its lower cased name. Fields tagged
+// `property:"-"` are skipped and nested structs read properties under their
+// own name, embedded structs under the same prefix:
+//
+//	type Config struct {
+//		Timeout  duration.Duration           `property:"timeout"`
+//		Hosts    []string                    `property:"hosts"` // a,b or ["a","b"]
+//		Schedule timeinterval.TimeIntervals `property:"schedule"`
+//		DB       struct {
+//			Host string `property:"host"` // app.db.host
+//		} `property:"db"`
+//	}
+//	cfg := Config{Timeout: duration.Duration(time.Minute)} // values of unset properties
+//	binding, err := properties.Bind(&cfg, "app")
+//	timeout := binding.Load().Timeout
+//
+// Fields support strings, bools, numbers, time.Duration, duration.Duration,
+// timeinterval.TimeIntervals, encoding.TextUnmarshaler, and pointers to and
+// slices of these, as comma separated or JSON array values.
+//
+// The values of target when it is bound are used for unset properties.
+// target is only written when it is bound: an update atomically replaces the
+// struct Load returns, so it can be read concurrently. If a property has an
+// invalid value, the update is skipped and the struct keeps its previous
+// values.
+func BindProperties[T any](p *Properties, target *T, prefix string) (*Binding[T], error) {
+	if reflect.TypeFor[T]().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot bind properties to %T, a struct is required", *target)
+	}
+	b := &Binding[T]{p: p, prefix: prefix, defaults: *target}
+	value, err := b.decode()
+	if err != nil {
+		return nil, err
+	}
+	*target = value
+	b.current.Store(&value)
+	b.remove = p.addListener(b.reload)
+	return b, nil
+}
+
+// Load returns a copy of the bound struct.
+func (b *Binding[T]) Load() T {
+	return *b.current.Load()
+}
+
+// OnChange registers fn to be called after the bound struct is updated with
+// different values.
+func (b *Binding[T]) OnChange(fn func(Change[T])) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+// Close stops updating the bound struct and unregisters its listener.
+func (b *Binding[T]) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.remove != nil {
+		b.remove()
+		b.remove = nil
+	}
+}
+
+func (b *Binding[T]) reload(*Properties) {
+	value, err := b.decode()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to update %T from properties: %v", value, err))
+		return
+	}
+	b.lock.Lock()
+	if b.remove == nil {
+		// closed while notified
+		b.lock.Unlock()
+		return
+	}
+	old := *b.current.Load()
+	fields := changedFields(reflect.ValueOf(old), reflect.ValueOf(value), "")
+	if len(fields) > 0 {
+		b.current.Store(&value)
+	}
+	listeners := slices.Clone(b.listeners)
+	b.lock.Unlock()
+
+	if len(fields) == 0 {
+		return
+	}
+	change := Change[T]{Old: old, New: value, Fields: fields}
+	for _, fn := range listeners {
+		fn(change)
+	}
+}
+
+func (b *Binding[T]) decode() (T, error) {
+	value := b.defaults
+	err := decodeStruct(b.p, reflect.ValueOf(&value).Elem(), b.prefix)
+	return value, err
+}
+
+// boundFields calls fn with each exported field of struct type t that is
+// bound to a property, and the name of the property.
+func boundFields(t reflect.Type, fn func(i int, f reflect.StructField, name string)) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name := f.Tag.Get("property")
+		embedded := f.Anonymous && nested(f.Type)
+		if (!f.IsExported() && !embedded) || name == "-" {
+			continue
+		}
+		if name == "" && !embedded {
+			name = strings.ToLower(f.Name)
+		}
+		fn(i, f, name)
+	}
+}
+
+// nested returns true for struct fields that are bound field by field.
+func nested(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshaler)
+}
+
+func joinKey(prefix, name string) string {
+	switch {
+	case prefix == "":
+		return name
+	case name == "":
+		return prefix
+	}
+	return prefix + "." + name
+}
+
+func decodeStruct(p *Properties, v reflect.Value, prefix string) error {
+	var errs []error
+	boundFields(v.Type(), func(i int, f reflect.StructField, name string) {
+		key := joinKey(prefix, name)
+		if nested(f.Type) {
+			errs = append(errs, decodeStruct(p, v.Field(i), key))
+			return
+		}
+		if s := p.Get(key); s != "" {
+			if err := decodeValue(v.Field(i), s); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for %s: %w", s, key, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// decodeValue sets v, which must be addressable, from a property value.
+func decodeValue(v reflect.Value, s string) error {
+	switch v.Type() {
+	case timeDurationType, durationType:
+		d, err := duration.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case timeIntervalsType:
+		var intervals timeinterval.TimeIntervals
+		if err := json.Unmarshal([]byte(s), &intervals); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(intervals))
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		switch strings.ToLower(s) {
+		case "true":
+			v.SetBool(true)
+		case "false":
+			v.SetBool(false)
+		default:
+			return errors.New("expected true or false")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		return decodeSlice(v, s)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// decodeSlice sets a slice from a JSON array, e.g. a list in a YAML file, or
+// comma separated values.
+func decodeSlice(v reflect.Value, s string) error {
+	var items []string
+	if trimmed := strings.TrimSpace(s); strings.HasPrefix(trimmed, "[") {
+		if nested(v.Type().Elem()) {
+			slice := reflect.New(v.Type())
+			if err := json.Unmarshal([]byte(trimmed), slice.Interface()); err != nil {
+				return err
+			}
+			v.Set(slice.Elem())
+			return nil
+		}
+		dec := json.NewDecoder(strings.NewReader(trimmed))
+		dec.UseNumber()
+		var values []any
+		if err := dec.Decode(&values); err != nil {
+			return err
+		}
+		for _, value := range values {
+			items = append(items, fmt.Sprint(value))
+		}
+	} else {
+		for item := range strings.SplitSeq(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := decodeValue(slice.Index(i), item); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	v.Set(slice)
+	return nil
+}
+
+// changedFields returns the paths of the bound fields that differ.
+func changedFields(old, updated reflect.Value, prefix string) []string {
+	var fields []string
+	boundFields(old.Type(), func(i int, f reflect.StructField, _ string) {
+		path := f.Name
+		if prefix != "" {
+			path = prefix + "." + f.Name
+		}
+		if nested(f.Type) {
+			if f.Anonymous {
+				path = prefix
+			}
+			fields = append(fields, changedFields(old.Field(i), updated.Field(i), path)...)
+		} else if !reflect.DeepEqual(old.Field(i).Interface(), updated.Field(i).Interface()) {
+			fields = append(fields, path)
+		}
+	})
+	return fields
+}
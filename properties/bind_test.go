@@ -0,0 +1,161 @@
+package properties
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/timeinterval"
+)
+
+type testDB struct {
+	Host string `property:"host"`
+	Port int    `property:"port"`
+}
+
+type testCommon struct {
+	Debug bool
+}
+
+type testConfig struct {
+	testCommon
+	Timeout  duration.Duration          `property:"timeout"`
+	Retry    time.Duration              `property:"retry"`
+	Hosts    []string                   `property:"hosts"`
+	Ports    []int                      `property:"ports"`
+	Schedule timeinterval.TimeIntervals `property:"schedule"`
+	Ratio    float64
+	DB       testDB `property:"db"`
+	Ignored  string `property:"-"`
+}
+
+func TestBind(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.yaml", `
+app:
+  debug: true
+  timeout: 1d
+  hosts: [a, b]
+  ports: 80, 443
+  schedule: '[{"weekdays":["monday:friday"]}]'
+  ratio: 0.5
+  db:
+    host: db.local
+  ignored: x
+`)
+	p := &Properties{}
+	if err := p.AddSource(FileSource{Path: file}, PrecedenceFile); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig{Retry: time.Second, DB: testDB{Port: 5432}}
+	if _, err := BindProperties(p, &cfg, "app"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !cfg.Debug || cfg.Timeout != duration.Duration(24*time.Hour) || cfg.Retry != time.Second || cfg.Ratio != 0.5 {
+		t.Errorf("scalars: %+v", cfg)
+	}
+	if strings.Join(cfg.Hosts, ",") != "a,b" || len(cfg.Ports) != 2 || cfg.Ports[1] != 443 {
+		t.Errorf("slices: %v %v", cfg.Hosts, cfg.Ports)
+	}
+	if len(cfg.Schedule) != 1 || len(cfg.Schedule[0].Weekdays) != 1 {
+		t.Errorf("schedule: %+v", cfg.Schedule)
+	}
+	if cfg.DB != (testDB{Host: "db.local", Port: 5432}) || cfg.Ignored != "" {
+		t.Errorf("nested: %+v, ignored: %q", cfg.DB, cfg.Ignored)
+	}
+}
+
+func TestBind_InvalidValue(t *testing.T) {
+	p := &Properties{}
+	p.Set("app.db.port", "x")
+	var cfg testConfig
+	_, err := BindProperties(p, &cfg, "app")
+	if err == nil || !strings.Contains(err.Error(), `invalid value "x" for app.db.port`) {
+		t.Errorf("expected an error, got %v", err)
+	}
+	if _, err := BindProperties(p, new(string), "app"); err == nil {
+		t.Error("expected an error binding a string")
+	}
+}
+
+func TestBind_Reload(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.properties", "app.db.host=a\napp.retry=1s\n")
+	p := &Properties{}
+	if err := p.AddSource(FileSource{Path: file}, PrecedenceFile); err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig{DB: testDB{Port: 5432}}
+	binding, err := BindProperties(p, &cfg, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []Change[testConfig]
+	binding.OnChange(func(c Change[testConfig]) { changes = append(changes, c) })
+
+	writeFile(t, dir, "app.properties", "app.db.host=b\napp.debug=true\n")
+	if err := p.ReloadSources(); err != nil {
+		t.Fatal(err)
+	}
+	if got := binding.Load(); got.DB.Host != "b" || !got.Debug || got.Retry != 0 || got.DB.Port != 5432 {
+		t.Errorf("after reload: %+v", got)
+	}
+	if cfg.DB.Host != "a" {
+		t.Errorf("the bound struct should only be written when it is bound, got %+v", cfg)
+	}
+	if len(changes) != 1 || strings.Join(changes[0].Fields, ",") != "Debug,Retry,DB.Host" {
+		t.Fatalf("changes: %+v", changes)
+	}
+	if c := changes[0]; !c.Changed("DB.Host") || c.Old.DB.Host != "a" || c.New.DB.Host != "b" {
+		t.Errorf("change: %+v", c)
+	}
+
+	// an invalid value keeps the previous struct
+	writeFile(t, dir, "app.properties", "app.db.host=c\napp.retry=soon\n")
+	_ = p.ReloadSources()
+	if got := binding.Load(); got.DB.Host != "b" || len(changes) != 1 {
+		t.Errorf("invalid reload applied: %+v", got)
+	}
+
+	// unrelated and unchanged properties do not notify
+	p.Set("other", "x")
+	binding.Close()
+	p.Set("app.db.host", "d")
+	if len(changes) != 1 || binding.Load().DB.Host != "b" {
+		t.Errorf("unexpected update: %+v", changes)
+	}
+	if len(p.listeners) != 0 {
+		t.Errorf("Close should unregister the listener, %d left", len(p.listeners))
+	}
+}
+
+func TestBind_ConcurrentReads(t *testing.T) {
+	p := &Properties{}
+	var cfg testConfig
+	binding, err := BindProperties(p, &cfg, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer binding.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 100 {
+			p.Set("app.db.port", i)
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			if got := binding.Load().DB.Port; got != 99 {
+				t.Errorf("port = %d after the last update", got)
+			}
+			return
+		default:
+			_ = cfg.DB.Port + binding.Load().DB.Port
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package properties
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is the number of changes History returns when
+// Properties.HistorySize is zero.
+const DefaultHistorySize = 100
+
+// Sources of changes recorded in Diff.Source, in addition to the names of
+// added sources.
+const (
+	// SetChange is a change made with Set.
+	SetChange = "set"
+	// UpdateChange is a change made with Update.
+	UpdateChange = "update"
+	// RegisterChange is a change of defaults or names made by Register.
+	RegisterChange = "register"
+	// ReloadChange is a change made by ReloadSources, or prefixes the name
+	// of the watched file when it is reloaded, e.g. "reload:file:/etc/app.properties".
+	ReloadChange = "reload"
+	// SecretRotation is a change of a secret file, see IsSecret.
+	SecretRotation = "secret"
+)
+
+// SensitiveKey returns true for properties whose values are masked with
+// MaskSecret in diffs. The logger package sets it to the redaction policy.
+var SensitiveKey = func(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"password", "passwd", "secret", "token", "apikey", "api_key", "api.key", "private", "credential"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyChange is the change of a property, see Diff.
+type KeyChange struct {
+	Key string `json:"key"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// Diff lists the properties changed at once, with sensitive values masked.
+// Secret references are listed as they are written, e.g. ${env:DB_PASSWORD},
+// never resolved.
+type Diff struct {
+	Time time.Time `json:"time"`
+	// Source is what made the change: SetChange, UpdateChange,
+	// RegisterChange, ReloadChange, "remove:<source>", or the name of an
+	// added Source, e.g. "file:/etc/app.properties" or "flags".
+	Source   string      `json:"source"`
+	Added    []KeyChange `json:"added,omitempty"`
+	Removed  []KeyChange `json:"removed,omitempty"`
+	Modified []KeyChange `json:"modified,omitempty"`
+}
+
+// Empty returns true if no property changed.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Changed returns the change of key, if it changed.
+func (d Diff) Changed(key string) (KeyChange, bool) {
+	for _, changes := range [][]KeyChange{d.Added, d.Removed, d.Modified} {
+		for _, c := range changes {
+			if c.Key == key {
+				return c, true
+			}
+		}
+	}
+	return KeyChange{}, false
+}
+
+// only returns the changes of key.
+func (d Diff) only(key string) Diff {
+	filter := func(changes []KeyChange) []KeyChange {
+		return slices.DeleteFunc(slices.Clone(changes), func(c KeyChange) bool { return c.Key != key })
+	}
+	d.Added, d.Removed, d.Modified = filter(d.Added), filter(d.Removed), filter(d.Modified)
+	return d
+}
+
+type changeHistory struct {
+	lock      sync.Mutex
+	last      map[string]string
+	diffs     []Diff
+	listeners []func(Diff)
+}
+
+func (h *changeHistory) diffListeners() []func(Diff) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return slices.Clone(h.listeners)
+}
+
+// RegisterDiffListener registers fn to be called with the properties that
+// changed, after the listeners registered with RegisterListener.
+func (p *Properties) RegisterDiffListener(fn func(Diff)) {
+	p.history.lock.Lock()
+	defer p.history.lock.Unlock()
+	p.history.listeners = append(p.history.listeners, fn)
+}
+
+// History returns the recorded changes, oldest first.
+func (p *Properties) History() []Diff {
+	p.history.lock.Lock()
+	history := slices.Clone(p.history.diffs)
+	p.history.lock.Unlock()
+	for i := range history {
+		history[i] = history[i].masked()
+	}
+	return history
+}
+
+// recordChanges compares the properties with the last ones recorded, and
+// records the difference as made by source. p.lock must be held, so the
+// difference is exactly the change made under it. Values are recorded as
+// they are, see Diff.masked.
+func (p *Properties) recordChanges(source string) (Diff, bool) {
+	current := make(map[string]string, len(p.m)+len(commandlineProperties))
+	maps.Copy(current, p.m)
+	for k, v := range commandlineProperties {
+		if name, ok := p.aliases[k]; ok {
+			k = name
+		}
+		current[k] = v
+	}
+	h := &p.history
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	diff := Diff{Time: time.Now(), Source: source}
+	for _, k := range sortedKeys(current) {
+		old, ok := h.last[k]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, KeyChange{Key: k, New: current[k]})
+		case old != current[k]:
+			diff.Modified = append(diff.Modified, KeyChange{Key: k, Old: old, New: current[k]})
+		}
+	}
+	for _, k := range sortedKeys(h.last) {
+		if _, ok := current[k]; !ok {
+			diff.Removed = append(diff.Removed, KeyChange{Key: k, Old: h.last[k]})
+		}
+	}
+	h.last = current
+	if diff.Empty() {
+		return diff, false
+	}
+
+	size := p.HistorySize
+	if size == 0 {
+		size = DefaultHistorySize
+	}
+	if size > 0 {
+		h.diffs = append(h.diffs, diff)
+		if len(h.diffs) > size {
+			h.diffs = slices.Delete(h.diffs, 0, len(h.diffs)-size)
+		}
+	}
+	return diff, true
+}
+
+// masked returns a copy of d with sensitive values masked. It is applied when
+// diffs are handed out rather than recorded, as SensitiveKey may read
+// properties, e.g. the logger's redaction policy.
+func (d Diff) masked() Diff {
+	mask := func(changes []KeyChange) []KeyChange {
+		if changes == nil {
+			return nil
+		}
+		out := make([]KeyChange, len(changes))
+		for i, c := range changes {
+			out[i] = maskChange(c)
+		}
+		return out
+	}
+	d.Added, d.Removed, d.Modified = mask(d.Added), mask(d.Removed), mask(d.Modified)
+	return d
+}
+
+func maskChange(c KeyChange) KeyChange {
+	if !SensitiveKey(c.Key) {
+		return c
+	}
+	for _, v := range []*string{&c.Old, &c.New} {
+		if *v != "" && !secretRef.MatchString(*v) {
+			*v = MaskSecret(*v)
+		}
+	}
+	return c
+}
+
+// HistoryHandler returns a handler listing the recorded changes as JSON,
+// newest first, for debug endpoints. ?key= limits the list to the changes of
+// a property. The handler does no authentication: mount it behind whatever
+// protects the service's other admin endpoints.
+func (p *Properties) HistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		history := p.History()
+		slices.Reverse(history)
+		if key := r.URL.Query().Get("key"); key != "" {
+			history = slices.DeleteFunc(history, func(d Diff) bool {
+				_, ok := d.Changed(key)
+				return !ok
+			})
+			for i := range history {
+				history[i] = history[i].only(key)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(history)
+	})
+}
+
+// RegisterDiffListener registers a listener of global property changes, see
+// Properties.RegisterDiffListener.
+func RegisterDiffListener(fn func(Diff)) {
+	Global.RegisterDiffListener(fn)
+}
+
+// History returns the recorded changes of global properties, see
+// Properties.History.
+func History() []Diff {
+	return Global.History()
+}
@@ -0,0 +1,118 @@
+package properties
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDiffListener(t *testing.T) {
+	p := &Properties{}
+	var diffs []Diff
+	p.RegisterDiffListener(func(d Diff) { diffs = append(diffs, d) })
+
+	_ = p.AddSource(MapSource{Values: map[string]string{"a": "1", "b": "2", "db.password": "hunter2"}}, PrecedenceDefaults)
+	p.Update(map[string]string{"a": "3", "db.password": "${env:DB_PASSWORD}"})
+	p.Set("a", "3") // unchanged
+	p.RemoveSource("defaults")
+
+	if len(diffs) != 3 {
+		t.Fatalf("%d diffs: %+v", len(diffs), diffs)
+	}
+	added := diffs[0]
+	if added.Source != "defaults" || len(added.Added) != 3 || len(added.Modified)+len(added.Removed) != 0 {
+		t.Errorf("added: %+v", added)
+	}
+	if c, _ := added.Changed("db.password"); c.New == "hunter2" || c.New == "" {
+		t.Errorf("secret not masked: %+v", c)
+	}
+	modified := diffs[1]
+	if modified.Source != UpdateChange || len(modified.Modified) != 2 {
+		t.Errorf("modified: %+v", modified)
+	}
+	if c, _ := modified.Changed("a"); c != (KeyChange{Key: "a", Old: "1", New: "3"}) {
+		t.Errorf("a: %+v", c)
+	}
+	if c, _ := modified.Changed("db.password"); c.Old == "hunter2" || c.New != "${env:DB_PASSWORD}" {
+		t.Errorf("db.password: %+v", c)
+	}
+	removed := diffs[2]
+	if removed.Source != "remove:defaults" || len(removed.Removed) != 1 || removed.Removed[0] != (KeyChange{Key: "b", Old: "2"}) {
+		t.Errorf("removed: %+v", removed)
+	}
+}
+
+func TestDiffListener_ConcurrentChanges(t *testing.T) {
+	p := &Properties{HistorySize: -1}
+	var lock sync.Mutex
+	var diffs []Diff
+	p.RegisterDiffListener(func(d Diff) {
+		lock.Lock()
+		defer lock.Unlock()
+		diffs = append(diffs, d)
+	})
+
+	src := map[string]string{}
+	for i := range 20 {
+		src[fmt.Sprintf("src.%d", i)] = "x"
+	}
+	var wg sync.WaitGroup
+	for i := range 200 {
+		wg.Go(func() { p.Set(fmt.Sprintf("set.%d", i), "x") })
+	}
+	wg.Go(func() { _ = p.AddSource(MapSource{Values: src}, PrecedenceDefaults) })
+	wg.Wait()
+
+	if len(diffs) != 201 {
+		t.Fatalf("expected a diff per change, got %d", len(diffs))
+	}
+	for _, d := range diffs {
+		prefix, want := "set.", 1
+		if d.Source == "defaults" {
+			prefix, want = "src.", len(src)
+		}
+		if len(d.Added) != want || len(d.Modified)+len(d.Removed) != 0 {
+			t.Errorf("%s: %+v", d.Source, d)
+		}
+		for _, c := range d.Added {
+			if !strings.HasPrefix(c.Key, prefix) {
+				t.Errorf("%s changed %s", d.Source, c.Key)
+			}
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	p := &Properties{HistorySize: 2}
+	for _, v := range []string{"1", "2", "3"} {
+		p.Set("a", v)
+	}
+	p.Set("b", "1")
+	history := p.History()
+	if len(history) != 2 || history[0].Modified[0].New != "3" || history[1].Added[0].Key != "b" {
+		t.Fatalf("history: %+v", history)
+	}
+	if history[0].Source != SetChange || history[0].Time.IsZero() {
+		t.Errorf("history: %+v", history[0])
+	}
+
+	rec := httptest.NewRecorder()
+	p.HistoryHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?key=a", nil))
+	var listed []Diff
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].Modified[0].Key != "a" {
+		t.Errorf("listed: %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	p.HistoryHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: %d", rec.Code)
+	}
+}
@@ -0,0 +1,303 @@
+package properties
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
+
+	"github.com/flanksource/commons/hash"
+	"github.com/flanksource/commons/timeinterval"
+)
+
+// FeatureFlagPrefix is the prefix of feature flag properties, see Feature.
+const FeatureFlagPrefix = "feature."
+
+// Reasons of FeatureEvaluation.Reason, as in the OpenTelemetry
+// feature_flag.result.reason attribute.
+const (
+	// FeatureReasonDefault is the result of an undefined flag.
+	FeatureReasonDefault = "default"
+	// FeatureReasonStatic is the result of a flag without targeting rules.
+	FeatureReasonStatic = "static"
+	// FeatureReasonTargetingMatch is the result of a schedule, allow or
+	// deny rule.
+	FeatureReasonTargetingMatch = "targeting_match"
+	// FeatureReasonSplit is the result of a percentage rollout.
+	FeatureReasonSplit = "split"
+	// FeatureReasonDisabled is the result of a flag turned off with enabled: false.
+	FeatureReasonDisabled = "disabled"
+	// FeatureReasonError is the result of an invalid flag definition.
+	FeatureReasonError = "error"
+)
+
+// FeatureFlag is the definition of a feature flag, see Feature.
+type FeatureFlag struct {
+	// Enabled turns the flag off for everyone when false. Default: true.
+	Enabled bool `property:"enabled" yaml:"enabled" json:"enabled"`
+	// Allow enables the flag for attribute values, e.g. tenant=acme, or
+	// values of the By attribute, e.g. alice. When there is no Percentage,
+	// the flag is disabled for everyone else.
+	Allow []string `property:"allow" yaml:"allow" json:"allow,omitempty"`
+	// Deny disables the flag for attribute values, over Allow.
+	Deny []string `property:"deny" yaml:"deny" json:"deny,omitempty"`
+	// Percentage enables the flag for a stable share of the values of the By
+	// attribute, e.g. 25 for 25% of users.
+	Percentage *float64 `property:"percentage" yaml:"percentage" json:"percentage,omitempty"`
+	// By is the attribute percentages and plain Allow and Deny values
+	// apply to. Default: user.
+	By string `property:"by" yaml:"by" json:"by,omitempty"`
+	// Schedule limits the flag to time windows.
+	Schedule timeinterval.TimeIntervals `property:"schedule" yaml:"schedule" json:"schedule,omitempty"`
+	// Intervals limits the flag to the time windows of time_interval.<name>
+	// properties, e.g. business_hours, see TimeIntervals. business_hours
+	// defaults to the BusinessHours default, other intervals must be set.
+	Intervals []string `property:"intervals" yaml:"intervals" json:"intervals,omitempty"`
+}
+
+func (f FeatureFlag) by() string {
+	if f.By == "" {
+		return "user"
+	}
+	return f.By
+}
+
+// FeatureEvaluation is the result of evaluating a feature flag.
+type FeatureEvaluation struct {
+	Flag    string
+	Enabled bool
+	// Reason is one of the FeatureReason constants.
+	Reason string
+	// Rule explains the result, e.g. "deny tenant=acme".
+	Rule  string
+	Error error
+}
+
+type featureAttributesKey struct{}
+
+// WithFeatureAttributes returns a context with attributes feature flags are
+// evaluated against, e.g. tenant and user, added to those already in ctx.
+// The hostname attribute is always set.
+func WithFeatureAttributes(ctx context.Context, attrs map[string]string) context.Context {
+	merged := maps.Clone(FeatureAttributes(ctx))
+	maps.Copy(merged, attrs)
+	return context.WithValue(ctx, featureAttributesKey{}, merged)
+}
+
+// FeatureAttributes returns the attributes of ctx, see WithFeatureAttributes.
+func FeatureAttributes(ctx context.Context) map[string]string {
+	if attrs, ok := ctx.Value(featureAttributesKey{}).(map[string]string); ok {
+		return attrs
+	}
+	return map[string]string{"hostname": hostname()}
+}
+
+var hostname = sync.OnceValue(func() string {
+	name, _ := os.Hostname()
+	return name
+})
+
+// Feature returns the definition of a feature flag, false if it is not
+// defined. A flag is defined by the feature.<name> property, true or false to
+// turn it on or off for everyone, or a YAML or JSON FeatureFlag:
+//
+//	feature.new-ui={"percentage": 25, "by": "tenant", "deny": ["tenant=acme"]}
+//
+// and by properties under it, e.g. from a YAML properties file:
+//
+//	feature:
+//	  new-ui:
+//	    allow: [tenant=acme, alice]
+//	    intervals: [business_hours]
+func (p *Properties) Feature(name string) (FeatureFlag, bool, error) {
+	key := FeatureFlagPrefix + name
+	flag := FeatureFlag{Enabled: true}
+	value := strings.TrimSpace(p.Get(key))
+	switch strings.ToLower(value) {
+	case "":
+	case "true", "false":
+		flag.Enabled = strings.EqualFold(value, "true")
+	default:
+		if err := yaml.Unmarshal([]byte(value), &flag); err != nil {
+			return flag, true, fmt.Errorf("invalid feature flag %s: %w", key, err)
+		}
+	}
+	if value == "" && !p.hasPrefix(key+".") {
+		return flag, false, nil
+	}
+	// properties under the flag override its value
+	if err := decodeStruct(p, reflect.ValueOf(&flag).Elem(), key); err != nil {
+		return flag, true, fmt.Errorf("invalid feature flag %s: %w", key, err)
+	}
+	return flag, true, nil
+}
+
+// FeatureEnabled returns true if a feature flag is on for the attributes of
+// ctx, or def if it is not defined or is invalid. See Feature for flag
+// definitions and EvaluateFeature for the rules.
+func (p *Properties) FeatureEnabled(ctx context.Context, name string, def bool) bool {
+	return p.EvaluateFeature(ctx, name, def).Enabled
+}
+
+// EvaluateFeature evaluates a feature flag for the attributes of ctx, see
+// WithFeatureAttributes. The first matching rule decides:
+//
+//  1. enabled: false turns the flag off
+//  2. outside the Schedule or Intervals, the flag is off
+//  3. a Deny match turns it off, then an Allow match on
+//  4. with a Percentage, the flag is on for the values of the By attribute
+//     whose hash falls in that percentage, so the same tenant or user always
+//     gets the same result, and raising the percentage only adds values
+//  5. with Allow and no Percentage, the flag is off for everyone else
+//  6. otherwise it is on
+//
+// The result is added as a feature_flag.evaluation event to the span of ctx.
+func (p *Properties) EvaluateFeature(ctx context.Context, name string, def bool) FeatureEvaluation {
+	e := FeatureEvaluation{Flag: name}
+	flag, defined, err := p.Feature(name)
+	switch {
+	case err != nil:
+		e.Enabled, e.Reason, e.Error = def, FeatureReasonError, err
+	case !defined:
+		e.Enabled, e.Reason = def, FeatureReasonDefault
+	default:
+		e.Enabled, e.Reason, e.Rule, e.Error = p.evaluateFeature(name, flag, FeatureAttributes(ctx), time.Now())
+		if e.Error != nil {
+			e.Enabled = def
+		}
+	}
+	traceFeature(ctx, e)
+	return e
+}
+
+func (p *Properties) evaluateFeature(name string, flag FeatureFlag, attrs map[string]string, now time.Time) (bool, string, string, error) {
+	if !flag.Enabled {
+		return false, FeatureReasonDisabled, "disabled", nil
+	}
+
+	schedule := flag.Schedule
+	if len(flag.Intervals) > 0 {
+		intervals, err := p.featureIntervals(flag.Intervals)
+		if err != nil {
+			return false, FeatureReasonError, "", fmt.Errorf("invalid feature flag %s: %w", FeatureFlagPrefix+name, err)
+		}
+		schedule = append(slices.Clone(schedule), intervals...)
+	}
+	if (len(flag.Schedule) > 0 || len(flag.Intervals) > 0) && !schedule.ContainsTime(now) {
+		return false, FeatureReasonTargetingMatch, "outside schedule", nil
+	}
+
+	if match, ok := flag.match(flag.Deny, attrs); ok {
+		return false, FeatureReasonTargetingMatch, "deny " + match, nil
+	}
+	if match, ok := flag.match(flag.Allow, attrs); ok {
+		return true, FeatureReasonTargetingMatch, "allow " + match, nil
+	}
+
+	if flag.Percentage != nil {
+		rule := fmt.Sprintf("rollout %s%% by %s", strconv.FormatFloat(*flag.Percentage, 'f', -1, 64), flag.by())
+		value := attrs[flag.by()]
+		if value == "" {
+			return false, FeatureReasonSplit, rule + ", no " + flag.by() + " attribute", nil
+		}
+		return rolloutBucket(name, value) < *flag.Percentage, FeatureReasonSplit, rule, nil
+	}
+	if len(flag.Allow) > 0 {
+		return false, FeatureReasonTargetingMatch, "not allowed", nil
+	}
+	return true, FeatureReasonStatic, "", nil
+}
+
+// featureIntervals returns the time windows of the time_interval.<name>
+// properties of a flag's Intervals. business_hours defaults to 9am to 5pm,
+// Monday to Friday, like BusinessHours, other intervals must be set.
+func (p *Properties) featureIntervals(names []string) (timeinterval.TimeIntervals, error) {
+	var schedule timeinterval.TimeIntervals
+	for _, name := range names {
+		if p.Get("time_interval."+name) == "" {
+			if name != businessHoursKey {
+				return nil, fmt.Errorf("time interval %s is not defined, set time_interval.%s", name, name)
+			}
+			schedule = append(schedule, defaultBusinessHours()...)
+			continue
+		}
+		intervals, err := p.TimeIntervals(name)
+		if err != nil {
+			return nil, err
+		}
+		schedule = append(schedule, intervals...)
+	}
+	return schedule, nil
+}
+
+// match returns the first rule matching attrs: attribute=value, or a value
+// of the By attribute.
+func (f FeatureFlag) match(rules []string, attrs map[string]string) (string, bool) {
+	for _, rule := range rules {
+		attr, value, ok := strings.Cut(rule, "=")
+		if !ok {
+			attr, value = f.by(), rule
+		}
+		attr, value = strings.TrimSpace(attr), strings.TrimSpace(value)
+		if v, ok := attrs[attr]; ok && v == value {
+			return attr + "=" + value, true
+		}
+	}
+	return "", false
+}
+
+// rolloutBucket maps a flag and attribute value to a stable number in
+// [0, 100), with a resolution of 0.01%.
+func rolloutBucket(flag, value string) float64 {
+	sum := hash.Sha256Hex(flag + "/" + value)
+	n, _ := strconv.ParseUint(sum[:8], 16, 32)
+	return float64(n%10000) / 100
+}
+
+func traceFeature(ctx context.Context, e FeatureEvaluation) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	variant := "off"
+	if e.Enabled {
+		variant = "on"
+	}
+	attrs := []attribute.KeyValue{
+		semconv.FeatureFlagKey(e.Flag),
+		semconv.FeatureFlagProviderName("properties"),
+		semconv.FeatureFlagResultVariant(variant),
+		semconv.FeatureFlagResultValueKey.Bool(e.Enabled),
+		semconv.FeatureFlagResultReasonKey.String(e.Reason),
+	}
+	if e.Rule != "" {
+		attrs = append(attrs, attribute.String("feature_flag.rule", e.Rule))
+	}
+	if e.Error != nil {
+		attrs = append(attrs, semconv.ErrorMessageKey.String(e.Error.Error()))
+	}
+	span.AddEvent("feature_flag.evaluation", trace.WithAttributes(attrs...))
+}
+
+// FeatureEnabled returns true if a global feature flag is on, see
+// Properties.FeatureEnabled.
+func FeatureEnabled(ctx context.Context, name string, def bool) bool {
+	return Global.FeatureEnabled(ctx, name, def)
+}
+
+// EvaluateFeature evaluates a global feature flag, see
+// Properties.EvaluateFeature.
+func EvaluateFeature(ctx context.Context, name string, def bool) FeatureEvaluation {
+	return Global.EvaluateFeature(ctx, name, def)
+}
@@ -0,0 +1,174 @@
+package properties
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func featureContext(attrs map[string]string) context.Context {
+	return WithFeatureAttributes(context.Background(), attrs)
+}
+
+func TestFeature_Rules(t *testing.T) {
+	p := &Properties{}
+	p.Update(map[string]string{
+		"feature.on":             "true",
+		"feature.off":            "FALSE",
+		"feature.tenants":        `{"allow": ["tenant=acme", "alice"], "deny": ["tenant=evil"]}`,
+		"feature.rollout":        "percentage: 50\nby: tenant\n",
+		"feature.rollout.deny":   "tenant=t1",
+		"feature.nested.enabled": "false",
+		"feature.invalid":        "yes",
+		"feature.invalid3.by":    "tenant",
+		"feature.invalid3.allow": "a",
+	})
+	for _, tc := range []struct {
+		flag   string
+		attrs  map[string]string
+		def    bool
+		on     bool
+		reason string
+		rule   string
+	}{
+		{"undefined", nil, true, true, FeatureReasonDefault, ""},
+		{"on", nil, false, true, FeatureReasonStatic, ""},
+		{"off", nil, true, false, FeatureReasonDisabled, "disabled"},
+		{"nested", nil, true, false, FeatureReasonDisabled, "disabled"},
+		{"tenants", map[string]string{"tenant": "acme"}, false, true, FeatureReasonTargetingMatch, "allow tenant=acme"},
+		{"tenants", map[string]string{"tenant": "evil", "user": "alice"}, true, false, FeatureReasonTargetingMatch, "deny tenant=evil"},
+		{"tenants", map[string]string{"tenant": "x", "user": "alice"}, false, true, FeatureReasonTargetingMatch, "allow user=alice"},
+		{"tenants", map[string]string{"tenant": "x"}, true, false, FeatureReasonTargetingMatch, "not allowed"},
+		{"rollout", map[string]string{"tenant": "t1"}, true, false, FeatureReasonTargetingMatch, "deny tenant=t1"},
+		{"rollout", nil, true, false, FeatureReasonSplit, "rollout 50% by tenant, no tenant attribute"},
+		{"invalid", nil, true, true, FeatureReasonError, ""},
+		{"invalid3", map[string]string{"tenant": "a"}, false, true, FeatureReasonTargetingMatch, "allow tenant=a"},
+	} {
+		e := p.EvaluateFeature(featureContext(tc.attrs), tc.flag, tc.def)
+		if e.Enabled != tc.on || e.Reason != tc.reason || e.Rule != tc.rule {
+			t.Errorf("%s %v = %+v, expected %v %s %q", tc.flag, tc.attrs, e, tc.on, tc.reason, tc.rule)
+		}
+	}
+}
+
+func TestFeature_RolloutIsStable(t *testing.T) {
+	p := &Properties{}
+	enabled := func(percentage string) map[string]bool {
+		p.Set("feature.rollout.percentage", percentage)
+		on := map[string]bool{}
+		for i := range 1000 {
+			tenant := "tenant-" + string(rune('a'+i%26)) + strings.Repeat("x", i/26)
+			if p.FeatureEnabled(featureContext(map[string]string{"user": tenant}), "rollout", false) {
+				on[tenant] = true
+			}
+		}
+		return on
+	}
+	ten, thirty := enabled("10"), enabled("30")
+	if len(ten) < 50 || len(ten) > 150 || len(thirty) < 230 || len(thirty) > 370 {
+		t.Errorf("10%% enabled %d, 30%% enabled %d of 1000", len(ten), len(thirty))
+	}
+	for tenant := range ten {
+		if !thirty[tenant] {
+			t.Errorf("%s enabled at 10%% but not 30%%", tenant)
+		}
+	}
+	if again := enabled("10"); len(again) != len(ten) {
+		t.Errorf("rollout not stable: %d then %d", len(ten), len(again))
+	}
+}
+
+func TestFeature_Schedule(t *testing.T) {
+	p := &Properties{}
+	weekday := strings.ToLower(time.Now().Weekday().String())
+	p.Update(map[string]string{
+		"time_interval.today": `[{"weekdays":["` + weekday + `"]}]`,
+		"feature.today":       `{"intervals": ["today"]}`,
+		"feature.never":       "schedule:\n  - years: ['1999']\n",
+	})
+	ctx := context.Background()
+	if e := p.EvaluateFeature(ctx, "today", false); !e.Enabled || e.Reason != FeatureReasonStatic {
+		t.Errorf("today = %+v", e)
+	}
+	if e := p.EvaluateFeature(ctx, "never", true); e.Enabled || e.Rule != "outside schedule" {
+		t.Errorf("never = %+v", e)
+	}
+}
+
+func TestFeature_Intervals(t *testing.T) {
+	p := &Properties{}
+	p.Update(map[string]string{
+		"feature.office.intervals":  `["business_hours"]`,
+		"feature.weekend.intervals": "weekend",
+	})
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	sunday := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	evaluate := func(name string, now time.Time) (bool, string, error) {
+		t.Helper()
+		flag, _, err := p.Feature(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enabled, _, rule, err := p.evaluateFeature(name, flag, nil, now)
+		return enabled, rule, err
+	}
+
+	// business_hours defaults to 9am to 5pm, Monday to Friday
+	if on, rule, err := evaluate("office", monday); !on || err != nil {
+		t.Errorf("monday: %v %s %v", on, rule, err)
+	}
+	if on, rule, err := evaluate("office", sunday); on || rule != "outside schedule" || err != nil {
+		t.Errorf("sunday: %v %s %v", on, rule, err)
+	}
+	if _, _, err := evaluate("weekend", sunday); err == nil || !strings.Contains(err.Error(), "time_interval.weekend") {
+		t.Errorf("expected an error for an undefined interval, got %v", err)
+	}
+	if e := p.EvaluateFeature(context.Background(), "weekend", true); !e.Enabled || e.Reason != FeatureReasonError {
+		t.Errorf("weekend = %+v", e)
+	}
+
+	p.Update(map[string]string{
+		"time_interval.business_hours": `[{"weekdays":["sunday"]}]`,
+		"time_interval.weekend":        `[{"weekdays":["saturday", "sunday"]}]`,
+	})
+	if on, _, _ := evaluate("office", sunday); !on {
+		t.Error("expected time_interval.business_hours to replace the default")
+	}
+	if on, _, err := evaluate("weekend", sunday); !on || err != nil {
+		t.Errorf("weekend: %v %v", on, err)
+	}
+}
+
+func TestFeature_Trace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, span := provider.Tracer("test").Start(featureContext(map[string]string{"tenant": "acme"}), "test")
+
+	p := &Properties{}
+	p.Set("feature.beta.allow", "tenant=acme")
+	p.FeatureEnabled(ctx, "beta", false)
+	span.End()
+
+	events := exporter.GetSpans()[0].Events
+	if len(events) != 1 || events[0].Name != "feature_flag.evaluation" {
+		t.Fatalf("events: %+v", events)
+	}
+	attrs := map[string]string{}
+	for _, kv := range events[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	for k, v := range map[string]string{
+		"feature_flag.key":           "beta",
+		"feature_flag.result.value":  "true",
+		"feature_flag.result.reason": "targeting_match",
+		"feature_flag.rule":          "allow tenant=acme",
+	} {
+		if attrs[k] != v {
+			t.Errorf("%s = %q, expected %q", k, attrs[k], v)
+		}
+	}
+}
@@ -6,12 +6,22 @@
 // and dynamic updates with file watching.
 //
 // Key features:
-//   - Load properties from files (key=value format)
+//   - Load properties from files (key=value, YAML or JSON format)
+//   - Layered sources: defaults, files, environment variables, flags and
+//     custom sources such as a remote endpoint, see AddSource
 //   - Command-line property overrides via -P flag
 //   - File watching with automatic hot-reloading
 //   - Thread-safe operations with read-write locks
-//   - Change listeners for reactive configuration
+//   - Change listeners for reactive configuration, with diffs of the changed
+//     properties and a change history, see RegisterDiffListener and History
 //   - Type-safe accessors for common data types
+//   - Typed property definitions with validation and generated docs, see
+//     Register
+//   - Structs bound to properties and updated on reload, see Bind
+//   - Feature flags with allow and deny lists, percentage rollouts and
+//     schedules, see FeatureEnabled
+//   - Secret references resolved from the environment, files and commands,
+//     e.g. db.password=${file:/var/run/secrets/db/password}, see IsSecret
 //
 // Basic usage:
 //
@@ -47,18 +57,36 @@
 //	debug.enabled=true
 //	request.timeout=30s
 //
+// Layered sources, the value from the source with the highest Precedence wins:
+//
+//	properties.AddSource(properties.MapSource{Values: defaults}, properties.PrecedenceDefaults)
+//	properties.AddSource(properties.FileSource{Path: "/etc/app/config.yaml"}, properties.PrecedenceFile)
+//	properties.AddSource(properties.EnvSource{Prefix: "APP_"}, properties.PrecedenceEnv) // APP_LOG_LEVEL -> log.level
+//	properties.Origin("log.level") // "env:APP_"
+//
+// Registered properties are validated when set or loaded, and documented by
+// Markdown and Usage:
+//
+//	properties.Register(properties.Definition{
+//		Name: "server.port", Type: properties.TypeInt, Default: "8080",
+//		Min: "1", Max: "65535", Description: "Port to listen on",
+//		Deprecated: []string{"port"},
+//	})
+//	err := properties.SetE("server.port", "http") // invalid value "http" for server.port: expected an integer
+//
 // The package maintains a global instance for convenience, but you can also
 // create isolated Properties instances for different configuration contexts.
 package properties
 
 import (
-	"bufio"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -67,6 +95,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/pflag"
 
+	"github.com/flanksource/commons/duration"
 	"github.com/flanksource/commons/timeinterval"
 )
 
@@ -102,112 +131,258 @@ func BindFlags(flags *pflag.FlagSet) {
 // Properties represents a thread-safe key-value store for application configuration.
 // It supports loading from files, dynamic updates, file watching, and change notifications.
 type Properties struct {
-	m         map[string]string   // The property map
-	filename  string              // Currently loaded file
-	listeners []func(*Properties) // Change listeners
-	lock      sync.RWMutex        // Protects concurrent access
-	close     func()              // Cleanup function for file watcher
-	Reload    func()              // Function to manually trigger reload
+	m         map[string]string     // The property map, resolved from layers and runtime
+	origins   map[string]string     // Source name of each value in m
+	layers    []*layer              // Sources, lowest precedence first
+	runtime   map[string]string     // Values set with Set and Update
+	schema    map[string]Definition // Registered properties
+	aliases   map[string]string     // Deprecated names of registered properties
+	filename  string                // Currently loaded file
+	listeners []*func(*Properties)  // Change listeners, by pointer so they can be removed
+	lock      sync.RWMutex          // Protects concurrent access
+	close     func()                // Cleanup function for file watcher
+	watcher   *fsnotify.Watcher     // Watches the file and secret file directories
+	watchLock sync.Mutex            // Protects filename and the watch state below
+	reloading *time.Timer           // Debounces reloads of the file
+	fileHash  [sha256.Size]byte     // Content of the file last loaded
+	reloadErr error                 // Error of the last reload
+	secrets   secretCache           // Resolved secret references
+	history   changeHistory         // Diff listeners and recorded changes
+	Reload    func()                // Function to manually trigger reload, set by Watch
+	// WatchDebounce is how long Watch waits for changes to settle before
+	// reloading the file. Default: DefaultWatchDebounce.
+	WatchDebounce time.Duration
+	// SecretTTL is how long resolved secrets are cached, see Get. Default:
+	// DefaultSecretTTL, negative to resolve them on every Get.
+	SecretTTL time.Duration
+	// HistorySize is the number of changes recorded, see History. Default:
+	// DefaultHistorySize, negative to record none.
+	HistorySize int
+	// AllowExec enables ${exec:...} secret references, which run a shell
+	// command. Even then they are only run in values from -P command line
+	// properties, a FileSource or a MapSource, so that values from the
+	// environment, remote sources, mounted directories or Set cannot run
+	// commands.
+	AllowExec bool
 }
 
 func (p *Properties) RegisterListener(fn func(*Properties)) {
-	p.listeners = append(p.listeners, fn)
+	p.addListener(fn)
 }
 
-func (p *Properties) Set(key string, value any) {
+// addListener registers fn like RegisterListener, returning a function that
+// removes it.
+func (p *Properties) addListener(fn func(*Properties)) (remove func()) {
+	listener := &fn
 	p.lock.Lock()
-	defer p.notify()
-	defer p.lock.Unlock()
-	p.m[key] = fmt.Sprintf("%v", value)
+	p.listeners = append(p.listeners, listener)
+	p.lock.Unlock()
+	return func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		p.listeners = slices.DeleteFunc(slices.Clone(p.listeners), func(l *func(*Properties)) bool { return l == listener })
+	}
 }
 
-func (p *Properties) notify() {
-	for _, listener := range p.listeners {
-		listener(p)
+// Set sets a property, overriding every source but -P command line
+// properties, and notifies listeners. Setting "" removes the override, so the
+// value of the sources applies again, including when they are reloaded. An
+// invalid value for a registered property is logged and ignored, use SetE to
+// handle the error.
+func (p *Properties) Set(key string, value any) {
+	if err := p.SetE(key, value); err != nil {
+		slog.Warn(fmt.Sprintf("failed to set property %s: %v", key, err))
 	}
 }
 
+// SetE sets a property like Set, returning an error, and leaving the property
+// unchanged, if the value is invalid for a registered property.
+func (p *Properties) SetE(key string, value any) error {
+	return p.update(map[string]string{key: fmt.Sprintf("%v", value)}, SetChange)
+}
+
+// changed records the changes made by source and notifies listeners, for
+// callers that do not hold p.lock.
+func (p *Properties) changed(source string) {
+	p.lock.RLock()
+	diff, changed := p.recordChanges(source)
+	p.lock.RUnlock()
+	p.notify(diff, changed)
+}
+
+// notify notifies listeners of a change recorded by recordChanges.
+func (p *Properties) notify(diff Diff, changed bool) {
+	p.lock.RLock()
+	listeners := p.listeners
+	p.lock.RUnlock()
+	for _, listener := range listeners {
+		(*listener)(p)
+	}
+	if changed {
+		diff = diff.masked()
+		for _, listener := range p.history.diffListeners() {
+			listener(diff)
+		}
+	}
+}
+
+// GetAll returns a copy of every property that is set. Secret references are
+// not resolved, so the copy can be logged. Unknown returns the keys of the
+// copy that are not registered, and List every property with
+// Property.Unknown marking them.
 func (p *Properties) GetAll() map[string]string {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
-	m := p.m
+	m := make(map[string]string, len(p.m)+len(commandlineProperties))
+	for k, v := range p.m {
+		m[k] = v
+	}
 	//command line properties take priority
 	for k, v := range commandlineProperties {
-		m[k] = v
+		m[p.canonical(k)] = v
 	}
 	return m
 }
 
+// hasPrefix returns true if a property under prefix is set.
+func (p *Properties) hasPrefix(prefix string) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	for k := range p.m {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	for k := range commandlineProperties {
+		if strings.HasPrefix(p.canonical(k), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the value of a property, or the default of a registered
+// property that is not set. Secret references in the value, see IsSecret,
+// are resolved, and replaced with "" if they cannot be.
 func (p *Properties) Get(key string) string {
-	//command line properties take priority
-	if v, ok := commandlineProperties[key]; ok {
+	v, trusted := p.rawValue(key)
+	if !secretRef.MatchString(v) {
 		return v
 	}
+	resolved, err := p.resolveSecrets(v, p.AllowExec && trusted)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to resolve secret property %s: %v", key, err))
+	}
+	return resolved
+}
 
+// raw returns the value of a property without resolving secret references.
+func (p *Properties) raw(key string) string {
+	v, _ := p.rawValue(key)
+	return v
+}
+
+// rawValue returns the value of a property without resolving secret
+// references, and whether it comes from a source trusted to run commands, see
+// AllowExec.
+func (p *Properties) rawValue(key string) (string, bool) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
-	return p.m[key]
+	key = p.canonical(key)
+	//command line properties take priority
+	if v, ok := p.commandLine(key); ok {
+		return v, true
+	}
+	if v := p.m[key]; v != "" {
+		return v, p.trusted(p.origins[key])
+	}
+	return p.schema[key].Default, true
 }
 
+// Update sets properties like Set, notifying listeners once. Either every
+// property is set or, if a value is invalid, none is and the error is logged,
+// use UpdateE to handle it.
 func (p *Properties) Update(props map[string]string) {
-	defer p.notify()
+	if err := p.UpdateE(props); err != nil {
+		slog.Warn(fmt.Sprintf("failed to update properties: %v", err))
+	}
+}
 
-	p.lock.Lock()
-	defer p.lock.Unlock()
+// UpdateE sets properties like Update, returning an error if a value is
+// invalid.
+func (p *Properties) UpdateE(props map[string]string) error {
+	return p.update(props, UpdateChange)
+}
 
+func (p *Properties) update(props map[string]string, source string) error {
+	p.lock.Lock()
+	if err := p.validate(props); err != nil {
+		p.lock.Unlock()
+		return err
+	}
+	if p.runtime == nil {
+		p.runtime = make(map[string]string)
+	}
+	if p.m == nil {
+		p.m = make(map[string]string)
+	}
+	if p.origins == nil {
+		p.origins = make(map[string]string)
+	}
+	unset := false
 	for k, v := range props {
-		p.m[k] = v
+		k = p.canonical(k)
+		if v == "" {
+			delete(p.runtime, k)
+			unset = true
+			continue
+		}
+		// runtime values override every source, so resolving is not needed
+		p.runtime[k] = v
+		p.m[k], p.origins[k] = v, RuntimeSource
+	}
+	if unset {
+		p.resolve()
 	}
+	diff, changed := p.recordChanges(source)
+	p.lock.Unlock()
+	p.notify(diff, changed)
+	return nil
 }
 
+// LoadFile adds filename as a FileSource, replacing it if it was loaded
+// before, and watches it for changes. A missing file is ignored.
 func (p *Properties) LoadFile(filename string) error {
 	if !path.IsAbs(filename) {
 		cwd, _ := os.Getwd()
 		filename = path.Join(cwd, filename)
 	}
-	file, err := os.Open(filename)
-	if errors.Is(err, os.ErrNotExist) {
+	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
 		slog.Warn(fmt.Sprintf("%s does not exist", filename))
-		p.Update(commandlineProperties)
+		p.changed(FileSource{Path: filename}.Name())
 		return nil
 	} else if err != nil {
 		return err
 	}
-	defer file.Close()
+	p.watchLock.Lock()
 	p.filename = filename
+	p.watchLock.Unlock()
 
 	if p.close == nil {
 		p.close = p.Watch()
+	} else if err := p.watchPath(path.Dir(filename)); err != nil {
+		slog.Warn(fmt.Sprintf("failed to watch %s: %v", filename, err))
 	}
 
 	slog.Info(fmt.Sprintf("Loading properties from %s", filename))
-	var props = make(map[string]string)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		tokens := strings.SplitN(line, "=", 2)
-		if len(tokens) != 2 {
-			return fmt.Errorf("invalid line: %s", line)
-		}
-
-		key := strings.TrimSpace(tokens[0])
-		value := strings.TrimSpace(tokens[1])
-		props[key] = value
+	if err := p.AddSource(FileSource{Path: filename}, PrecedenceFile); err != nil {
+		return err
 	}
-
-	if scanner.Err() != nil {
-		return scanner.Err()
+	if data, err := os.ReadFile(filename); err == nil {
+		p.watchLock.Lock()
+		p.fileHash = sha256.Sum256(data)
+		p.watchLock.Unlock()
 	}
-
-	for k, v := range commandlineProperties {
-		props[k] = v
-	}
-	p.Update(props)
-
 	return nil
 }
 
@@ -219,6 +394,11 @@ func Set(key string, value any) {
 	Global.Set(key, value)
 }
 
+// SetE sets a global property, see Properties.SetE.
+func SetE(key string, value any) error {
+	return Global.SetE(key, value)
+}
+
 func Get(key string) string {
 	return Global.Get(key)
 }
@@ -227,6 +407,21 @@ func Update(props map[string]string) {
 	Global.Update(props)
 }
 
+// UpdateE sets global properties, see Properties.UpdateE.
+func UpdateE(props map[string]string) error {
+	return Global.UpdateE(props)
+}
+
+// AddSource adds a source to the global properties, see Properties.AddSource.
+func AddSource(src Source, precedence Precedence) error {
+	return Global.AddSource(src, precedence)
+}
+
+// Origin returns the source of a global property, see Properties.Origin.
+func Origin(key string) string {
+	return Global.Origin(key)
+}
+
 func On(def bool, keys ...string) bool {
 	return Global.On(def, keys...)
 }
@@ -250,25 +445,31 @@ func BusinessHours() (timeinterval.TimeIntervals, error) {
 	}
 
 	if len(hours) == 0 {
-		return []timeinterval.TimeInterval{{
-			Times: []timeinterval.TimeRange{
-				{
-					StartMinute: 540,  // 9am
-					EndMinute:   1020, // 5pm
-				},
-			},
-			Weekdays: []timeinterval.WeekdayRange{
-				{InclusiveRange: timeinterval.InclusiveRange{
-					Begin: 1, // Monday
-					End:   5, // Friday
-				}},
-			},
-		}}, nil
+		return defaultBusinessHours(), nil
 	}
 
 	return hours, nil
 }
 
+// defaultBusinessHours are the business hours when time_interval.business_hours
+// is not set.
+func defaultBusinessHours() timeinterval.TimeIntervals {
+	return []timeinterval.TimeInterval{{
+		Times: []timeinterval.TimeRange{
+			{
+				StartMinute: 540,  // 9am
+				EndMinute:   1020, // 5pm
+			},
+		},
+		Weekdays: []timeinterval.WeekdayRange{
+			{InclusiveRange: timeinterval.InclusiveRange{
+				Begin: 1, // Monday
+				End:   5, // Friday
+			}},
+		},
+	}}
+}
+
 func TimeIntervals(keys ...string) (timeinterval.TimeIntervals, error) {
 	return Global.TimeIntervals(keys...)
 }
@@ -294,8 +495,8 @@ func (p *Properties) String(def string, keys ...string) string {
 func (p *Properties) Duration(def time.Duration, keys ...string) time.Duration {
 	for _, key := range keys {
 		if v := p.Get(key); v != "" {
-			if d, err := time.ParseDuration(v); err == nil {
-				return d
+			if d, err := duration.ParseDuration(v); err == nil {
+				return time.Duration(d)
 			}
 			//FIXME: return the failed parsing up the stack
 		}
@@ -339,32 +540,3 @@ func (p *Properties) TimeIntervals(keys ...string) (timeinterval.TimeIntervals,
 
 	return output, nil
 }
-
-func (p *Properties) Watch() func() {
-	if p.close != nil {
-		return p.close
-	}
-	slog.Info(fmt.Sprintf("Watching %s for changes", p.filename))
-	// Create new watcher.
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		slog.Warn("Failed to create watcher for properties file: " + err.Error())
-	}
-
-	_ = watcher.Add(path.Dir(p.filename))
-
-	go func() {
-
-		for e := range watcher.Events {
-			if e.Name == p.filename && e.Op != fsnotify.Chmod {
-				if err := p.LoadFile(p.filename); err != nil {
-					fmt.Printf("Error reloading %s: %s\n", p.filename, err)
-				}
-
-			}
-		}
-	}()
-	return func() {
-		_ = watcher.Close()
-	}
-}
@@ -0,0 +1,399 @@
+package properties
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/timeinterval"
+)
+
+// Type is the type of a property value, see Definition.
+type Type string
+
+const (
+	TypeString Type = "string"
+	TypeInt    Type = "int"
+	TypeFloat  Type = "float"
+	// TypeBool accepts true and false, in any case, as On does.
+	TypeBool Type = "bool"
+	// TypeDuration accepts durations such as 30s or 1d, see duration.ParseDuration.
+	TypeDuration Type = "duration"
+	// TypeTimeIntervals accepts a JSON array of time intervals, see TimeIntervals.
+	TypeTimeIntervals Type = "time_intervals"
+)
+
+// Definition declares a property, see Register.
+type Definition struct {
+	Name string
+	// Type defaults to TypeString.
+	Type Type
+	// Default is returned by Get when the property is not set.
+	Default string
+	// Min and Max are the inclusive bounds of int, float and duration
+	// properties, e.g. "1s", empty for no bound.
+	Min, Max string
+	// Enum lists the allowed values, empty to allow any.
+	Enum        []string
+	Description string
+	// Deprecated are former names of the property: values set under them
+	// apply to Name, with a warning.
+	Deprecated []string
+}
+
+func (d Definition) typ() Type {
+	if d.Type == "" {
+		return TypeString
+	}
+	return d.Type
+}
+
+// Validate returns an error if value is not a valid value of the property.
+// An empty value is valid: it unsets the property.
+func (d Definition) Validate(value string) error {
+	if value == "" {
+		return nil
+	}
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("invalid value %q for %s: %s", value, d.Name, fmt.Sprintf(format, args...))
+	}
+	switch d.typ() {
+	case TypeString:
+	case TypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return invalid("expected an integer")
+		}
+	case TypeFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return invalid("expected a number")
+		}
+	case TypeBool:
+		if v := strings.ToLower(value); v != "true" && v != "false" {
+			return invalid("expected true or false")
+		}
+	case TypeDuration:
+		if _, err := duration.ParseDuration(value); err != nil {
+			return invalid("expected a duration, e.g. 30s, 5m or 1d")
+		}
+	case TypeTimeIntervals:
+		var intervals []timeinterval.TimeInterval
+		if err := json.Unmarshal([]byte(value), &intervals); err != nil {
+			return invalid("expected a JSON array of time intervals: %v", err)
+		}
+	default:
+		return fmt.Errorf("%s has an unknown type %q", d.Name, d.Type)
+	}
+	if len(d.Enum) > 0 && !slices.Contains(d.Enum, value) {
+		return invalid("expected one of %s", strings.Join(d.Enum, ", "))
+	}
+	if d.Min == "" && d.Max == "" {
+		return nil
+	}
+	v, _ := d.number(value)
+	if d.Min != "" {
+		if minimum, _ := d.number(d.Min); v < minimum {
+			return invalid("must be at least %s", d.Min)
+		}
+	}
+	if d.Max != "" {
+		if maximum, _ := d.number(d.Max); v > maximum {
+			return invalid("must be at most %s", d.Max)
+		}
+	}
+	return nil
+}
+
+// number converts a value of a bounded property for comparison.
+func (d Definition) number(value string) (float64, error) {
+	switch d.typ() {
+	case TypeInt, TypeFloat:
+		return strconv.ParseFloat(value, 64)
+	case TypeDuration:
+		v, err := duration.ParseDuration(value)
+		return float64(v), err
+	default:
+		return 0, fmt.Errorf("%s properties cannot have a minimum or maximum", d.typ())
+	}
+}
+
+func (d Definition) check() error {
+	if d.Name == "" {
+		return errors.New("property definition without a name")
+	}
+	switch d.typ() {
+	case TypeString, TypeInt, TypeFloat, TypeBool, TypeDuration, TypeTimeIntervals:
+	default:
+		return fmt.Errorf("%s has an unknown type %q", d.Name, d.Type)
+	}
+	for _, bound := range []string{d.Min, d.Max} {
+		if bound == "" {
+			continue
+		}
+		if _, err := d.number(bound); err != nil {
+			return fmt.Errorf("%s has an invalid bound %q: %w", d.Name, bound, err)
+		}
+	}
+	if err := d.Validate(d.Default); err != nil {
+		return fmt.Errorf("invalid default: %w", err)
+	}
+	return nil
+}
+
+// Property describes a property, see List.
+type Property struct {
+	Name string
+	// Value is the value in effect, the default if the property is not set.
+	Value string
+	// Origin is the source of Value, empty when the default applies.
+	Origin string
+	// Definition is the registered definition, the zero value for unknown
+	// properties.
+	Definition Definition
+	// Unknown is true for properties that are set but not registered.
+	Unknown bool
+}
+
+var deprecationWarnings sync.Map
+
+// Register declares properties. Set, Update and sources then reject invalid
+// values of these properties (SetE and UpdateE return the error), Get returns
+// their default when they are not set, and their former names are mapped to
+// the current ones. Registering a property again replaces its definition.
+func (p *Properties) Register(defs ...Definition) error {
+	for _, def := range defs {
+		if err := def.check(); err != nil {
+			return err
+		}
+	}
+	p.lock.Lock()
+	if p.schema == nil {
+		p.schema = make(map[string]Definition)
+		p.aliases = make(map[string]string)
+	}
+	for _, def := range defs {
+		if old, ok := p.schema[def.Name]; ok {
+			for _, alias := range old.Deprecated {
+				delete(p.aliases, alias)
+			}
+		}
+		p.schema[def.Name] = def
+		for _, alias := range def.Deprecated {
+			p.aliases[alias] = def.Name
+		}
+	}
+	p.resolve()
+	err := p.validate(p.m)
+	diff, changed := p.recordChanges(RegisterChange)
+	p.lock.Unlock()
+	if err != nil {
+		slog.Warn(fmt.Sprintf("properties do not match their definitions: %v", err))
+	}
+	p.notify(diff, changed)
+	return nil
+}
+
+// Validate checks every property, including -P command line properties,
+// against the registered definitions.
+func (p *Properties) Validate() error {
+	values := p.GetAll()
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.validate(values)
+}
+
+// validate checks values against the definitions, p.lock must be held.
+func (p *Properties) validate(values map[string]string) error {
+	var errs []error
+	for _, k := range sortedKeys(values) {
+		if secretRef.MatchString(values[k]) {
+			continue // secrets are not resolved, nor printed in errors
+		}
+		if def, ok := p.schema[p.canonical(k)]; ok {
+			if err := def.Validate(values[k]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// canonical returns the current name of a deprecated property name, p.lock
+// must be held.
+func (p *Properties) canonical(key string) string {
+	name, ok := p.aliases[key]
+	if !ok {
+		return key
+	}
+	if _, warned := deprecationWarnings.LoadOrStore(key, true); !warned {
+		slog.Warn(fmt.Sprintf("property %s is deprecated, use %s", key, name))
+	}
+	return name
+}
+
+// commandLine returns the -P value of key or one of its former names, p.lock
+// must be held.
+func (p *Properties) commandLine(key string) (string, bool) {
+	if v, ok := commandlineProperties[key]; ok {
+		return v, true
+	}
+	for _, alias := range p.schema[key].Deprecated {
+		if v, ok := commandlineProperties[alias]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Definitions returns the registered definitions sorted by name.
+func (p *Properties) Definitions() []Definition {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	defs := make([]Definition, 0, len(p.schema))
+	for _, name := range sortedKeys(p.schema) {
+		defs = append(defs, p.schema[name])
+	}
+	return defs
+}
+
+// List returns every registered or set property sorted by name, marking the
+// ones that are not registered, e.g. misspelled keys.
+func (p *Properties) List() []Property {
+	values := p.GetAll()
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	props := make(map[string]Property, len(values)+len(p.schema))
+	for name, def := range p.schema {
+		props[name] = Property{Name: name, Value: def.Default, Definition: def}
+	}
+	for k, v := range values {
+		k = p.canonical(k)
+		def, ok := p.schema[k]
+		origin := p.origins[k]
+		if _, cmd := p.commandLine(k); cmd {
+			origin = CommandLineSource
+		}
+		if v == "" && ok {
+			v, origin = def.Default, ""
+		}
+		props[k] = Property{Name: k, Value: v, Origin: origin, Definition: def, Unknown: !ok}
+	}
+	list := make([]Property, 0, len(props))
+	for _, k := range sortedKeys(props) {
+		list = append(list, props[k])
+	}
+	return list
+}
+
+// Unknown returns the keys returned by GetAll that are not registered, e.g.
+// misspelled keys, sorted.
+func (p *Properties) Unknown() []string {
+	var unknown []string
+	for _, prop := range p.List() {
+		if prop.Unknown {
+			unknown = append(unknown, prop.Name)
+		}
+	}
+	return unknown
+}
+
+// Markdown documents the registered properties as a markdown table.
+func (p *Properties) Markdown() string {
+	var b strings.Builder
+	b.WriteString("| Property | Type | Default | Description |\n")
+	b.WriteString("|----------|------|---------|-------------|\n")
+	for _, def := range p.Definitions() {
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", def.Name, strings.ReplaceAll(def.summary(), "|", `\|`),
+			markdownCode(def.Default), strings.ReplaceAll(def.details(), "|", `\|`))
+	}
+	return b.String()
+}
+
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+// Usage documents the registered properties for --help output, e.g. with
+// cobra:
+//
+//	cmd.SetUsageTemplate(cmd.UsageTemplate() + "\n" + properties.Usage())
+func (p *Properties) Usage() string {
+	var b strings.Builder
+	b.WriteString("Properties (-P name=value):\n")
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, def := range p.Definitions() {
+		details := def.details()
+		if def.Default != "" {
+			details = strings.TrimSpace(fmt.Sprintf("%s (default %s)", details, def.Default))
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", def.Name, def.summary(), details)
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+// summary returns the type and allowed values of a property.
+func (d Definition) summary() string {
+	s := string(d.typ())
+	switch {
+	case len(d.Enum) > 0:
+		s += " (" + strings.Join(d.Enum, ", ") + ")"
+	case d.Min != "" && d.Max != "":
+		s += " (" + d.Min + ".." + d.Max + ")"
+	case d.Min != "":
+		s += " (>= " + d.Min + ")"
+	case d.Max != "":
+		s += " (<= " + d.Max + ")"
+	}
+	return s
+}
+
+// details returns the description and former names of a property.
+func (d Definition) details() string {
+	s := d.Description
+	if len(d.Deprecated) > 0 {
+		s = strings.TrimSpace(s + " (deprecated: " + strings.Join(d.Deprecated, ", ") + ")")
+	}
+	return s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Register declares global properties, see Properties.Register.
+func Register(defs ...Definition) error {
+	return Global.Register(defs...)
+}
+
+// List describes the global properties, see Properties.List.
+func List() []Property {
+	return Global.List()
+}
+
+// Unknown returns the global properties that are set but not registered, see
+// Properties.Unknown.
+func Unknown() []string {
+	return Global.Unknown()
+}
+
+// Usage documents the global properties for --help output, see
+// Properties.Usage.
+func Usage() string {
+	return Global.Usage()
+}
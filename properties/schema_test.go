@@ -0,0 +1,172 @@
+package properties
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSchemaProperties(t *testing.T) *Properties {
+	t.Helper()
+	p := &Properties{}
+	err := p.Register(
+		Definition{Name: "server.port", Type: TypeInt, Default: "8080", Min: "1", Max: "65535", Description: "Port to listen on", Deprecated: []string{"port"}},
+		Definition{Name: "log.level", Enum: []string{"info", "debug"}, Default: "info"},
+		Definition{Name: "timeout", Type: TypeDuration, Min: "1s", Description: "Request timeout"},
+		Definition{Name: "debug", Type: TypeBool},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDefinition_Validate(t *testing.T) {
+	p := newSchemaProperties(t)
+	for _, tc := range []struct {
+		key, value string
+		err        string
+	}{
+		{"server.port", "443", ""},
+		{"server.port", "http", `invalid value "http" for server.port: expected an integer`},
+		{"server.port", "0", `invalid value "0" for server.port: must be at least 1`},
+		{"server.port", "70000", "must be at most 65535"},
+		{"log.level", "trace", "expected one of info, debug"},
+		{"timeout", "1d", ""},
+		{"timeout", "500ms", "must be at least 1s"},
+		{"timeout", "soon", "expected a duration"},
+		{"debug", "TRUE", ""},
+		{"debug", "yes", "expected true or false"},
+		{"port", "x", "for server.port: expected an integer"},
+		{"unknown", "anything", ""},
+		{"server.port", "", ""},
+	} {
+		err := p.SetE(tc.key, tc.value)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("Set(%s, %s) = %v", tc.key, tc.value, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("Set(%s, %s) = %v, expected %q", tc.key, tc.value, err, tc.err)
+		}
+	}
+}
+
+func TestRegister_InvalidDefinition(t *testing.T) {
+	p := &Properties{}
+	for _, def := range []Definition{
+		{},
+		{Name: "a", Type: "list"},
+		{Name: "a", Type: TypeInt, Default: "x"},
+		{Name: "a", Type: TypeBool, Min: "1"},
+		{Name: "a", Enum: []string{"x"}, Default: "y"},
+	} {
+		if err := p.Register(def); err == nil {
+			t.Errorf("Register(%+v) succeeded", def)
+		}
+	}
+}
+
+func TestUpdate_RejectsInvalidValuesAtomically(t *testing.T) {
+	p := newSchemaProperties(t)
+	var notified int
+	p.RegisterListener(func(*Properties) { notified++ })
+	if err := p.UpdateE(map[string]string{"debug": "true", "server.port": "-1"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if p.Get("debug") != "" || notified != 0 {
+		t.Errorf("invalid update applied: debug=%q, %d notifications", p.Get("debug"), notified)
+	}
+}
+
+func TestRegister_DefaultsAndAliases(t *testing.T) {
+	p := newSchemaProperties(t)
+	if v := p.Int(0, "server.port"); v != 8080 {
+		t.Errorf("default port = %d", v)
+	}
+	if err := p.SetE("port", 9090); err != nil {
+		t.Fatal(err)
+	}
+	if p.Get("server.port") != "9090" || p.Get("port") != "9090" {
+		t.Errorf("deprecated name not mapped: %q, %q", p.Get("server.port"), p.Get("port"))
+	}
+	if d := p.Duration(0, "timeout"); d != 0 {
+		t.Errorf("timeout = %s", d)
+	}
+	if err := p.SetE("timeout", "1d"); err != nil {
+		t.Fatal(err)
+	}
+	if d := p.Duration(0, "timeout"); d != 24*time.Hour {
+		t.Errorf("timeout = %s", d)
+	}
+}
+
+func TestLoadFile_RejectsInvalidValues(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.properties", "port=8081\n")
+	p := newSchemaProperties(t)
+	defer func() {
+		if p.close != nil {
+			p.close()
+		}
+	}()
+	if err := p.LoadFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if v := p.Get("server.port"); v != "8081" {
+		t.Errorf("server.port = %q", v)
+	}
+
+	writeFile(t, dir, "app.properties", "server.port=http\nlog.level=trace\n")
+	err := p.LoadFile(file)
+	if err == nil || !strings.Contains(err.Error(), "server.port") || !strings.Contains(err.Error(), "log.level") {
+		t.Fatalf("expected both invalid values to be reported, got %v", err)
+	}
+	if v := p.Get("server.port"); v != "8081" {
+		t.Errorf("invalid file replaced server.port with %q", v)
+	}
+}
+
+func TestList_MarksUnknownKeys(t *testing.T) {
+	p := newSchemaProperties(t)
+	p.Set("log.levle", "debug")
+	p.Set("log.level", "debug")
+
+	var unknown []string
+	byName := map[string]Property{}
+	for _, prop := range p.List() {
+		byName[prop.Name] = prop
+		if prop.Unknown {
+			unknown = append(unknown, prop.Name)
+		}
+	}
+	if strings.Join(unknown, ",") != "log.levle" {
+		t.Errorf("unknown = %v", unknown)
+	}
+	if got := p.Unknown(); strings.Join(got, ",") != "log.levle" {
+		t.Errorf("Unknown() = %v", got)
+	}
+	if port := byName["server.port"]; port.Value != "8080" || port.Origin != "" {
+		t.Errorf("server.port = %+v", port)
+	}
+	if level := byName["log.level"]; level.Value != "debug" || level.Origin != RuntimeSource {
+		t.Errorf("log.level = %+v", level)
+	}
+}
+
+func TestMarkdownAndUsage(t *testing.T) {
+	p := newSchemaProperties(t)
+	md := p.Markdown()
+	for _, line := range []string{
+		"| `server.port` | int (1..65535) | `8080` | Port to listen on (deprecated: port) |",
+		"| `log.level` | string (info, debug) | `info` |  |",
+		"| `timeout` | duration (>= 1s) |  | Request timeout |",
+	} {
+		if !strings.Contains(md, line) {
+			t.Errorf("markdown is missing %q:\n%s", line, md)
+		}
+	}
+	usage := p.Usage()
+	if !strings.Contains(usage, "server.port  int (1..65535)") || !strings.Contains(usage, "(default 8080)") {
+		t.Errorf("usage:\n%s", usage)
+	}
+}
@@ -0,0 +1,236 @@
+package properties
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultSecretTTL is how long resolved ${file:...} and ${exec:...} secrets
+// are cached when Properties.SecretTTL is zero.
+const DefaultSecretTTL = 5 * time.Minute
+
+// secretRetryInterval is how long a failure to resolve a secret is cached,
+// so a failing command is not run on every Get.
+const secretRetryInterval = 10 * time.Second
+
+// execTimeout bounds ${exec:...} commands.
+const execTimeout = 30 * time.Second
+
+var secretRef = regexp.MustCompile(`\$\{(env|file|exec):([^}]*)\}`)
+
+// MaskSecret masks resolved secrets in Printable. The logger package sets it
+// to logger.PrintableSecret.
+var MaskSecret = func(string) string { return "****" }
+
+type cachedSecret struct {
+	value   string
+	err     error
+	expires time.Time
+	path    string // file of ${file:...} secrets
+	// refreshing is true while an expired secret is read again in the
+	// background
+	refreshing bool
+}
+
+// secretCache caches resolved secret references, by reference. The lock is
+// not held while secrets are read, so a slow command only delays the Gets of
+// its own reference.
+type secretCache struct {
+	lock    sync.Mutex
+	secrets map[string]cachedSecret
+	// generation is incremented by invalidateSecrets, so a read that started
+	// before a rotation does not cache the old secret
+	generation uint64
+	loads      singleflight.Group
+}
+
+// IsSecret returns true if the value of key references a secret:
+//
+//	db.password=${env:DB_PASSWORD}
+//	db.password=${file:/var/run/secrets/db/password}
+//	db.password=${exec:vault kv get -field=password secret/db} // see AllowExec
+//	db.url=postgres://app:${env:DB_PASSWORD}@db:5432/app
+func (p *Properties) IsSecret(key string) bool {
+	return secretRef.MatchString(p.raw(key))
+}
+
+// Printable returns the value of key with resolved secrets masked by
+// MaskSecret, for logs and debug output.
+func (p *Properties) Printable(key string) string {
+	v := p.Get(key)
+	if !p.IsSecret(key) {
+		return v
+	}
+	return MaskSecret(v)
+}
+
+// resolveSecrets replaces the secret references in value with the secrets:
+// environment variables are read on every call, files until they change or
+// the SecretTTL expires, and command outputs until the SecretTTL expires.
+// Once expired, the last secret is returned while it is read again. Commands
+// are only run if allowExec is true.
+func (p *Properties) resolveSecrets(value string, allowExec bool) (string, error) {
+	var errs []string
+	resolved := secretRef.ReplaceAllStringFunc(value, func(ref string) string {
+		secret, err := p.resolveSecret(ref, allowExec)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		return secret
+	})
+	if len(errs) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return resolved, nil
+}
+
+func (p *Properties) resolveSecret(ref string, allowExec bool) (string, error) {
+	match := secretRef.FindStringSubmatch(ref)
+	kind, arg := match[1], strings.TrimSpace(match[2])
+	if kind == "exec" && !allowExec {
+		return "", fmt.Errorf("%s: commands are only run with AllowExec, from the command line, a file or a MapSource", ref)
+	}
+	if kind == "env" {
+		v, ok := os.LookupEnv(arg)
+		if !ok {
+			return "", fmt.Errorf("%s: environment variable %s is not set", ref, arg)
+		}
+		return v, nil
+	}
+
+	p.secrets.lock.Lock()
+	cached, ok := p.secrets.secrets[ref]
+	switch {
+	case ok && time.Now().Before(cached.expires):
+		p.secrets.lock.Unlock()
+		return cached.value, cached.err
+	case ok && cached.err == nil && p.secretTTL() > 0:
+		// serve the expired secret while it is read again
+		if !cached.refreshing {
+			cached.refreshing = true
+			p.secrets.secrets[ref] = cached
+			go p.loadSecret(ref, kind, arg)
+		}
+		p.secrets.lock.Unlock()
+		return cached.value, nil
+	}
+	p.secrets.lock.Unlock()
+
+	loaded := p.loadSecret(ref, kind, arg)
+	return loaded.value, loaded.err
+}
+
+// loadSecret reads a secret, once for concurrent callers, and caches it.
+func (p *Properties) loadSecret(ref, kind, arg string) cachedSecret {
+	loaded, _, _ := p.secrets.loads.Do(ref, func() (any, error) {
+		p.secrets.lock.Lock()
+		generation := p.secrets.generation
+		p.secrets.lock.Unlock()
+
+		var value string
+		var err error
+		entry := cachedSecret{}
+		switch kind {
+		case "file":
+			entry.path, err = filepath.Abs(arg)
+			if err == nil {
+				value, err = p.readSecretFile(entry.path)
+			}
+		case "exec":
+			value, err = runSecretCommand(arg)
+		}
+
+		p.secrets.lock.Lock()
+		defer p.secrets.lock.Unlock()
+		if p.secrets.secrets == nil {
+			p.secrets.secrets = make(map[string]cachedSecret)
+		}
+		cached, ok := p.secrets.secrets[ref]
+		if err != nil {
+			err = fmt.Errorf("%s: %w", ref, err)
+			if ok && cached.err == nil {
+				// keep the last known secret, e.g. while a vault is unavailable
+				slog.Warn(fmt.Sprintf("failed to refresh secret, using the cached value: %v", err))
+				cached.expires, cached.refreshing = time.Now().Add(secretRetryInterval), false
+				p.secrets.secrets[ref] = cached
+				return cached, nil
+			}
+			entry.err, entry.expires = err, time.Now().Add(secretRetryInterval)
+		} else {
+			entry.value, entry.expires = value, time.Now().Add(p.secretTTL())
+		}
+		if generation == p.secrets.generation {
+			p.secrets.secrets[ref] = entry
+		} else {
+			// rotated while it was read: read it again on the next Get
+			delete(p.secrets.secrets, ref)
+		}
+		return entry, nil
+	})
+	return loaded.(cachedSecret)
+}
+
+func (p *Properties) secretTTL() time.Duration {
+	if p.SecretTTL == 0 {
+		return DefaultSecretTTL
+	}
+	return p.SecretTTL
+}
+
+// readSecretFile reads a secret file and watches its directory, so a rotated
+// secret, e.g. a Kubernetes secret mount updated by swapping its ..data link,
+// is read again.
+func (p *Properties) readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if err := p.watchPath(filepath.Dir(path)); err != nil {
+		slog.Warn(fmt.Sprintf("failed to watch %s for rotation: %v", path, err))
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func runSecretCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+	// the output is not included in errors, as it may contain the secret
+	out, err := exec.CommandContext(ctx, "sh", "-c", command).Output()
+	if err != nil {
+		return "", fmt.Errorf("command failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// invalidateSecrets drops the cached file secrets in the directory of a
+// changed file, returning true if there were any.
+func (p *Properties) invalidateSecrets(changed string) bool {
+	dir := filepath.Dir(changed)
+	p.secrets.lock.Lock()
+	defer p.secrets.lock.Unlock()
+	p.secrets.generation++
+	invalidated := false
+	for ref, secret := range p.secrets.secrets {
+		if secret.path != "" && filepath.Dir(secret.path) == dir {
+			delete(p.secrets.secrets, ref)
+			invalidated = true
+		}
+	}
+	return invalidated
+}
+
+// Printable returns a global property with secrets masked, see
+// Properties.Printable.
+func Printable(key string) string {
+	return Global.Printable(key)
+}
@@ -0,0 +1,197 @@
+package properties
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecrets_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	secret := writeFile(t, dir, "password", "file-secret\n")
+	t.Setenv("TEST_DB_PASS", "env-secret")
+	p := &Properties{AllowExec: true}
+	addValues(t, p, map[string]string{
+		"env":      "${env:TEST_DB_PASS}",
+		"file":     "${file:" + secret + "}",
+		"exec":     "${exec:echo exec-secret}",
+		"url":      "postgres://app:${env:TEST_DB_PASS}@db/app",
+		"missing":  "${env:TEST_NOT_SET}",
+		"failing":  "${exec:exit 1}",
+		"template": "${name}",
+	})
+	for key, expected := range map[string]string{
+		"env":      "env-secret",
+		"file":     "file-secret",
+		"exec":     "exec-secret",
+		"url":      "postgres://app:env-secret@db/app",
+		"missing":  "",
+		"failing":  "",
+		"template": "${name}",
+	} {
+		if v := p.Get(key); v != expected {
+			t.Errorf("%s = %q, expected %q", key, v, expected)
+		}
+	}
+
+	all := p.GetAll()
+	if all["env"] != "${env:TEST_DB_PASS}" || all["file"] != "${file:"+secret+"}" {
+		t.Errorf("GetAll resolved secrets: %v", all)
+	}
+	if !p.IsSecret("url") || p.IsSecret("template") {
+		t.Error("IsSecret")
+	}
+	if v := p.Printable("env"); strings.Contains(v, "env-secret") {
+		t.Errorf("Printable = %q", v)
+	}
+}
+
+func TestSecrets_CacheTTL(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "counter")
+	p := &Properties{SecretTTL: time.Hour, AllowExec: true}
+	addValues(t, p, map[string]string{"token": "${exec:echo x >> " + counter + "; wc -l < " + counter + "}"})
+	if a, b := p.Get("token"), p.Get("token"); a != "1" || b != "1" {
+		t.Errorf("cached command ran again: %q, %q", a, b)
+	}
+
+	p = &Properties{SecretTTL: -1, AllowExec: true}
+	addValues(t, p, map[string]string{"token": "${exec:echo x >> " + counter + "; wc -l < " + counter + "}"})
+	if a, b := p.Get("token"), p.Get("token"); a != "2" || b != "3" {
+		t.Errorf("uncached command did not run: %q, %q", a, b)
+	}
+}
+
+func TestSecrets_SlowCommandsDoNotBlock(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "counter")
+	p := &Properties{SecretTTL: 50 * time.Millisecond, AllowExec: true}
+	addValues(t, p, map[string]string{
+		"slow":    "${exec:sleep 1; echo slow}",
+		"fast":    "${exec:echo fast}",
+		"refresh": "${exec:echo x >> " + counter + "; sleep 0.5; wc -l < " + counter + "}",
+	})
+	if v := p.Get("refresh"); v != "1" {
+		t.Fatalf("refresh = %q", v)
+	}
+
+	go p.Get("slow")
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	if v := p.Get("fast"); v != "fast" {
+		t.Errorf("fast = %q", v)
+	}
+	// the expired secret is served while it is read again
+	if v := p.Get("refresh"); v != "1" {
+		t.Errorf("refresh = %q, expected the expired value", v)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Gets waited %s for other commands", elapsed)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Get("refresh") != "2" {
+		if time.Now().After(deadline) {
+			t.Fatal("expired secret was not refreshed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSecrets_ExecOnlyFromTrustedSources(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	cmd := "${exec:touch " + marker + "; echo ran}"
+	t.Setenv("APP_ENV_TOKEN", cmd)
+
+	p := &Properties{AllowExec: true}
+	remote := SourceFunc("remote", func() (map[string]string, error) {
+		return map[string]string{"remote.token": cmd}, nil
+	})
+	if err := p.AddSource(remote, PrecedenceRemote); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.AddSource(EnvSource{Prefix: "APP_"}, PrecedenceEnv); err != nil {
+		t.Fatal(err)
+	}
+	p.Set("runtime.token", cmd)
+	for _, key := range []string{"remote.token", "env.token", "runtime.token"} {
+		if v := p.Get(key); v != "" {
+			t.Errorf("%s = %q, expected the command not to run", key, v)
+		}
+	}
+
+	disabled := &Properties{}
+	addValues(t, disabled, map[string]string{"token": cmd})
+	if v := disabled.Get("token"); v != "" {
+		t.Errorf("token = %q without AllowExec", v)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("an untrusted value ran a command")
+	}
+
+	file := writeFile(t, dir, "app.properties", "token="+cmd+"\n")
+	if err := p.AddSource(FileSource{Path: file}, PrecedenceFile); err != nil {
+		t.Fatal(err)
+	}
+	if v := p.Get("token"); v != "ran" {
+		t.Errorf("token = %q from a file with AllowExec", v)
+	}
+}
+
+func TestSecrets_FileRotation(t *testing.T) {
+	dir := t.TempDir()
+	// a Kubernetes secret mount: password -> ..data/password -> ..2024/password
+	if err := os.MkdirAll(filepath.Join(dir, "..v1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "..v1/password", "old")
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..data/password", filepath.Join(dir, "password")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Properties{SecretTTL: time.Hour}
+	t.Cleanup(func() {
+		if p.watcher != nil {
+			_ = p.watcher.Close()
+		}
+	})
+	notified := make(chan struct{}, 10)
+	p.RegisterListener(func(*Properties) { notified <- struct{}{} })
+	p.Set("password", "${file:"+filepath.Join(dir, "password")+"}")
+	<-notified
+	if v := p.Get("password"); v != "old" {
+		t.Fatalf("password = %q", v)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "..v2"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "..v2/password", "new")
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotation was not detected")
+	}
+	if v := p.Get("password"); v != "new" {
+		t.Errorf("password = %q after rotation", v)
+	}
+}
+
+// addValues adds values as a MapSource, which may run commands.
+func addValues(t *testing.T, p *Properties, values map[string]string) {
+	t.Helper()
+	if err := p.AddSource(MapSource{Values: values}, PrecedenceDefaults); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,467 @@
+package properties
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// Source provides properties from outside the program, e.g. a file, the
+// environment or a remote endpoint. Sources are added to Properties with a
+// Precedence, see AddSource.
+type Source interface {
+	// Name identifies the source, e.g. "file:/etc/app.properties". Adding a
+	// source with the name of an existing one replaces it.
+	Name() string
+	// Load returns the properties of the source.
+	Load() (map[string]string, error)
+}
+
+// Precedence orders sources: a property is taken from the source with the
+// highest precedence defining it. Values set with Set and Update override
+// every source, and -P command line properties override those.
+type Precedence int
+
+const (
+	PrecedenceDefaults Precedence = 100
+	PrecedenceFile     Precedence = 200
+	PrecedenceRemote   Precedence = 300
+	PrecedenceEnv      Precedence = 400
+	PrecedenceFlags    Precedence = 500
+)
+
+// Source names of values that do not come from a Source, see Origin.
+const (
+	// RuntimeSource is the origin of values set with Set and Update.
+	RuntimeSource = "runtime"
+	// CommandLineSource is the origin of -P command line properties.
+	CommandLineSource = "command-line"
+)
+
+// SourceValue is the value of a property in one source, see Lookup.
+type SourceValue struct {
+	Source string
+	Value  string
+}
+
+type layer struct {
+	source     Source
+	precedence Precedence
+	values     map[string]string
+}
+
+// AddSource loads src and adds it at precedence, replacing a source with the
+// same name, and notifies listeners. A source with values that are invalid
+// for registered properties is not added.
+func (p *Properties) AddSource(src Source, precedence Precedence) error {
+	return p.addSource(src, precedence, src.Name())
+}
+
+// addSource adds src, recording changes as made by change.
+func (p *Properties) addSource(src Source, precedence Precedence, change string) error {
+	values, err := src.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load properties from %s: %w", src.Name(), err)
+	}
+	p.lock.Lock()
+	if err := p.validate(values); err != nil {
+		p.lock.Unlock()
+		return fmt.Errorf("invalid properties in %s: %w", src.Name(), err)
+	}
+	p.layers = slices.DeleteFunc(p.layers, func(l *layer) bool { return l.source.Name() == src.Name() })
+	p.layers = append(p.layers, &layer{source: src, precedence: precedence, values: values})
+	sort.SliceStable(p.layers, func(i, j int) bool { return p.layers[i].precedence < p.layers[j].precedence })
+	p.resolve()
+	diff, changed := p.recordChanges(change)
+	p.lock.Unlock()
+	p.notify(diff, changed)
+	return nil
+}
+
+// RemoveSource removes the named source, returning false if there is none.
+func (p *Properties) RemoveSource(name string) bool {
+	p.lock.Lock()
+	n := len(p.layers)
+	p.layers = slices.DeleteFunc(p.layers, func(l *layer) bool { return l.source.Name() == name })
+	removed := len(p.layers) != n
+	var diff Diff
+	var changed bool
+	if removed {
+		p.resolve()
+		diff, changed = p.recordChanges("remove:" + name)
+	}
+	p.lock.Unlock()
+	if removed {
+		p.notify(diff, changed)
+	}
+	return removed
+}
+
+// ReloadSources loads every source again. A source failing to load, or with
+// invalid values, keeps its previous values.
+func (p *Properties) ReloadSources() error {
+	p.lock.RLock()
+	layers := slices.Clone(p.layers)
+	p.lock.RUnlock()
+
+	var errs []error
+	loaded := map[*layer]map[string]string{}
+	for _, l := range layers {
+		values, err := l.source.Load()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load properties from %s: %w", l.source.Name(), err))
+			continue
+		}
+		loaded[l] = values
+	}
+	p.lock.Lock()
+	for l, values := range loaded {
+		if err := p.validate(values); err != nil {
+			errs = append(errs, fmt.Errorf("invalid properties in %s: %w", l.source.Name(), err))
+			continue
+		}
+		l.values = values
+	}
+	p.resolve()
+	diff, changed := p.recordChanges(ReloadChange)
+	p.lock.Unlock()
+	p.notify(diff, changed)
+	return errors.Join(errs...)
+}
+
+// Sources returns the names of the sources, highest precedence first.
+func (p *Properties) Sources() []string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	var names []string
+	for i := len(p.layers) - 1; i >= 0; i-- {
+		names = append(names, p.layers[i].source.Name())
+	}
+	return names
+}
+
+// Origin returns the name of the source the value of key comes from:
+// CommandLineSource, RuntimeSource or a Source name, "" if it is not set.
+func (p *Properties) Origin(key string) string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	key = p.canonical(key)
+	if _, ok := p.commandLine(key); ok {
+		return CommandLineSource
+	}
+	return p.origins[key]
+}
+
+// Lookup returns the value of key, under its current or a deprecated name, in
+// every source defining it, the value in effect first.
+func (p *Properties) Lookup(key string) []SourceValue {
+	var values []SourceValue
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	key = p.canonical(key)
+	if v, ok := p.commandLine(key); ok {
+		values = append(values, SourceValue{Source: CommandLineSource, Value: v})
+	}
+	if v, ok := p.runtime[key]; ok {
+		values = append(values, SourceValue{Source: RuntimeSource, Value: v})
+	}
+	names := append([]string{key}, p.schema[key].Deprecated...)
+	for i := len(p.layers) - 1; i >= 0; i-- {
+		for _, name := range names {
+			if v, ok := p.layers[i].values[name]; ok {
+				values = append(values, SourceValue{Source: p.layers[i].source.Name(), Value: v})
+			}
+		}
+	}
+	return values
+}
+
+// resolve merges the sources and runtime values, p.lock must be held.
+func (p *Properties) resolve() {
+	m := make(map[string]string)
+	origins := make(map[string]string)
+	for _, l := range p.layers {
+		for k, v := range l.values {
+			name := p.canonical(k)
+			if _, ok := l.values[name]; ok && name != k {
+				continue // the current name wins over a deprecated one
+			}
+			m[name], origins[name] = v, l.source.Name()
+		}
+	}
+	for k, v := range p.runtime {
+		m[k], origins[k] = v, RuntimeSource
+	}
+	p.m, p.origins = m, origins
+}
+
+// trusted returns true if values of the named source may run commands, see
+// AllowExec. p.lock must be held.
+func (p *Properties) trusted(origin string) bool {
+	for _, l := range p.layers {
+		if l.source.Name() != origin {
+			continue
+		}
+		switch l.source.(type) {
+		case FileSource, *FileSource, MapSource, *MapSource:
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// MapSource is a fixed set of properties, e.g. defaults.
+type MapSource struct {
+	// SourceName defaults to "defaults".
+	SourceName string
+	Values     map[string]string
+}
+
+func (s MapSource) Name() string {
+	if s.SourceName == "" {
+		return "defaults"
+	}
+	return s.SourceName
+}
+
+func (s MapSource) Load() (map[string]string, error) {
+	return maps.Clone(s.Values), nil
+}
+
+// FileSource reads a properties file: YAML (.yaml, .yml) and JSON (.json)
+// files are flattened to dotted keys, with lists as JSON values, other files
+// are parsed as key=value lines.
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Name() string {
+	return "file:" + s.Path
+}
+
+func (s FileSource) Load() (map[string]string, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseFile(s.Path, f)
+}
+
+func parseFile(name string, r io.Reader) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		var tree any
+		if err := yaml.NewDecoder(r).Decode(&tree); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		return flatten(tree)
+	case ".json":
+		var tree any
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return flatten(tree)
+	default:
+		return parseProperties(r)
+	}
+}
+
+// parseProperties parses key=value lines, ignoring blank lines and # comments.
+func parseProperties(r io.Reader) (map[string]string, error) {
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tokens := strings.SplitN(line, "=", 2)
+		if len(tokens) != 2 {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+		props[strings.TrimSpace(tokens[0])] = strings.TrimSpace(tokens[1])
+	}
+	return props, scanner.Err()
+}
+
+// flatten converts a YAML or JSON document to dotted keys.
+func flatten(tree any) (map[string]string, error) {
+	props := make(map[string]string)
+	if tree == nil {
+		return props, nil
+	}
+	root, ok := tree.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object, got %T", tree)
+	}
+	return props, flattenInto(props, "", root)
+}
+
+func flattenInto(props map[string]string, prefix string, m map[string]any) error {
+	for k, v := range m {
+		key := prefix + k
+		switch value := v.(type) {
+		case map[string]any:
+			if err := flattenInto(props, key+".", value); err != nil {
+				return err
+			}
+		case []any:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			props[key] = string(data)
+		case nil:
+			props[key] = ""
+		case float64:
+			props[key] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			props[key] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+// DirSource reads a directory such as a mounted Kubernetes ConfigMap: each
+// file is a property named after it, except for .properties, .yaml, .yml and
+// .json files, which are parsed as a FileSource. Hidden files, including the
+// ..data links of ConfigMap mounts, are skipped.
+type DirSource struct {
+	Path string
+}
+
+func (s DirSource) Name() string {
+	return "dir:" + s.Path
+}
+
+func (s DirSource) Load() (map[string]string, error) {
+	entries, err := os.ReadDir(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(s.Path, name)
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue // ConfigMap keys are symlinks to files
+		}
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".properties", ".yaml", ".yml", ".json":
+			values, err := FileSource{Path: path}.Load()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			for k, v := range values {
+				props[k] = v
+			}
+		default:
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			props[name] = strings.TrimSpace(string(data))
+		}
+	}
+	return props, nil
+}
+
+// EnvSource maps environment variables starting with Prefix to properties:
+// with the prefix APP_, APP_LOG_LEVEL=debug sets log.level. Underscores
+// become dots and a double underscore an underscore, so
+// APP_TIME__INTERVAL_BUSINESS__HOURS sets time_interval.business_hours.
+type EnvSource struct {
+	Prefix string
+}
+
+func (s EnvSource) Name() string {
+	return "env:" + s.Prefix
+}
+
+func (s EnvSource) Load() (map[string]string, error) {
+	props := make(map[string]string)
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		rest, ok := strings.CutPrefix(name, s.Prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		parts := strings.Split(strings.ToLower(rest), "__")
+		for i := range parts {
+			parts[i] = strings.ReplaceAll(parts[i], "_", ".")
+		}
+		props[strings.Join(parts, "_")] = value
+	}
+	return props, nil
+}
+
+// FlagSource reads the flags set on the command line: --log-level=debug sets
+// log.level, unless Keys maps the flag to another property. Load it after the
+// flags are parsed.
+type FlagSource struct {
+	Flags *pflag.FlagSet
+	// Keys maps flag names to property keys.
+	Keys map[string]string
+}
+
+func (s FlagSource) Name() string {
+	return "flags"
+}
+
+func (s FlagSource) Load() (map[string]string, error) {
+	props := make(map[string]string)
+	s.Flags.Visit(func(f *pflag.Flag) {
+		if f.Name == "properties" {
+			return // -P properties override every source, see BindFlags
+		}
+		key, ok := s.Keys[f.Name]
+		if !ok {
+			key = strings.ReplaceAll(f.Name, "-", ".")
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			props[key] = strings.Join(slice.GetSlice(), ",")
+		} else {
+			props[key] = f.Value.String()
+		}
+	})
+	return props, nil
+}
+
+type sourceFunc struct {
+	name string
+	load func() (map[string]string, error)
+}
+
+// SourceFunc returns a Source loading properties with load, e.g. from an HTTP
+// endpoint.
+func SourceFunc(name string, load func() (map[string]string, error)) Source {
+	return sourceFunc{name: name, load: load}
+}
+
+func (s sourceFunc) Name() string {
+	return s.name
+}
+
+func (s sourceFunc) Load() (map[string]string, error) {
+	return s.load()
+}
@@ -0,0 +1,202 @@
+package properties
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSources_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.yaml", "log:\n  level: info\n  json: true\nport: 8080\n")
+	t.Setenv("APP_LOG_LEVEL", "debug")
+	t.Setenv("APP_TIME__INTERVAL_BUSINESS__HOURS", "[]")
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("port", "80", "")
+	flags.String("name", "", "")
+	if err := flags.Parse([]string{"--port=9090"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Properties{}
+	var notified int
+	p.RegisterListener(func(*Properties) { notified++ })
+	for _, src := range []struct {
+		source     Source
+		precedence Precedence
+	}{
+		// added out of order: precedence, not order, decides
+		{EnvSource{Prefix: "APP_"}, PrecedenceEnv},
+		{FileSource{Path: file}, PrecedenceFile},
+		{FlagSource{Flags: flags}, PrecedenceFlags},
+		{MapSource{Values: map[string]string{"log.level": "warn", "log.json": "false", "timeout": "1m"}}, PrecedenceDefaults},
+	} {
+		if err := p.AddSource(src.source, src.precedence); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if notified != 4 {
+		t.Errorf("expected a notification per source, got %d", notified)
+	}
+
+	for key, want := range map[string][2]string{
+		"log.level":                    {"debug", "env:APP_"},
+		"log.json":                     {"true", "file:" + file},
+		"port":                         {"9090", "flags"},
+		"timeout":                      {"1m", "defaults"},
+		"time_interval.business_hours": {"[]", "env:APP_"},
+		"name":                         {"", ""},
+	} {
+		if got, origin := p.Get(key), p.Origin(key); got != want[0] || origin != want[1] {
+			t.Errorf("%s = %q from %q, want %q from %q", key, got, origin, want[0], want[1])
+		}
+	}
+
+	p.Set("log.level", "trace")
+	if p.Get("log.level") != "trace" || p.Origin("log.level") != RuntimeSource {
+		t.Errorf("expected Set to override every source, got %s from %s", p.Get("log.level"), p.Origin("log.level"))
+	}
+	var lookup []string
+	for _, v := range p.Lookup("log.level") {
+		lookup = append(lookup, v.Source+"="+v.Value)
+	}
+	if want := "runtime=trace env:APP_=debug file:" + file + "=info defaults=warn"; strings.Join(lookup, " ") != want {
+		t.Errorf("Lookup = %v, want %s", lookup, want)
+	}
+	if sources := strings.Join(p.Sources(), ","); sources != "flags,env:APP_,file:"+file+",defaults" {
+		t.Errorf("Sources = %s", sources)
+	}
+
+	if !p.RemoveSource("env:APP_") || p.Get("time_interval.business_hours") != "" {
+		t.Error("expected removing a source to remove its values")
+	}
+}
+
+func TestFileSource_Formats(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, content string
+		want          map[string]string
+	}{
+		{"app.properties", "# comment\n\na.b = c=d\n", map[string]string{"a.b": "c=d"}},
+		{"app.yaml", "db:\n  port: 5432\n  ratio: 0.5\n  hosts: [a, b]\n  pool: {max: 10}\nempty:\n", map[string]string{
+			"db.port": "5432", "db.ratio": "0.5", "db.hosts": `["a","b"]`, "db.pool.max": "10", "empty": "",
+		}},
+		{"app.json", `{"db": {"port": 5432, "big": 12345678901234567890, "tls": false}}`, map[string]string{
+			"db.port": "5432", "db.big": "12345678901234567890", "db.tls": "false",
+		}},
+		{"empty.yaml", "", map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FileSource{Path: writeFile(t, dir, tt.name, tt.content)}.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+
+	for name, content := range map[string]string{"bad.properties": "novalue", "bad.yaml": "- a\n- b", "bad.json": "{"} {
+		if _, err := (FileSource{Path: writeFile(t, dir, name, content)}).Load(); err == nil {
+			t.Errorf("expected an error loading %s", name)
+		}
+	}
+}
+
+func TestDirSource_ConfigMapMount(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "..2024_01_01_00_00_00.000")
+	if err := os.Mkdir(data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, data, "log.level", "debug\n")
+	writeFile(t, data, "app.properties", "db.host=postgres\n")
+	for _, link := range [][2]string{{filepath.Base(data), "..data"}, {"..data/log.level", "log.level"}, {"..data/app.properties", "app.properties"}} {
+		if err := os.Symlink(link[0], filepath.Join(dir, link[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := DirSource{Path: dir}.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["log.level"] != "debug" || got["db.host"] != "postgres" {
+		t.Errorf("unexpected properties: %v", got)
+	}
+}
+
+func TestReloadSources_KeepsValuesOnError(t *testing.T) {
+	fail := false
+	remote := SourceFunc("remote", func() (map[string]string, error) {
+		if fail {
+			return nil, errors.New("unavailable")
+		}
+		return map[string]string{"feature": "on"}, nil
+	})
+	p := &Properties{}
+	if err := p.AddSource(remote, PrecedenceRemote); err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	if err := p.ReloadSources(); err == nil || !strings.Contains(err.Error(), "remote: unavailable") {
+		t.Errorf("expected the reload error, got %v", err)
+	}
+	if p.Get("feature") != "on" {
+		t.Error("expected the failed source to keep its values")
+	}
+	if err := p.AddSource(remote, PrecedenceRemote); err == nil {
+		t.Error("expected AddSource to return the load error")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	p := &Properties{}
+	if err := p.LoadFile(filepath.Join(dir, "missing.properties")); err != nil {
+		t.Errorf("expected a missing file to be ignored, got %v", err)
+	}
+	file := writeFile(t, dir, "app.properties", "a=1\n")
+	p.Set("b", "2")
+	if err := p.LoadFile(file); err != nil {
+		t.Fatal(err)
+	}
+	defer p.close()
+	if all := p.GetAll(); all["a"] != "1" || all["b"] != "2" || p.Origin("a") != "file:"+file {
+		t.Errorf("unexpected properties %v", all)
+	}
+	// a runtime value unset with "" must not hide later file changes
+	p.Set("a", "2")
+	p.Set("a", "")
+	if p.Get("a") != "1" || p.Origin("a") != "file:"+file {
+		t.Errorf("expected the file value after unsetting, got %q from %q", p.Get("a"), p.Origin("a"))
+	}
+	writeFile(t, dir, "app.properties", "a=3\n")
+	if err := p.ReloadSources(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Get("a") != "3" || p.Origin("a") != "file:"+file {
+		t.Errorf("expected the reloaded file value, got %q from %q", p.Get("a"), p.Origin("a"))
+	}
+}
@@ -0,0 +1,180 @@
+package properties
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultWatchDebounce is how long Watch waits for changes to a properties
+// file to settle when Properties.WatchDebounce is zero.
+const DefaultWatchDebounce = 100 * time.Millisecond
+
+var reloadMetrics = sync.OnceValues(func() (*prometheus.CounterVec, prometheus.Gauge) {
+	return promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "properties_reloads_total",
+			Help: "The total number of properties file reloads by result",
+		}, []string{"result"}),
+		promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "properties_last_reload_success_timestamp_seconds",
+			Help: "The time of the last successful properties file reload",
+		})
+})
+
+// Watch reloads the properties file when it changes, returning a function to
+// stop watching it and rotated secret files.
+//
+// The directory of the file is watched, so the file is reloaded when it is
+// replaced by a rename, as editors save files, or when a Kubernetes ConfigMap
+// mount swaps its ..data link. Changes are debounced by WatchDebounce and the
+// file is only reloaded when its content changed. A file that fails to parse
+// or validate keeps the current values and is reported in ReloadError. Reloads
+// are counted by the properties_reloads_total metric.
+func (p *Properties) Watch() func() {
+	if p.close != nil {
+		return p.close
+	}
+	filename := p.file()
+	slog.Info(fmt.Sprintf("Watching %s for changes", filename))
+	if err := p.watchPath(path.Dir(filename)); err != nil {
+		slog.Warn("Failed to create watcher for properties file: " + err.Error())
+	}
+	p.Reload = func() { p.reloadFile(true) }
+	return func() {
+		p.watchLock.Lock()
+		defer p.watchLock.Unlock()
+		if p.watcher != nil {
+			_ = p.watcher.Close()
+			p.watcher = nil
+		}
+		if p.reloading != nil {
+			p.reloading.Stop()
+		}
+	}
+}
+
+// ReloadError returns the error of the last reload of the watched file, nil
+// if it succeeded.
+func (p *Properties) ReloadError() error {
+	p.watchLock.Lock()
+	defer p.watchLock.Unlock()
+	return p.reloadErr
+}
+
+func (p *Properties) file() string {
+	p.watchLock.Lock()
+	defer p.watchLock.Unlock()
+	return p.filename
+}
+
+// watchPath adds a directory to the watcher, starting it on first use.
+func (p *Properties) watchPath(dir string) error {
+	p.watchLock.Lock()
+	defer p.watchLock.Unlock()
+	if p.watcher == nil {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		p.watcher = watcher
+		go p.handleEvents(watcher)
+	}
+	return p.watcher.Add(dir)
+}
+
+func (p *Properties) handleEvents(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if e.Op == fsnotify.Chmod {
+				continue
+			}
+			if p.affectsFile(e.Name) {
+				p.scheduleReload()
+			}
+			if p.invalidateSecrets(e.Name) {
+				p.changed(SecretRotation)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn(fmt.Sprintf("error watching properties: %v", err))
+		}
+	}
+}
+
+// affectsFile returns true if a change of name may change the properties
+// file: the file itself, replaced or renamed over, or a hidden ConfigMap
+// ..data link or directory it resolves through.
+func (p *Properties) affectsFile(name string) bool {
+	filename := p.file()
+	if filename == "" || filepath.Dir(name) != filepath.Dir(filename) {
+		return false
+	}
+	return name == filename || strings.HasPrefix(filepath.Base(name), "..")
+}
+
+func (p *Properties) scheduleReload() {
+	p.watchLock.Lock()
+	defer p.watchLock.Unlock()
+	debounce := p.WatchDebounce
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+	if p.reloading == nil {
+		p.reloading = time.AfterFunc(debounce, func() { p.reloadFile(false) })
+	} else {
+		p.reloading.Reset(debounce)
+	}
+}
+
+// reloadFile reloads the watched file if its content changed, or if force is
+// true.
+func (p *Properties) reloadFile(force bool) {
+	filename := p.file()
+	data, err := os.ReadFile(filename)
+	if err != nil && os.IsNotExist(err) {
+		return // removed, or replaced by a rename that has not happened yet
+	}
+	hash := sha256.Sum256(data)
+	p.watchLock.Lock()
+	unchanged := err == nil && hash == p.fileHash && p.reloadErr == nil
+	p.watchLock.Unlock()
+	if unchanged && !force {
+		return
+	}
+
+	if err == nil {
+		slog.Info(fmt.Sprintf("Reloading properties from %s", filename))
+		src := FileSource{Path: filename}
+		err = p.addSource(src, PrecedenceFile, ReloadChange+":"+src.Name())
+	}
+	reloads, lastSuccess := reloadMetrics()
+	p.watchLock.Lock()
+	p.reloadErr = err
+	if err == nil {
+		p.fileHash = hash
+	}
+	p.watchLock.Unlock()
+	if err != nil {
+		reloads.WithLabelValues("error").Inc()
+		slog.Error(fmt.Sprintf("Failed to reload %s, keeping the current properties: %v", filename, err))
+		return
+	}
+	reloads.WithLabelValues("success").Inc()
+	lastSuccess.SetToCurrentTime()
+}
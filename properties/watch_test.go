@@ -0,0 +1,112 @@
+package properties
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// watchFile loads file into new Properties, returning them and a channel
+// receiving each notification.
+func watchFile(t *testing.T, file string) (*Properties, chan struct{}) {
+	t.Helper()
+	p := &Properties{WatchDebounce: 50 * time.Millisecond}
+	notified := make(chan struct{}, 100)
+	if err := p.LoadFile(file); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.close)
+	p.RegisterListener(func(*Properties) { notified <- struct{}{} })
+	return p, notified
+}
+
+func waitFor(t *testing.T, notified chan struct{}, p *Properties, key, expected string) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for p.Get(key) != expected {
+		select {
+		case <-notified:
+		case <-deadline:
+			t.Fatalf("%s = %q, expected %q", key, p.Get(key), expected)
+		}
+	}
+}
+
+func TestWatch_AtomicRename(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.properties", "a=1\n")
+	p, notified := watchFile(t, file)
+
+	tmp := writeFile(t, dir, ".app.properties.swp", "a=2\n")
+	if err := os.Rename(tmp, file); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, notified, p, "a", "2")
+}
+
+func TestWatch_ConfigMapSymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	for _, version := range []string{"..v1", "..v2"} {
+		if err := os.Mkdir(filepath.Join(dir, version), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "..v1/app.properties", "a=1\n")
+	writeFile(t, dir, "..v2/app.properties", "a=2\n")
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "app.properties")
+	if err := os.Symlink("..data/app.properties", file); err != nil {
+		t.Fatal(err)
+	}
+	p, notified := watchFile(t, file)
+
+	// the kubelet swaps ..data to the new version with a rename
+	if err := os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, notified, p, "a", "2")
+}
+
+func TestWatch_DebounceAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.json", `{"a": 1}`)
+	p, notified := watchFile(t, file)
+	reloads, _ := reloadMetrics()
+	successes := testutil.ToFloat64(reloads.WithLabelValues("success"))
+	failures := testutil.ToFloat64(reloads.WithLabelValues("error"))
+
+	for i := 2; i <= 5; i++ {
+		writeFile(t, dir, "app.json", `{"a": `+string(rune('0'+i))+`}`)
+	}
+	waitFor(t, notified, p, "a", "5")
+	time.Sleep(200 * time.Millisecond)
+	if n := testutil.ToFloat64(reloads.WithLabelValues("success")) - successes; n != 1 {
+		t.Errorf("%v reloads, expected the writes to be debounced to 1", n)
+	}
+
+	writeFile(t, dir, "app.json", `{"a": `)
+	deadline := time.Now().Add(5 * time.Second)
+	for p.ReloadError() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p.ReloadError() == nil || testutil.ToFloat64(reloads.WithLabelValues("error")) != failures+1 {
+		t.Fatalf("parse error not reported: %v", p.ReloadError())
+	}
+	if v := p.Get("a"); v != "5" {
+		t.Errorf("parse error changed a to %q", v)
+	}
+
+	writeFile(t, dir, "app.json", `{"a": 6}`)
+	waitFor(t, notified, p, "a", "6")
+	if err := p.ReloadError(); err != nil {
+		t.Errorf("ReloadError after a successful reload: %v", err)
+	}
+}
@@ -226,6 +226,21 @@ func Interpolate(arg string, vars interface{}) string {
 
 }
 
+// InterpolateE templatises the string using the vars as the context like
+// Interpolate, but returns errors instead of logging them, including for
+// map keys that are not in vars.
+func InterpolateE(arg string, vars interface{}) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(arg)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 // InterpolateStrings templatises each string in the slice using the vars as the context
 func InterpolateStrings(arg []string, vars interface{}) []string {
 	out := make([]string, len(arg))